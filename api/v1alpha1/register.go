@@ -25,6 +25,12 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
 		&Probe{},
 		&ProbeList{},
+		&ClusterProbe{},
+		&ClusterProbeList{},
+		&ProbeGroup{},
+		&ProbeGroupList{},
+		&MaintenanceWindow{},
+		&MaintenanceWindowList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
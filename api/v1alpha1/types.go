@@ -2,6 +2,7 @@ package v1alpha1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // +genclient
@@ -22,15 +23,129 @@ type ProbeSpec struct {
 	CheckTarget string `json:"checkTarget"`
 	Interval    string `json:"interval"`
 	Timeout     string `json:"timeout,omitempty"`
+	// Schedule is a cron expression, or several separated by ";", of the
+	// checks. It replaces Interval when set.
+	Schedule string `json:"schedule,omitempty"`
+	// TimeZone of Schedule, UTC by default.
+	TimeZone string `json:"timeZone,omitempty"`
+	// DependsOn names the Probes this probe relies on, in the same namespace,
+	// or "ClusterProbe/<name>". While one of them is not healthy this probe is
+	// suppressed.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Options are specific to the prober of CheckType.
+	Options *runtime.RawExtension `json:"options,omitempty"`
+	// Suspend stops the checks until it is unset, the status keeps the
+	// last result.
+	Suspend bool `json:"suspend,omitempty"`
+	// Retries repeats a failed check within its timeout before it counts
+	// as failed.
+	Retries *RetryPolicy `json:"retries,omitempty"`
+	// SuccessThreshold is the number of consecutive passed checks that turn
+	// a failing probe healthy, 1 when unset.
+	SuccessThreshold int32 `json:"successThreshold,omitempty"`
+	// FailureThreshold is the number of consecutive failed checks that turn
+	// a healthy probe failing, 1 when unset.
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
+// Backoffs of a RetryPolicy.
+const (
+	RetryBackoffFixed       = "Fixed"
+	RetryBackoffExponential = "Exponential"
+)
+
+// RetryPolicy repeats a failed check within a single execution.
+type RetryPolicy struct {
+	// Count is the number of attempts after the first one.
+	Count int32 `json:"count"`
+	// Delay is the wait before the first retry, 1s by default.
+	Delay string `json:"delay,omitempty"`
+	// Backoff is Fixed (default) or Exponential, which doubles the delay
+	// after every retry.
+	Backoff string `json:"backoff,omitempty"`
+	// MaxDelay caps the delay of Exponential backoff.
+	MaxDelay string `json:"maxDelay,omitempty"`
 }
 
 // ProbeStatus defines the observed state of Probe
 type ProbeStatus struct {
-	Healthy       bool         `json:"healthy"`
-	LastProbeTime *metav1.Time `json:"lastProbeTime,omitempty"`
-	Message       string       `json:"message,omitempty"`
+	Healthy       bool               `json:"healthy"`
+	LastProbeTime *metav1.Time       `json:"lastProbeTime,omitempty"`
+	Message       string             `json:"message,omitempty"`
+	Conditions    []metav1.Condition `json:"conditions,omitempty"`
+	// Endpoints are the results of the individual endpoints when the check
+	// probes every endpoint of a Service.
+	Endpoints []EndpointStatus `json:"endpoints,omitempty"`
+	// Heartbeat reports the pings received by a heartbeat check.
+	Heartbeat *HeartbeatStatus `json:"heartbeat,omitempty"`
+	// LastDuration is how long the check took when the status was last
+	// written.
+	LastDuration *metav1.Duration `json:"lastDuration,omitempty"`
+	// LastFailure is the most recent failed check.
+	LastFailure *ProbeResult `json:"lastFailure,omitempty"`
+	// History lists the latest changes of Healthy, oldest first.
+	History []ProbeResult `json:"history,omitempty"`
+	// LastRunRequest is the value of the run-now annotation the last
+	// check run on request served.
+	LastRunRequest string `json:"lastRunRequest,omitempty"`
+	// NextProbeTime is when the next scheduled check is due.
+	NextProbeTime *metav1.Time `json:"nextProbeTime,omitempty"`
+	// Attempts is the number of attempts the last written check took.
+	Attempts int32 `json:"attempts,omitempty"`
+}
+
+// ProbeResult is the outcome of a check at a point in time.
+type ProbeResult struct {
+	Time    metav1.Time `json:"time"`
+	Healthy bool        `json:"healthy"`
+	Message string      `json:"message,omitempty"`
+}
+
+// HeartbeatStatus reports the pings received by a heartbeat check.
+type HeartbeatStatus struct {
+	LastPingTime    *metav1.Time `json:"lastPingTime,omitempty"`
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+	// RunDuration is the time between the start ping and the end of the
+	// last run.
+	RunDuration *metav1.Duration `json:"runDuration,omitempty"`
+}
+
+// EndpointStatus is the result of the check against one endpoint.
+type EndpointStatus struct {
+	Address string `json:"address"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
 }
 
+// Condition type and reasons of a Probe.
+const (
+	ConditionHealthy = "Healthy"
+
+	ReasonCheckPassed = "CheckPassed"
+	ReasonCheckFailed = "CheckFailed"
+	// ReasonSuppressed is set with status Unknown while a probe listed in
+	// DependsOn is not healthy.
+	ReasonSuppressed = "Suppressed"
+	// ReasonPolicyDenied is set with status False while the target policy
+	// of the operator refuses the target, which is not checked then.
+	ReasonPolicyDenied = "PolicyDenied"
+	// ReasonSuspended is set with status Unknown while Spec.Suspend is set.
+	ReasonSuspended = "Suspended"
+	// ReasonInMaintenance is set with status Unknown while a
+	// MaintenanceWindow covers the probe.
+	ReasonInMaintenance = "InMaintenance"
+
+	// ConditionPolicyDenied is True while the target policy refuses the
+	// target and absent otherwise.
+	ConditionPolicyDenied = "PolicyDenied"
+	ReasonTargetDenied    = "TargetDenied"
+)
+
+// AnnotationRunNow asks for a check of a Probe or ClusterProbe right away,
+// out of its schedule. Every new value, e.g. a timestamp, triggers one; the
+// check records the value in Status.LastRunRequest.
+const AnnotationRunNow = "probes.ready.io/run-now"
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // ProbeList contains a list of Probe
@@ -39,3 +154,155 @@ type ProbeList struct {
 	metav1.ListMeta `json:"metadata,omitempty"`
 	Items           []Probe `json:"items"`
 }
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterProbe is the Schema for the cluster-scoped clusterprobes API.
+// It shares its spec and status with Probe and is meant for platform-owned
+// checks that do not belong to a tenant namespace.
+type ClusterProbe struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProbeSpec   `json:"spec,omitempty"`
+	Status ProbeStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterProbeList contains a list of ClusterProbe
+type ClusterProbeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterProbe `json:"items"`
+}
+
+// Aggregation policies of a ProbeGroup.
+const (
+	GroupPolicyAll      = "All"
+	GroupPolicyAny      = "Any"
+	GroupPolicyQuorum   = "Quorum"
+	GroupPolicyWeighted = "Weighted"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ProbeGroup aggregates the Probes selected by label into one health signal.
+type ProbeGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProbeGroupSpec   `json:"spec,omitempty"`
+	Status ProbeGroupStatus `json:"status,omitempty"`
+}
+
+// ProbeGroupSpec defines the desired state of ProbeGroup
+type ProbeGroupSpec struct {
+	// Selector picks the member Probes from the namespace of the group.
+	Selector metav1.LabelSelector `json:"selector"`
+	// Policy is one of All (default), Any, Quorum or Weighted.
+	Policy string `json:"policy,omitempty"`
+	// MinHealthy is the number of healthy members the Quorum policy needs.
+	MinHealthy int32 `json:"minHealthy,omitempty"`
+	// Weights of members by Probe name for the Weighted policy, unlisted members weigh 1.
+	Weights map[string]int32 `json:"weights,omitempty"`
+	// MinHealthyPercent is the share of the total weight the Weighted policy needs healthy.
+	MinHealthyPercent int32 `json:"minHealthyPercent,omitempty"`
+}
+
+// ProbeGroupStatus defines the observed state of ProbeGroup
+type ProbeGroupStatus struct {
+	Healthy            bool               `json:"healthy"`
+	HealthyMembers     int32              `json:"healthyMembers"`
+	TotalMembers       int32              `json:"totalMembers"`
+	Members            []ProbeGroupMember `json:"members,omitempty"`
+	LastEvaluationTime *metav1.Time       `json:"lastEvaluationTime,omitempty"`
+	Message            string             `json:"message,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// ProbeGroupMember is the last known state of one member Probe.
+type ProbeGroupMember struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ProbeGroupList contains a list of ProbeGroup
+type ProbeGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProbeGroup `json:"items"`
+}
+
+// Modes of a MaintenanceWindow.
+const (
+	// MaintenanceModeMute keeps checking the Probes, but their results do
+	// not change their status, dependents or events.
+	MaintenanceModeMute = "Mute"
+	// MaintenanceModePause does not check the Probes.
+	MaintenanceModePause = "Pause"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// MaintenanceWindow holds off the Probes selected by label during planned
+// work, on a cron schedule or in absolute time ranges.
+type MaintenanceWindow struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MaintenanceWindowSpec   `json:"spec,omitempty"`
+	Status MaintenanceWindowStatus `json:"status,omitempty"`
+}
+
+// MaintenanceWindowSpec defines the desired state of MaintenanceWindow
+type MaintenanceWindowSpec struct {
+	// Selector picks the Probes from the namespace of the window, an empty
+	// one all of them. ClusterProbes are never covered.
+	Selector metav1.LabelSelector `json:"selector,omitempty"`
+	// Schedule is a cron expression of the starts of the window, each
+	// lasting Duration.
+	Schedule string           `json:"schedule,omitempty"`
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// TimeZone of Schedule, UTC by default.
+	TimeZone string `json:"timeZone,omitempty"`
+	// Ranges are periods of maintenance besides Schedule.
+	Ranges []TimeRange `json:"ranges,omitempty"`
+	// Mode is Mute (default) or Pause.
+	Mode string `json:"mode,omitempty"`
+}
+
+// TimeRange is the period from Start until End.
+type TimeRange struct {
+	Start metav1.Time `json:"start"`
+	End   metav1.Time `json:"end"`
+}
+
+// MaintenanceWindowStatus defines the observed state of MaintenanceWindow
+type MaintenanceWindowStatus struct {
+	Active bool `json:"active"`
+	// ActiveUntil is the end of the current period while Active.
+	ActiveUntil *metav1.Time `json:"activeUntil,omitempty"`
+	// NextStart is the start of the next period.
+	NextStart *metav1.Time `json:"nextStart,omitempty"`
+	// Probes is the number of Probes the selector matches.
+	Probes             int32        `json:"probes"`
+	LastEvaluationTime *metav1.Time `json:"lastEvaluationTime,omitempty"`
+	// Message explains an invalid spec.
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// MaintenanceWindowList contains a list of MaintenanceWindow
+type MaintenanceWindowList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MaintenanceWindow `json:"items"`
+}
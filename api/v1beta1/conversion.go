@@ -0,0 +1,411 @@
+package v1beta1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// SpecAnnotation keeps the v1beta1 spec of an object stored as v1alpha1, so
+// what v1alpha1 cannot express, such as exec arguments with spaces,
+// survives a round trip through the storage version.
+const SpecAnnotation = "probes.ready.io/v1beta1-spec"
+
+// ConvertTo converts this Probe to the v1alpha1 storage version.
+func (src *Probe) ConvertTo(dst *v1alpha1.Probe) error {
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	if err := convertSpecTo(&src.Spec, &dst.Spec); err != nil {
+		return err
+	}
+	if err := setSpecAnnotation(&dst.ObjectMeta, &src.Spec); err != nil {
+		return err
+	}
+	convertStatusTo(&src.Status, &dst.Status)
+	return nil
+}
+
+// ConvertFrom converts a v1alpha1 Probe to this version.
+func (dst *Probe) ConvertFrom(src *v1alpha1.Probe) error {
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	restored := popSpecAnnotation(&dst.ObjectMeta)
+	if err := convertSpecFrom(&src.Spec, restored, &dst.Spec); err != nil {
+		return err
+	}
+	convertStatusFrom(&src.Status, &dst.Status)
+	return nil
+}
+
+func convertSpecTo(in *ProbeSpec, out *v1alpha1.ProbeSpec) error {
+	switch {
+	case in.HTTP != nil:
+		out.CheckType = "http"
+		out.CheckTarget = in.HTTP.URL
+	case in.TCP != nil:
+		out.CheckType = "tcp"
+		out.CheckTarget = net.JoinHostPort(in.TCP.Host, strconv.Itoa(int(in.TCP.Port)))
+	case in.Exec != nil:
+		out.CheckType = "exec"
+		out.CheckTarget = strings.Join(in.Exec.Command, " ")
+	case in.DNS != nil:
+		out.CheckType = "dns"
+		out.CheckTarget = in.DNS.Name
+	case in.Kubernetes != nil:
+		out.CheckType = "kubernetes"
+		out.CheckTarget = kubernetesTarget(in.Kubernetes)
+	case in.Heartbeat != nil:
+		out.CheckType = "heartbeat"
+		out.CheckTarget = in.Heartbeat.SecretName
+	case in.CronJob != nil:
+		out.CheckType = "cronjob"
+		out.CheckTarget = in.CronJob.Name
+		if in.CronJob.Namespace != "" {
+			out.CheckTarget = in.CronJob.Namespace + "/" + in.CronJob.Name
+		}
+	case in.Plugin != nil:
+		out.CheckType = in.Plugin.Type
+		out.CheckTarget = in.Plugin.Target
+	default:
+		return fmt.Errorf("spec has no check set")
+	}
+
+	out.Interval = ""
+	if in.Interval.Duration != 0 {
+		out.Interval = in.Interval.Duration.String()
+	}
+	out.Timeout = ""
+	if in.Timeout != nil {
+		out.Timeout = in.Timeout.Duration.String()
+	}
+	out.DependsOn = in.DependsOn
+	out.SuccessThreshold = in.SuccessThreshold
+	out.FailureThreshold = in.FailureThreshold
+	out.Suspend = in.Suspend
+	out.Schedule = in.Schedule
+	out.TimeZone = in.TimeZone
+	out.Retries = nil
+	if r := in.Retries; r != nil {
+		out.Retries = &v1alpha1.RetryPolicy{Count: r.Count, Backoff: r.Backoff}
+		if r.Delay != nil {
+			out.Retries.Delay = r.Delay.Duration.String()
+		}
+		if r.MaxDelay != nil {
+			out.Retries.MaxDelay = r.MaxDelay.Duration.String()
+		}
+	}
+	out.Options = nil
+	var options interface{}
+	switch {
+	case in.HTTP != nil && (in.Endpoints != nil || in.HTTP.Method != "" || len(in.HTTP.Headers) > 0 || len(in.HTTP.ExpectedStatusCodes) > 0):
+		options = httpOptions{
+			Endpoints:           in.Endpoints,
+			Method:              in.HTTP.Method,
+			Headers:             in.HTTP.Headers,
+			ExpectedStatusCodes: in.HTTP.ExpectedStatusCodes,
+		}
+	case in.Endpoints != nil:
+		options = endpointOptions{Endpoints: in.Endpoints}
+	case in.Exec != nil && (in.Exec.Pod != nil || in.Exec.Job != nil):
+		options = execOptions{Pod: in.Exec.Pod, Job: in.Exec.Job}
+	case in.Kubernetes != nil && (in.Kubernetes.LabelSelector != "" || in.Kubernetes.Condition != "" || in.Kubernetes.Status != "" || len(in.Kubernetes.Fields) > 0):
+		options = kubernetesOptions{
+			LabelSelector: in.Kubernetes.LabelSelector,
+			Condition:     in.Kubernetes.Condition,
+			Status:        in.Kubernetes.Status,
+			Fields:        in.Kubernetes.Fields,
+		}
+	case in.Heartbeat != nil && in.Heartbeat.Grace != nil:
+		options = heartbeatOptions{Grace: in.Heartbeat.Grace.Duration.String()}
+	case in.CronJob != nil && (in.CronJob.Tolerance != nil || in.CronJob.MaxDuration != nil):
+		opts := cronJobOptions{}
+		if in.CronJob.Tolerance != nil {
+			opts.Tolerance = in.CronJob.Tolerance.Duration.String()
+		}
+		if in.CronJob.MaxDuration != nil {
+			opts.MaxDuration = in.CronJob.MaxDuration.Duration.String()
+		}
+		options = opts
+	case in.Plugin != nil:
+		out.Options = in.Plugin.Options.DeepCopy()
+	}
+	if options != nil {
+		data, err := json.Marshal(options)
+		if err != nil {
+			return err
+		}
+		out.Options = &runtime.RawExtension{Raw: data}
+	}
+	return nil
+}
+
+// endpointOptions are the v1alpha1 options of tcp checks.
+type endpointOptions struct {
+	Endpoints *EndpointFanOut `json:"endpoints,omitempty"`
+}
+
+// httpOptions are the v1alpha1 options of http checks.
+type httpOptions struct {
+	Endpoints           *EndpointFanOut   `json:"endpoints,omitempty"`
+	Method              string            `json:"method,omitempty"`
+	Headers             map[string]string `json:"headers,omitempty"`
+	ExpectedStatusCodes []int32           `json:"expectedStatusCodes,omitempty"`
+}
+
+// execOptions are the v1alpha1 options of exec checks.
+type execOptions struct {
+	Pod *ExecPod `json:"pod,omitempty"`
+	Job *ExecJob `json:"job,omitempty"`
+}
+
+// kubernetesOptions are the v1alpha1 options of kubernetes checks.
+type kubernetesOptions struct {
+	LabelSelector string   `json:"labelSelector,omitempty"`
+	Condition     string   `json:"condition,omitempty"`
+	Status        string   `json:"status,omitempty"`
+	Fields        []string `json:"fields,omitempty"`
+}
+
+// heartbeatOptions are the v1alpha1 options of heartbeat checks.
+type heartbeatOptions struct {
+	Grace string `json:"grace,omitempty"`
+}
+
+// cronJobOptions are the v1alpha1 options of cronjob checks.
+type cronJobOptions struct {
+	Tolerance   string `json:"tolerance,omitempty"`
+	MaxDuration string `json:"maxDuration,omitempty"`
+}
+
+// kubernetesTarget joins the v1alpha1 target of c,
+// "<resource>/[<namespace>/]<name>", or "<resource>[/<namespace>]" with a
+// label selector and no name.
+func kubernetesTarget(c *KubernetesCheck) string {
+	parts := []string{c.Resource}
+	if c.Namespace != "" {
+		parts = append(parts, c.Namespace)
+	}
+	if c.Name != "" {
+		parts = append(parts, c.Name)
+	}
+	return strings.Join(parts, "/")
+}
+
+// convertSpecFrom builds a v1beta1 spec from a v1alpha1 one. Fields v1alpha1
+// cannot express are taken from restored, which may be nil. It is lenient on
+// malformed durations and targets so that existing objects stay readable.
+func convertSpecFrom(in *v1alpha1.ProbeSpec, restored *ProbeSpec, out *ProbeSpec) error {
+	if restored == nil {
+		restored = &ProbeSpec{}
+	}
+	*out = ProbeSpec{
+		SuccessThreshold: in.SuccessThreshold,
+		FailureThreshold: in.FailureThreshold,
+		DependsOn:        in.DependsOn,
+		Suspend:          in.Suspend,
+		Schedule:         in.Schedule,
+		TimeZone:         in.TimeZone,
+	}
+	// Objects written before v1alpha1 had thresholds keep them in the
+	// annotation only
+	if out.SuccessThreshold == 0 {
+		out.SuccessThreshold = restored.SuccessThreshold
+	}
+	if out.FailureThreshold == 0 {
+		out.FailureThreshold = restored.FailureThreshold
+	}
+	if r := in.Retries; r != nil {
+		out.Retries = &RetryPolicy{Count: r.Count, Backoff: r.Backoff, Delay: optionalDuration(r.Delay), MaxDelay: optionalDuration(r.MaxDelay)}
+	}
+
+	switch in.CheckType {
+	case "http":
+		out.HTTP = &HTTPCheck{URL: in.CheckTarget}
+		if in.Options != nil {
+			var opts httpOptions
+			if err := json.Unmarshal(in.Options.Raw, &opts); err == nil {
+				out.HTTP.Method = opts.Method
+				out.HTTP.Headers = opts.Headers
+				out.HTTP.ExpectedStatusCodes = opts.ExpectedStatusCodes
+			}
+		}
+	case "tcp":
+		out.TCP = &TCPCheck{Host: in.CheckTarget}
+		if host, port, err := net.SplitHostPort(in.CheckTarget); err == nil {
+			out.TCP.Host = host
+			if p, err := strconv.Atoi(port); err == nil {
+				out.TCP.Port = int32(p)
+			}
+		}
+	case "exec":
+		// Prefer the restored argv when it still matches, it may hold
+		// arguments with spaces that strings.Fields would split.
+		if restored.Exec != nil && strings.Join(restored.Exec.Command, " ") == in.CheckTarget {
+			out.Exec = &ExecCheck{Command: restored.Exec.Command}
+		} else {
+			out.Exec = &ExecCheck{Command: strings.Fields(in.CheckTarget)}
+		}
+		if in.Options != nil {
+			var opts execOptions
+			if err := json.Unmarshal(in.Options.Raw, &opts); err == nil {
+				out.Exec.Pod = opts.Pod
+				out.Exec.Job = opts.Job
+			}
+		}
+	case "dns":
+		out.DNS = &DNSCheck{Name: in.CheckTarget}
+	case "kubernetes":
+		out.Kubernetes = &KubernetesCheck{}
+		if in.Options != nil {
+			var opts kubernetesOptions
+			if err := json.Unmarshal(in.Options.Raw, &opts); err == nil {
+				out.Kubernetes = &KubernetesCheck{
+					LabelSelector: opts.LabelSelector,
+					Condition:     opts.Condition,
+					Status:        opts.Status,
+					Fields:        opts.Fields,
+				}
+			}
+		}
+		parts := strings.Split(in.CheckTarget, "/")
+		out.Kubernetes.Resource = parts[0]
+		switch rest := parts[1:]; {
+		case out.Kubernetes.LabelSelector != "" && len(rest) == 1:
+			out.Kubernetes.Namespace = rest[0]
+		case out.Kubernetes.LabelSelector == "" && len(rest) == 1:
+			out.Kubernetes.Name = rest[0]
+		case out.Kubernetes.LabelSelector == "" && len(rest) == 2:
+			out.Kubernetes.Namespace, out.Kubernetes.Name = rest[0], rest[1]
+		case len(rest) > 0:
+			// Malformed, keep it readable
+			out.Kubernetes.Resource = in.CheckTarget
+		}
+	case "heartbeat":
+		out.Heartbeat = &HeartbeatCheck{SecretName: in.CheckTarget}
+		if in.Options != nil {
+			var opts heartbeatOptions
+			if err := json.Unmarshal(in.Options.Raw, &opts); err == nil {
+				out.Heartbeat.Grace = optionalDuration(opts.Grace)
+			}
+		}
+	case "cronjob":
+		out.CronJob = &CronJobCheck{Name: in.CheckTarget}
+		if ns, name, ok := strings.Cut(in.CheckTarget, "/"); ok && !strings.Contains(name, "/") {
+			out.CronJob.Namespace, out.CronJob.Name = ns, name
+		}
+		if in.Options != nil {
+			var opts cronJobOptions
+			if err := json.Unmarshal(in.Options.Raw, &opts); err == nil {
+				out.CronJob.Tolerance = optionalDuration(opts.Tolerance)
+				out.CronJob.MaxDuration = optionalDuration(opts.MaxDuration)
+			}
+		}
+	default:
+		// Plugin check types, and any other this version has no member
+		// for, keep their target and options as they are
+		out.Plugin = &PluginCheck{Type: in.CheckType, Target: in.CheckTarget, Options: in.Options.DeepCopy()}
+	}
+	if (out.HTTP != nil || out.TCP != nil) && in.Options != nil {
+		var opts endpointOptions
+		if err := json.Unmarshal(in.Options.Raw, &opts); err == nil {
+			out.Endpoints = opts.Endpoints
+		}
+	}
+
+	if d, err := time.ParseDuration(in.Interval); err == nil {
+		out.Interval = metav1.Duration{Duration: d}
+	}
+	if d, err := time.ParseDuration(in.Timeout); err == nil {
+		out.Timeout = &metav1.Duration{Duration: d}
+	}
+	return nil
+}
+
+func convertStatusTo(in *ProbeStatus, out *v1alpha1.ProbeStatus) {
+	out.Healthy = in.Healthy
+	out.LastProbeTime = in.LastProbeTime.DeepCopy()
+	out.Message = in.Message
+	out.Conditions = in.Conditions
+	out.Endpoints = nil
+	for _, e := range in.Endpoints {
+		out.Endpoints = append(out.Endpoints, v1alpha1.EndpointStatus(e))
+	}
+	out.Heartbeat = (*v1alpha1.HeartbeatStatus)(in.Heartbeat.DeepCopy())
+	out.LastDuration = in.LastDuration.DeepCopy()
+	out.LastFailure = (*v1alpha1.ProbeResult)(in.LastFailure.DeepCopy())
+	out.History = nil
+	for _, h := range in.History {
+		out.History = append(out.History, v1alpha1.ProbeResult(h))
+	}
+	out.LastRunRequest = in.LastRunRequest
+	out.NextProbeTime = in.NextProbeTime.DeepCopy()
+	out.Attempts = in.Attempts
+}
+
+func convertStatusFrom(in *v1alpha1.ProbeStatus, out *ProbeStatus) {
+	out.Healthy = in.Healthy
+	out.LastProbeTime = in.LastProbeTime.DeepCopy()
+	out.Message = in.Message
+	out.Conditions = in.Conditions
+	out.Endpoints = nil
+	for _, e := range in.Endpoints {
+		out.Endpoints = append(out.Endpoints, EndpointStatus(e))
+	}
+	out.Heartbeat = (*HeartbeatStatus)(in.Heartbeat.DeepCopy())
+	out.LastDuration = in.LastDuration.DeepCopy()
+	out.LastFailure = (*ProbeResult)(in.LastFailure.DeepCopy())
+	out.History = nil
+	for _, h := range in.History {
+		out.History = append(out.History, ProbeResult(h))
+	}
+	out.LastRunRequest = in.LastRunRequest
+	out.NextProbeTime = in.NextProbeTime.DeepCopy()
+	out.Attempts = in.Attempts
+}
+
+func setSpecAnnotation(meta *metav1.ObjectMeta, spec *ProbeSpec) error {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[SpecAnnotation] = string(data)
+	return nil
+}
+
+// popSpecAnnotation removes the SpecAnnotation from meta and returns the spec
+// it holds. A malformed annotation is dropped rather than failing conversion.
+func popSpecAnnotation(meta *metav1.ObjectMeta) *ProbeSpec {
+	data, ok := meta.Annotations[SpecAnnotation]
+	if !ok {
+		return nil
+	}
+	delete(meta.Annotations, SpecAnnotation)
+	if len(meta.Annotations) == 0 {
+		meta.Annotations = nil
+	}
+
+	spec := &ProbeSpec{}
+	if err := json.Unmarshal([]byte(data), spec); err != nil {
+		return nil
+	}
+	return spec
+}
+
+// optionalDuration parses a v1alpha1 duration, nil when it is empty or
+// malformed.
+func optionalDuration(s string) *metav1.Duration {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil
+	}
+	return &metav1.Duration{Duration: d}
+}
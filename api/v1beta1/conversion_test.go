@@ -0,0 +1,285 @@
+package v1beta1
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestProbeRoundTripThroughV1alpha1(t *testing.T) {
+	timeout := metav1.Duration{Duration: 2 * time.Second}
+	tests := []struct {
+		name string
+		spec ProbeSpec
+	}{
+		{
+			name: "http with headers and thresholds",
+			spec: ProbeSpec{
+				HTTP: &HTTPCheck{
+					URL:                 "https://example.com/health",
+					Method:              "HEAD",
+					Headers:             map[string]string{"X-Probe": "heartbeat"},
+					ExpectedStatusCodes: []int32{200, 204},
+				},
+				Interval:         metav1.Duration{Duration: 30 * time.Second},
+				Timeout:          &timeout,
+				SuccessThreshold: 1,
+				FailureThreshold: 3,
+			},
+		},
+		{
+			name: "tcp",
+			spec: ProbeSpec{
+				TCP:      &TCPCheck{Host: "postgres", Port: 5432},
+				Interval: metav1.Duration{Duration: 10 * time.Second},
+			},
+		},
+		{
+			name: "suspended",
+			spec: ProbeSpec{
+				DNS:      &DNSCheck{Name: "db.internal"},
+				Interval: metav1.Duration{Duration: 10 * time.Second},
+				Suspend:  true,
+			},
+		},
+		{
+			name: "cron schedule",
+			spec: ProbeSpec{
+				HTTP:     &HTTPCheck{URL: "https://shop.example.com/synthetic-order"},
+				Schedule: "*/5 9-17 * * mon-fri; 0 * * * *",
+				TimeZone: "Europe/Berlin",
+			},
+		},
+		{
+			name: "retries with backoff",
+			spec: ProbeSpec{
+				TCP:      &TCPCheck{Host: "vpn-gateway", Port: 443},
+				Interval: metav1.Duration{Duration: 30 * time.Second},
+				Retries: &RetryPolicy{
+					Count:    3,
+					Delay:    &metav1.Duration{Duration: 200 * time.Millisecond},
+					Backoff:  "Exponential",
+					MaxDelay: &metav1.Duration{Duration: time.Second},
+				},
+			},
+		},
+		{
+			name: "http fanned out to endpoints",
+			spec: ProbeSpec{
+				HTTP:      &HTTPCheck{URL: "http://api.shop.svc:8080/health"},
+				Endpoints: &EndpointFanOut{Policy: "Percentage", MinHealthyPercent: 50},
+				Interval:  metav1.Duration{Duration: 10 * time.Second},
+			},
+		},
+		{
+			name: "exec with spaced argument",
+			spec: ProbeSpec{
+				Exec:     &ExecCheck{Command: []string{"sh", "-c", "test -f /tmp/ready"}},
+				Interval: metav1.Duration{Duration: time.Minute},
+			},
+		},
+		{
+			name: "exec in another pod",
+			spec: ProbeSpec{
+				Exec: &ExecCheck{
+					Command: []string{"pg_isready", "-h", "db"},
+					Pod:     &ExecPod{LabelSelector: "app=api", Container: "app"},
+				},
+				Interval: metav1.Duration{Duration: time.Minute},
+			},
+		},
+		{
+			name: "exec in a Job",
+			spec: ProbeSpec{
+				Exec: &ExecCheck{
+					Command: []string{"redis-cli", "-h", "cache", "ping"},
+					Job: &ExecJob{
+						Image:              "redis:7",
+						ServiceAccountName: "cache-check",
+						Resources: &corev1.ResourceRequirements{
+							Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+						},
+					},
+				},
+				Interval: metav1.Duration{Duration: time.Minute},
+			},
+		},
+		{
+			name: "dns",
+			spec: ProbeSpec{
+				DNS:      &DNSCheck{Name: "kubernetes.default.svc"},
+				Interval: metav1.Duration{Duration: 5 * time.Second},
+			},
+		},
+		{
+			name: "kubernetes object",
+			spec: ProbeSpec{
+				Kubernetes: &KubernetesCheck{
+					Resource:  "deployments.v1.apps",
+					Namespace: "shop",
+					Name:      "api",
+					Condition: "Available",
+					Fields:    []string{"status.readyReplicas >= 2"},
+				},
+				Interval: metav1.Duration{Duration: 30 * time.Second},
+			},
+		},
+		{
+			name: "kubernetes objects by selector",
+			spec: ProbeSpec{
+				Kubernetes: &KubernetesCheck{Resource: "certificates.v1.cert-manager.io", LabelSelector: "app=shop", Condition: "Ready"},
+				Interval:   metav1.Duration{Duration: time.Minute},
+			},
+		},
+		{
+			name: "heartbeat",
+			spec: ProbeSpec{
+				Heartbeat: &HeartbeatCheck{SecretName: "nightly-backup-heartbeat", Grace: &metav1.Duration{Duration: 30 * time.Minute}},
+				Interval:  metav1.Duration{Duration: 24 * time.Hour},
+			},
+		},
+		{
+			name: "cronjob in another namespace",
+			spec: ProbeSpec{
+				CronJob: &CronJobCheck{
+					Namespace:   "ops",
+					Name:        "nightly-backup",
+					Tolerance:   &metav1.Duration{Duration: 15 * time.Minute},
+					MaxDuration: &metav1.Duration{Duration: 2 * time.Hour},
+				},
+				Interval: metav1.Duration{Duration: 5 * time.Minute},
+			},
+		},
+		{
+			name: "cronjob",
+			spec: ProbeSpec{
+				CronJob:  &CronJobCheck{Name: "nightly-backup"},
+				Interval: metav1.Duration{Duration: 5 * time.Minute},
+			},
+		},
+		{
+			name: "plugin",
+			spec: ProbeSpec{
+				Plugin: &PluginCheck{
+					Type:    "sap-rfc",
+					Target:  "PRD/100",
+					Options: &runtime.RawExtension{Raw: []byte(`{"function":"RFC_PING"}`)},
+				},
+				Interval: metav1.Duration{Duration: time.Minute},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := &Probe{
+				ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "default"},
+				Spec:       tt.spec,
+				Status: ProbeStatus{
+					Healthy:        true,
+					Message:        "Check passed",
+					LastDuration:   &metav1.Duration{Duration: 40 * time.Millisecond},
+					LastFailure:    &ProbeResult{Time: metav1.Unix(1700000000, 0), Message: "Check failed"},
+					History:        []ProbeResult{{Time: metav1.Unix(1700000060, 0), Healthy: true, Message: "Check passed"}},
+					LastRunRequest: "2026-03-01T12:00:00Z",
+					NextProbeTime:  &metav1.Time{Time: time.Unix(1700000120, 0)},
+					Attempts:       2,
+				},
+			}
+
+			hub := &v1alpha1.Probe{}
+			if err := in.ConvertTo(hub); err != nil {
+				t.Fatalf("ConvertTo: %v", err)
+			}
+			out := &Probe{}
+			if err := out.ConvertFrom(hub); err != nil {
+				t.Fatalf("ConvertFrom: %v", err)
+			}
+
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("round trip mismatch:\n in: %+v\nout: %+v", in, out)
+			}
+		})
+	}
+}
+
+func TestConvertFromV1alpha1(t *testing.T) {
+	src := &v1alpha1.Probe{
+		ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "default"},
+		Spec: v1alpha1.ProbeSpec{
+			CheckType:   "tcp",
+			CheckTarget: "redis-master:6379",
+			Interval:    "15s",
+			Timeout:     "3s",
+		},
+	}
+
+	dst := &Probe{}
+	if err := dst.ConvertFrom(src); err != nil {
+		t.Fatalf("ConvertFrom: %v", err)
+	}
+	if dst.Spec.TCP == nil || dst.Spec.TCP.Host != "redis-master" || dst.Spec.TCP.Port != 6379 {
+		t.Errorf("unexpected tcp check: %+v", dst.Spec.TCP)
+	}
+	if dst.Spec.Interval.Duration != 15*time.Second {
+		t.Errorf("interval = %v; want 15s", dst.Spec.Interval.Duration)
+	}
+	if dst.Spec.Timeout == nil || dst.Spec.Timeout.Duration != 3*time.Second {
+		t.Errorf("timeout = %v; want 3s", dst.Spec.Timeout)
+	}
+
+	// The target was edited through v1alpha1 after the annotation was written,
+	// the stored command must not win over it.
+	src.Spec = v1alpha1.ProbeSpec{CheckType: "exec", CheckTarget: "true", Interval: "banana"}
+	src.Annotations = map[string]string{SpecAnnotation: `{"exec":{"command":["false"]},"failureThreshold":2}`}
+	dst = &Probe{}
+	if err := dst.ConvertFrom(src); err != nil {
+		t.Fatalf("ConvertFrom: %v", err)
+	}
+	if !reflect.DeepEqual(dst.Spec.Exec.Command, []string{"true"}) {
+		t.Errorf("command = %v; want [true]", dst.Spec.Exec.Command)
+	}
+	if dst.Spec.FailureThreshold != 2 {
+		t.Errorf("failureThreshold = %d; want 2", dst.Spec.FailureThreshold)
+	}
+	if dst.Spec.Interval.Duration != 0 {
+		t.Errorf("malformed interval should convert to zero, got %v", dst.Spec.Interval.Duration)
+	}
+	if _, ok := dst.Annotations[SpecAnnotation]; ok {
+		t.Errorf("%s annotation leaked into v1beta1 object", SpecAnnotation)
+	}
+}
+
+// Check types without a member of their own must survive a read and write
+// through v1beta1, or listing Probes in v1beta1 would fail.
+func TestConvertUnknownCheckType(t *testing.T) {
+	want := v1alpha1.ProbeSpec{
+		CheckType:   "sap-rfc",
+		CheckTarget: "PRD/100",
+		Interval:    "30s",
+		Options:     &runtime.RawExtension{Raw: []byte(`{"function":"RFC_PING"}`)},
+	}
+	src := &v1alpha1.Probe{ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "shop"}, Spec: want}
+
+	beta := &Probe{}
+	if err := beta.ConvertFrom(src); err != nil {
+		t.Fatalf("ConvertFrom: %v", err)
+	}
+	if beta.Spec.Plugin == nil || beta.Spec.Plugin.Type != "sap-rfc" {
+		t.Fatalf("plugin = %+v; want the sap-rfc check", beta.Spec.Plugin)
+	}
+	hub := &v1alpha1.Probe{}
+	if err := beta.ConvertTo(hub); err != nil {
+		t.Fatalf("ConvertTo: %v", err)
+	}
+	if !reflect.DeepEqual(hub.Spec, want) {
+		t.Errorf("spec = %+v; want %+v", hub.Spec, want)
+	}
+}
@@ -0,0 +1,5 @@
+// +k8s:deepcopy-gen=package
+// +k8s:conversion-gen=heartbeat-operator/api/v1alpha1
+// +groupName=probes.ready.io
+
+package v1beta1
@@ -0,0 +1,277 @@
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Probe is the Schema for the probes API
+type Probe struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProbeSpec   `json:"spec,omitempty"`
+	Status ProbeStatus `json:"status,omitempty"`
+}
+
+// ProbeSpec defines the desired state of Probe.
+// Exactly one of HTTP, TCP, Exec, DNS, Kubernetes, Heartbeat, CronJob or
+// Plugin must be set.
+type ProbeSpec struct {
+	HTTP       *HTTPCheck       `json:"http,omitempty"`
+	TCP        *TCPCheck        `json:"tcp,omitempty"`
+	Exec       *ExecCheck       `json:"exec,omitempty"`
+	DNS        *DNSCheck        `json:"dns,omitempty"`
+	Kubernetes *KubernetesCheck `json:"kubernetes,omitempty"`
+	Heartbeat  *HeartbeatCheck  `json:"heartbeat,omitempty"`
+	CronJob    *CronJobCheck    `json:"cronJob,omitempty"`
+	Plugin     *PluginCheck     `json:"plugin,omitempty"`
+	// Endpoints makes an http or tcp check probe every ready endpoint of
+	// the Service it targets, instead of the Service address.
+	Endpoints *EndpointFanOut `json:"endpoints,omitempty"`
+
+	// Interval between two checks.
+	Interval metav1.Duration `json:"interval"`
+	// Schedule is a cron expression, or several separated by ";", of the
+	// checks. It replaces Interval when set.
+	Schedule string `json:"schedule,omitempty"`
+	// TimeZone of Schedule, UTC by default.
+	TimeZone string `json:"timeZone,omitempty"`
+	// Timeout of a single check.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// SuccessThreshold is the number of consecutive successes needed to turn healthy.
+	SuccessThreshold int32 `json:"successThreshold,omitempty"`
+	// FailureThreshold is the number of consecutive failures needed to turn unhealthy.
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+	// DependsOn names the Probes this probe relies on, in the same namespace,
+	// or "ClusterProbe/<name>". While one of them is not healthy this probe is
+	// suppressed.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Suspend stops the checks until it is unset, the status keeps the
+	// last result.
+	Suspend bool `json:"suspend,omitempty"`
+	// Retries repeats a failed check within its timeout before it counts
+	// as failed.
+	Retries *RetryPolicy `json:"retries,omitempty"`
+}
+
+// RetryPolicy repeats a failed check within a single execution.
+type RetryPolicy struct {
+	// Count is the number of attempts after the first one.
+	Count int32 `json:"count"`
+	// Delay is the wait before the first retry, 1s by default.
+	Delay *metav1.Duration `json:"delay,omitempty"`
+	// Backoff is Fixed (default) or Exponential, which doubles the delay
+	// after every retry.
+	Backoff string `json:"backoff,omitempty"`
+	// MaxDelay caps the delay of Exponential backoff.
+	MaxDelay *metav1.Duration `json:"maxDelay,omitempty"`
+}
+
+// HTTPCheck issues an HTTP request and expects a successful status code.
+type HTTPCheck struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// ExpectedStatusCodes defaults to any 2xx code.
+	ExpectedStatusCodes []int32 `json:"expectedStatusCodes,omitempty"`
+}
+
+// TCPCheck opens a TCP connection to Host:Port.
+type TCPCheck struct {
+	Host string `json:"host"`
+	Port int32  `json:"port"`
+}
+
+// EndpointFanOut decides how the results of the endpoints of a Service add
+// up to the result of the probe.
+type EndpointFanOut struct {
+	// Policy is All (the default), Any or Percentage.
+	Policy string `json:"policy,omitempty"`
+	// MinHealthyPercent is the share of endpoints that must pass with the
+	// Percentage policy.
+	MinHealthyPercent int32 `json:"minHealthyPercent,omitempty"`
+}
+
+// ExecCheck runs a command and expects a zero exit code.
+type ExecCheck struct {
+	Command []string `json:"command"`
+	// Pod runs the command in a container of another pod through the
+	// pods/exec subresource instead of in the operator.
+	Pod *ExecPod `json:"pod,omitempty"`
+	// Job runs the command in a Job of its own with an image of its own
+	// instead. Only one of Pod and Job may be set.
+	Job *ExecJob `json:"job,omitempty"`
+}
+
+// ExecJob describes the Job an exec check runs its command in.
+type ExecJob struct {
+	Image string `json:"image"`
+	// Namespace defaults to the one of the Probe, the only one a Probe may
+	// run Jobs in.
+	Namespace          string                       `json:"namespace,omitempty"`
+	ServiceAccountName string                       `json:"serviceAccountName,omitempty"`
+	Resources          *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// ExecPod picks the pod an exec check runs its command in.
+type ExecPod struct {
+	// Namespace defaults to the one of the Probe, the only one a Probe may
+	// run commands in.
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the pod. Exactly one of Name and LabelSelector must be set.
+	Name string `json:"name,omitempty"`
+	// LabelSelector picks the first running and ready pod that matches.
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// Container defaults to the first container of the pod.
+	Container string `json:"container,omitempty"`
+}
+
+// DNSCheck resolves Name and expects at least one address.
+type DNSCheck struct {
+	Name string `json:"name"`
+}
+
+// KubernetesCheck reads objects through the API and expects a status
+// condition and field comparisons to hold on them. Without Condition and
+// Fields an object only has to exist.
+type KubernetesCheck struct {
+	// Resource is "<resource>.<version>[.<group>]", e.g. deployments.v1.apps.
+	Resource string `json:"resource"`
+	// Namespace defaults to the one of the Probe, the only one a Probe may
+	// read objects in. ClusterProbes leave it empty for cluster-scoped
+	// objects.
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the object. Exactly one of Name and LabelSelector must be set.
+	Name string `json:"name,omitempty"`
+	// LabelSelector checks every matching object instead of a named one.
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// Condition is the type of a status condition, e.g. Available or Ready.
+	Condition string `json:"condition,omitempty"`
+	// Status the condition must have, "True" by default.
+	Status string `json:"status,omitempty"`
+	// Fields are comparisons such as "status.readyReplicas >= 2".
+	Fields []string `json:"fields,omitempty"`
+}
+
+// HeartbeatCheck expects a job to ping the operator within the interval
+// plus Grace, instead of checking a target.
+type HeartbeatCheck struct {
+	// SecretName is the Secret holding the ping token, in the namespace of
+	// the Probe. It is created with a random token when missing.
+	SecretName string `json:"secretName"`
+	// Grace is added to the interval before a missing ping fails the
+	// check, 1m by default.
+	Grace *metav1.Duration `json:"grace,omitempty"`
+}
+
+// CronJobCheck watches the Jobs a CronJob creates: the last finished run
+// must have succeeded and the last scheduled one must have started.
+type CronJobCheck struct {
+	// Namespace defaults to the one of the Probe, the only one a Probe may
+	// watch CronJobs in. ClusterProbes must set it.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Tolerance is how late a scheduled run may start, 5m by default.
+	Tolerance *metav1.Duration `json:"tolerance,omitempty"`
+	// MaxDuration fails the check when a run takes longer.
+	MaxDuration *metav1.Duration `json:"maxDuration,omitempty"`
+}
+
+// PluginCheck runs a check type served by a prober plugin. Check types
+// without a member of their own in this version are read as one, so no
+// Probe is lost converting it.
+type PluginCheck struct {
+	// Type is the check type, as the plugin registered it.
+	Type   string `json:"type"`
+	Target string `json:"target"`
+	// Options are handed to the check type as they are.
+	Options *runtime.RawExtension `json:"options,omitempty"`
+}
+
+// ProbeStatus defines the observed state of Probe
+type ProbeStatus struct {
+	Healthy       bool               `json:"healthy"`
+	LastProbeTime *metav1.Time       `json:"lastProbeTime,omitempty"`
+	Message       string             `json:"message,omitempty"`
+	Conditions    []metav1.Condition `json:"conditions,omitempty"`
+	// Endpoints are the results of the individual endpoints when the check
+	// probes every endpoint of a Service.
+	Endpoints []EndpointStatus `json:"endpoints,omitempty"`
+	// Heartbeat reports the pings received by a heartbeat check.
+	Heartbeat *HeartbeatStatus `json:"heartbeat,omitempty"`
+	// LastDuration is how long the check took when the status was last
+	// written.
+	LastDuration *metav1.Duration `json:"lastDuration,omitempty"`
+	// LastFailure is the most recent failed check.
+	LastFailure *ProbeResult `json:"lastFailure,omitempty"`
+	// History lists the latest changes of Healthy, oldest first.
+	History []ProbeResult `json:"history,omitempty"`
+	// LastRunRequest is the value of the run-now annotation the last
+	// check run on request served.
+	LastRunRequest string `json:"lastRunRequest,omitempty"`
+	// NextProbeTime is when the next scheduled check is due.
+	NextProbeTime *metav1.Time `json:"nextProbeTime,omitempty"`
+	// Attempts is the number of attempts the last written check took.
+	Attempts int32 `json:"attempts,omitempty"`
+}
+
+// ProbeResult is the outcome of a check at a point in time.
+type ProbeResult struct {
+	Time    metav1.Time `json:"time"`
+	Healthy bool        `json:"healthy"`
+	Message string      `json:"message,omitempty"`
+}
+
+// HeartbeatStatus reports the pings received by a heartbeat check.
+type HeartbeatStatus struct {
+	LastPingTime    *metav1.Time `json:"lastPingTime,omitempty"`
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+	// RunDuration is the time between the start ping and the end of the
+	// last run.
+	RunDuration *metav1.Duration `json:"runDuration,omitempty"`
+}
+
+// EndpointStatus is the result of the check against one endpoint.
+type EndpointStatus struct {
+	Address string `json:"address"`
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ProbeList contains a list of Probe
+type ProbeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Probe `json:"items"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterProbe is the Schema for the cluster-scoped clusterprobes API.
+// It shares its spec and status with Probe and is meant for platform-owned
+// checks that do not belong to a tenant namespace.
+type ClusterProbe struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProbeSpec   `json:"spec,omitempty"`
+	Status ProbeStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ClusterProbeList contains a list of ClusterProbe
+type ClusterProbeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterProbe `json:"items"`
+}
@@ -0,0 +1,475 @@
+{{/*
+Served versions of the Probe and ClusterProbe CRDs, which share their schema.
+*/}}
+{{- define "heartbeat-operator.probeVersions" -}}
+- name: v1alpha1
+  served: true
+  storage: true
+  subresources:
+    status: {}
+  additionalPrinterColumns:
+    - name: Healthy
+      type: boolean
+      jsonPath: .status.healthy
+    - name: Message
+      type: string
+      jsonPath: .status.message
+    - name: Last Probe
+      type: date
+      jsonPath: .status.lastProbeTime
+  schema:
+    openAPIV3Schema:
+      type: object
+      properties:
+        spec:
+          type: object
+          properties:
+            # Any prober type registered with the operator, checked by the validating webhook.
+            checkType:
+              type: string
+            checkTarget:
+              type: string
+            options:
+              type: object
+              x-kubernetes-preserve-unknown-fields: true
+            interval:
+              type: string
+            timeout:
+              type: string
+            # Cron expressions separated by ";", replacing interval when set.
+            schedule:
+              type: string
+            timeZone:
+              type: string
+            dependsOn:
+              type: array
+              items:
+                type: string
+            suspend:
+              type: boolean
+            successThreshold:
+              type: integer
+              minimum: 1
+            failureThreshold:
+              type: integer
+              minimum: 1
+            retries:
+              type: object
+              required: ["count"]
+              properties:
+                count:
+                  type: integer
+                  format: int32
+                  minimum: 0
+                  maximum: 10
+                delay:
+                  type: string
+                backoff:
+                  type: string
+                  enum: ["Fixed", "Exponential"]
+                maxDelay:
+                  type: string
+        status:
+          type: object
+          properties:
+            healthy:
+              type: boolean
+            lastProbeTime:
+              type: string
+              format: date-time
+            message:
+              type: string
+            conditions:
+              type: array
+              items:
+                type: object
+                required: ["type", "status", "lastTransitionTime", "reason", "message"]
+                properties:
+                  type:
+                    type: string
+                  status:
+                    type: string
+                    enum: ["True", "False", "Unknown"]
+                  observedGeneration:
+                    type: integer
+                    format: int64
+                  lastTransitionTime:
+                    type: string
+                    format: date-time
+                  reason:
+                    type: string
+                  message:
+                    type: string
+            endpoints:
+              type: array
+              items:
+                type: object
+                required: ["address", "healthy"]
+                properties:
+                  address:
+                    type: string
+                  healthy:
+                    type: boolean
+                  message:
+                    type: string
+            heartbeat:
+              type: object
+              properties:
+                lastPingTime:
+                  type: string
+                  format: date-time
+                lastSuccessTime:
+                  type: string
+                  format: date-time
+                runDuration:
+                  type: string
+            lastDuration:
+              type: string
+            lastFailure:
+              type: object
+              required: ["time", "healthy"]
+              properties:
+                time:
+                  type: string
+                  format: date-time
+                healthy:
+                  type: boolean
+                message:
+                  type: string
+            history:
+              type: array
+              items:
+                type: object
+                required: ["time", "healthy"]
+                properties:
+                  time:
+                    type: string
+                    format: date-time
+                  healthy:
+                    type: boolean
+                  message:
+                    type: string
+            lastRunRequest:
+              type: string
+            nextProbeTime:
+              type: string
+              format: date-time
+            attempts:
+              type: integer
+              format: int32
+# v1beta1 needs the conversion webhook, it is only served when the webhook is enabled.
+- name: v1beta1
+  served: {{ .Values.webhook.enabled }}
+  storage: false
+  subresources:
+    status: {}
+  additionalPrinterColumns:
+    - name: Healthy
+      type: boolean
+      jsonPath: .status.healthy
+    - name: Message
+      type: string
+      jsonPath: .status.message
+    - name: Interval
+      type: string
+      jsonPath: .spec.interval
+    - name: Last Probe
+      type: date
+      jsonPath: .status.lastProbeTime
+  schema:
+    openAPIV3Schema:
+      type: object
+      properties:
+        spec:
+          type: object
+          anyOf:
+            - required: ["interval"]
+            - required: ["schedule"]
+          oneOf:
+            - required: ["http"]
+            - required: ["tcp"]
+            - required: ["exec"]
+            - required: ["dns"]
+            - required: ["kubernetes"]
+            - required: ["heartbeat"]
+            - required: ["cronJob"]
+            - required: ["plugin"]
+          properties:
+            http:
+              type: object
+              required: ["url"]
+              properties:
+                url:
+                  type: string
+                method:
+                  type: string
+                headers:
+                  type: object
+                  additionalProperties:
+                    type: string
+                expectedStatusCodes:
+                  type: array
+                  items:
+                    type: integer
+            tcp:
+              type: object
+              required: ["host", "port"]
+              properties:
+                host:
+                  type: string
+                port:
+                  type: integer
+                  minimum: 1
+                  maximum: 65535
+            exec:
+              type: object
+              required: ["command"]
+              properties:
+                command:
+                  type: array
+                  minItems: 1
+                  items:
+                    type: string
+                pod:
+                  type: object
+                  description: Runs the command in a container of another pod through pods/exec.
+                  properties:
+                    namespace:
+                      type: string
+                    name:
+                      type: string
+                    labelSelector:
+                      type: string
+                    container:
+                      type: string
+                job:
+                  type: object
+                  description: Runs the command in a Job of its own with its own image.
+                  required: ["image"]
+                  properties:
+                    image:
+                      type: string
+                    namespace:
+                      type: string
+                    serviceAccountName:
+                      type: string
+                    resources:
+                      type: object
+                      x-kubernetes-preserve-unknown-fields: true
+            dns:
+              type: object
+              required: ["name"]
+              properties:
+                name:
+                  type: string
+            kubernetes:
+              type: object
+              description: Reads objects through the API and expects a condition and field comparisons to hold.
+              required: ["resource"]
+              properties:
+                resource:
+                  type: string
+                  description: <resource>.<version>[.<group>], e.g. deployments.v1.apps.
+                namespace:
+                  type: string
+                name:
+                  type: string
+                labelSelector:
+                  type: string
+                condition:
+                  type: string
+                status:
+                  type: string
+                fields:
+                  type: array
+                  items:
+                    type: string
+            heartbeat:
+              type: object
+              description: Expects a job to ping the operator within the interval plus grace.
+              required: ["secretName"]
+              properties:
+                secretName:
+                  type: string
+                  description: Secret holding the ping token, created with a random token when missing.
+                grace:
+                  type: string
+            cronJob:
+              type: object
+              description: Watches the Jobs a CronJob creates.
+              required: ["name"]
+              properties:
+                namespace:
+                  type: string
+                name:
+                  type: string
+                tolerance:
+                  type: string
+                maxDuration:
+                  type: string
+            plugin:
+              type: object
+              description: Runs a check type served by a prober plugin, or any check type without a member of its own.
+              required: ["type", "target"]
+              properties:
+                type:
+                  type: string
+                target:
+                  type: string
+                options:
+                  type: object
+                  x-kubernetes-preserve-unknown-fields: true
+            endpoints:
+              type: object
+              properties:
+                policy:
+                  type: string
+                  enum: ["All", "Any", "Percentage"]
+                minHealthyPercent:
+                  type: integer
+                  minimum: 1
+                  maximum: 100
+            interval:
+              type: string
+            timeout:
+              type: string
+            # Cron expressions separated by ";", replacing interval when set.
+            schedule:
+              type: string
+            timeZone:
+              type: string
+            successThreshold:
+              type: integer
+              minimum: 1
+            failureThreshold:
+              type: integer
+              minimum: 1
+            dependsOn:
+              type: array
+              items:
+                type: string
+            suspend:
+              type: boolean
+            retries:
+              type: object
+              required: ["count"]
+              properties:
+                count:
+                  type: integer
+                  format: int32
+                  minimum: 0
+                  maximum: 10
+                delay:
+                  type: string
+                backoff:
+                  type: string
+                  enum: ["Fixed", "Exponential"]
+                maxDelay:
+                  type: string
+        status:
+          type: object
+          properties:
+            healthy:
+              type: boolean
+            lastProbeTime:
+              type: string
+              format: date-time
+            message:
+              type: string
+            conditions:
+              type: array
+              items:
+                type: object
+                required: ["type", "status", "lastTransitionTime", "reason", "message"]
+                properties:
+                  type:
+                    type: string
+                  status:
+                    type: string
+                    enum: ["True", "False", "Unknown"]
+                  observedGeneration:
+                    type: integer
+                    format: int64
+                  lastTransitionTime:
+                    type: string
+                    format: date-time
+                  reason:
+                    type: string
+                  message:
+                    type: string
+            endpoints:
+              type: array
+              items:
+                type: object
+                required: ["address", "healthy"]
+                properties:
+                  address:
+                    type: string
+                  healthy:
+                    type: boolean
+                  message:
+                    type: string
+            heartbeat:
+              type: object
+              properties:
+                lastPingTime:
+                  type: string
+                  format: date-time
+                lastSuccessTime:
+                  type: string
+                  format: date-time
+                runDuration:
+                  type: string
+            lastDuration:
+              type: string
+            lastFailure:
+              type: object
+              required: ["time", "healthy"]
+              properties:
+                time:
+                  type: string
+                  format: date-time
+                healthy:
+                  type: boolean
+                message:
+                  type: string
+            history:
+              type: array
+              items:
+                type: object
+                required: ["time", "healthy"]
+                properties:
+                  time:
+                    type: string
+                    format: date-time
+                  healthy:
+                    type: boolean
+                  message:
+                    type: string
+            lastRunRequest:
+              type: string
+            nextProbeTime:
+              type: string
+              format: date-time
+            attempts:
+              type: integer
+              format: int32
+{{- end }}
+
+{{/*
+Conversion settings of the probes.ready.io CRDs, pointing at the operator webhook.
+*/}}
+{{- define "heartbeat-operator.crdConversion" -}}
+{{- include "heartbeat-operator.webhookTLS" . -}}
+conversion:
+  strategy: Webhook
+  webhook:
+    conversionReviewVersions: ["v1"]
+    clientConfig:
+      caBundle: {{ .webhookTLS.ca }}
+      service:
+        name: {{ include "heartbeat-operator.fullname" . }}
+        namespace: {{ .Release.Namespace }}
+        path: /convert
+        port: 443
+{{- end }}
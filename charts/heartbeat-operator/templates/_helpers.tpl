@@ -60,3 +60,87 @@ Create the name of the service account to use
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
 {{- end }}
+
+{{/*
+Serving certificate of the webhook server, base64 encoded.
+It is generated once per render and stored on the root context so the CRD
+caBundle and the Secret agree. An existing Secret is reused on upgrades.
+*/}}
+{{- define "heartbeat-operator.webhookTLS" -}}
+{{- if not (hasKey $ "webhookTLS") }}
+{{- $secretName := printf "%s-webhook-tls" (include "heartbeat-operator.fullname" .) }}
+{{- $secret := lookup "v1" "Secret" .Release.Namespace $secretName }}
+{{- if $secret }}
+{{- $_ := set $ "webhookTLS" (dict "ca" (index $secret.data "ca.crt") "cert" (index $secret.data "tls.crt") "key" (index $secret.data "tls.key")) }}
+{{- else }}
+{{- $svc := include "heartbeat-operator.fullname" . }}
+{{- $altNames := list (printf "%s.%s.svc" $svc .Release.Namespace) (printf "%s.%s.svc.cluster.local" $svc .Release.Namespace) }}
+{{- $ca := genCA (printf "%s-webhook-ca" $svc) 3650 }}
+{{- $cert := genSignedCert (first $altNames) nil $altNames 3650 $ca }}
+{{- $_ := set $ "webhookTLS" (dict "ca" ($ca.Cert | b64enc) "cert" ($cert.Cert | b64enc) "key" ($cert.Key | b64enc)) }}
+{{- end }}
+{{- end }}
+{{- end }}
+
+{{/*
+Rules of the operator, in its ClusterRole or the Roles of the watched namespaces.
+*/}}
+{{- define "heartbeat-operator.rules" -}}
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get", "list", "watch"]
+- apiGroups: [""]
+  resources: ["pods/status"]
+  verbs: ["update", "patch"]
+- apiGroups: ["probes.ready.io"]
+  resources: ["probes", "clusterprobes"]
+  verbs: ["get", "list", "watch", "create", "update", "patch", "delete"]
+- apiGroups: ["probes.ready.io"]
+  resources: ["probes/status", "clusterprobes/status"]
+  verbs: ["get", "update", "patch"]
+- apiGroups: ["probes.ready.io"]
+  resources: ["probegroups", "maintenancewindows"]
+  verbs: ["get", "list", "watch"]
+- apiGroups: ["probes.ready.io"]
+  resources: ["probegroups/status", "maintenancewindows/status"]
+  verbs: ["get", "update", "patch"]
+- apiGroups: [""]
+  resources: ["services"]
+  verbs: ["get", "list", "watch"]
+{{- if .Values.discovery.enabled }}
+- apiGroups: ["networking.k8s.io"]
+  resources: ["ingresses"]
+  verbs: ["list", "watch"]
+- apiGroups: ["gateway.networking.k8s.io"]
+  resources: ["httproutes"]
+  verbs: ["list", "watch"]
+{{- end }}
+# Runs of cronjob checks
+- apiGroups: ["batch"]
+  resources: ["cronjobs", "jobs"]
+  verbs: ["get", "list"]
+{{- if .Values.rbac.podExec }}
+# Commands of exec checks in other pods
+- apiGroups: [""]
+  resources: ["pods/exec"]
+  verbs: ["create", "get"]
+{{- end }}
+{{- if .Values.execJobs.enabled }}
+# Jobs of exec checks and their output
+- apiGroups: ["batch"]
+  resources: ["jobs"]
+  verbs: ["create", "delete"]
+- apiGroups: [""]
+  resources: ["pods/log"]
+  verbs: ["get"]
+{{- end }}
+- apiGroups: ["discovery.k8s.io"]
+  resources: ["endpointslices"]
+  verbs: ["list"]
+- apiGroups: [""]
+  resources: ["events"]
+  verbs: ["create", "patch"]
+{{- with .Values.rbac.extraRules }}
+{{ toYaml . }}
+{{- end }}
+{{- end }}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"heartbeat-operator/internal/check"
+	"heartbeat-operator/internal/config"
+	"heartbeat-operator/internal/prober"
+
+	"k8s.io/client-go/dynamic"
+)
+
+// runCheck implements the check command: it runs the rules of a file once
+// and prints their results. It returns the exit code, 1 when a check failed
+// and 2 when the command could not run.
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	file := flags.String("f", "", "path of the gate rules file (required)")
+	only := flags.String("only", "", "comma-separated names of the rules to run, all when empty")
+	output := flags.String("o", check.FormatTable, "output format: table, json or junit")
+	kubeconfig := flags.String("kubeconfig", "", "path of a kubeconfig, for check types that need the Kubernetes API")
+	kubeContext := flags.String("context", "", "kubeconfig context to use")
+	verbose := flags.Bool("v", false, "log what the checks do to stderr")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s check -f rules.json [--only name] [-o table|json|junit]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" {
+		flags.Usage()
+		return 2
+	}
+	if err := check.ValidateFormat(*output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	rules, err := config.LoadRules(*file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config from %s: %v\n", *file, err)
+		return 2
+	}
+	var names []string
+	for _, name := range strings.Split(*only, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if rules, err = check.Select(rules, names); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	configureProbers()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startPlugins(ctx)
+
+	// Without a cluster, check types that need the API fail to build
+	if *kubeconfig != "" || *kubeContext != "" {
+		k8sConfig, err := kubeConfig(*kubeconfig, *kubeContext)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to get k8s config: %v\n", err)
+			return 2
+		}
+		dynamicClient, err := dynamic.NewForConfig(k8sConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create dynamic client: %v\n", err)
+			return 2
+		}
+		prober.SetKubernetesClient(dynamicClient)
+		if err := prober.SetRestConfig(k8sConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create pod exec client: %v\n", err)
+			return 2
+		}
+	}
+
+	results := check.Run(ctx, rules, newProber)
+	if err := check.Write(os.Stdout, *output, results); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if check.Failed(results) {
+		return 1
+	}
+	return 0
+}
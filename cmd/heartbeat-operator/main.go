@@ -2,65 +2,80 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"heartbeat-operator/internal/config"
 	"heartbeat-operator/internal/controller"
+	"heartbeat-operator/internal/heartbeat"
 	"heartbeat-operator/internal/prober"
 	"heartbeat-operator/internal/ui"
+	"heartbeat-operator/internal/webhook"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/record"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	configPath := os.Getenv("CONFIG_PATH")
-	if configPath == "" {
-		configPath = "/etc/config/gates.json"
+	// Exec checks with limits start this binary again to apply them
+	if len(os.Args) > 1 && os.Args[1] == prober.SandboxCommand {
+		if err := prober.RunSandbox(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", prober.SandboxCommand, err)
+			os.Exit(126)
+		}
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(runCheck(os.Args[2:]))
 	}
 
-	rules, err := config.LoadRules(configPath)
+	defaultConfigPath := os.Getenv("CONFIG_PATH")
+	if defaultConfigPath == "" {
+		defaultConfigPath = "/etc/config/gates.json"
+	}
+	configPath := flag.String("config", defaultConfigPath, "path of the gate rules file")
+	kubeconfig := flag.String("kubeconfig", "", "path of a kubeconfig, to run outside the cluster (default $KUBECONFIG or ~/.kube/config when not in a pod)")
+	kubeContext := flag.String("context", "", "kubeconfig context to use")
+	standalone := flag.Bool("standalone", false, "run the config file rules without Kubernetes, reporting to metrics, the UI and the log only")
+	flag.Parse()
+
+	rules, err := config.LoadRules(*configPath)
 	if err != nil {
-		log.Fatalf("Failed to load config from %s: %v", configPath, err)
+		log.Fatalf("Failed to load config from %s: %v", *configPath, err)
 	}
 	log.Printf("Loaded %d gate rules", len(rules))
 
-	k8sConfig, err := rest.InClusterConfig()
+	defaults, err := config.LoadDefaults()
 	if err != nil {
-		log.Fatalf("Failed to get k8s config: %v", err)
+		log.Fatalf("Invalid probe defaults: %v", err)
 	}
-	clientset, err := kubernetes.NewForConfig(k8sConfig)
+	scope, err := config.LoadScope()
 	if err != nil {
-		log.Fatalf("Failed to create k8s client: %v", err)
+		log.Fatalf("Invalid scope: %v", err)
 	}
+	rules = scopedRules(rules, scope)
 
-	log.Println("Initializing Event Broadcaster...")
-	eventBroadcaster := record.NewBroadcaster()
-
-	eventBroadcaster.StartStructuredLogging(0)
-
-	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
-		Interface: clientset.CoreV1().Events(""),
-	})
-	defer eventBroadcaster.Shutdown()
-
-	recorder := eventBroadcaster.NewRecorder(
-		scheme.Scheme,
-		corev1.EventSource{Component: "readiness-controller"},
-	)
-	// -------------------------------------------------------
+	configureProbers()
 
+	// Heartbeat checks are pinged through the UI port
+	ui.Handle("/ping/", heartbeat.Handler())
 	ui.Start("8080")
 
 	// Start Metrics Server
@@ -85,8 +100,76 @@ func main() {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+
+	// Start prober plugins before anything builds probers, so their check types are known
+	startPlugins(ctx)
+
+	if *standalone {
+		runStandalone(ctx, rules)
+		return
+	}
+
+	k8sConfig, err := kubeConfig(*kubeconfig, *kubeContext)
+	if err != nil {
+		log.Fatalf("Failed to get k8s config: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(k8sConfig)
+	if err != nil {
+		log.Fatalf("Failed to create k8s client: %v", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(k8sConfig)
+	if err != nil {
+		log.Fatalf("Failed to create dynamic client: %v", err)
+	}
+	prober.SetKubernetesClient(dynamicClient)
+	if err := prober.SetRestConfig(k8sConfig); err != nil {
+		log.Fatalf("Failed to create pod exec client: %v", err)
+	}
+
+	log.Println("Initializing Event Broadcaster...")
+	eventBroadcaster := record.NewBroadcaster()
+
+	eventBroadcaster.StartStructuredLogging(0)
+
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: clientset.CoreV1().Events(""),
+	})
+	defer eventBroadcaster.Shutdown()
+
+	recorder := eventBroadcaster.NewRecorder(
+		scheme.Scheme,
+		corev1.EventSource{Component: "readiness-controller"},
+	)
+	// -------------------------------------------------------
+
+	probeClient, err := controller.NewCrdClient(k8sConfig, metav1.NamespaceAll)
+	if err != nil {
+		log.Fatalf("Failed to create CRD client: %v", err)
+	}
+	clusterProbeClient, err := controller.NewClusterCrdClient(k8sConfig)
+	if err != nil {
+		log.Fatalf("Failed to create CRD client: %v", err)
+	}
+
+	// Start Webhook Server (conversion, defaulting, validation), only when the chart mounted a serving certificate
+	if certDir := os.Getenv("WEBHOOK_CERT_DIR"); certDir != "" {
+		webhookAddr := os.Getenv("WEBHOOK_ADDR")
+		if webhookAddr == "" {
+			webhookAddr = ":9443"
+		}
+		webhook.NewServer(defaults, controller.NewDependencyLookup(probeClient, clusterProbeClient)).Start(webhookAddr, certDir)
+	}
+
 	var wg sync.WaitGroup
 
+	// Run the Probes and ClusterProbes created through the API
+	watcher := controller.NewWatcher(clientset, probeClient, clusterProbeClient, scope, recorder, newProber, rules)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watcher.Run(ctx)
+	}()
+
 	for _, rule := range rules {
 		wg.Add(1)
 
@@ -95,36 +178,198 @@ func main() {
 		go func() {
 			defer wg.Done()
 
-			var p prober.Prober
-			switch r.CheckType {
-			case "http":
-				p = prober.NewHttpProber(r.CheckTarget)
-			case "tcp":
-				p = prober.NewTcpProber(r.CheckTarget)
-			case "exec":
-				p = prober.NewExecProber(r.CheckTarget)
-			default:
-				log.Printf("[%s] Unknown CheckType '%s', skipping rule", r.Name, r.CheckType)
+			p, err := newProber(r)
+			if err != nil {
+				log.Printf("[%s] %v, skipping rule", r.Name, err)
 				return
 			}
 
 			// -------------------------------------------------------
 			// Initialize CRD Client
-			crdClient, err := controller.NewCrdClient(k8sConfig, r.Namespace)
+			var crdClient controller.ProbeClient
+			if r.Kind == config.KindClusterProbe {
+				crdClient, err = controller.NewClusterCrdClient(k8sConfig)
+			} else {
+				crdClient, err = controller.NewCrdClient(k8sConfig, r.Namespace)
+			}
 			if err != nil {
 				log.Printf("Failed to create CRD client: %v", err)
 				return
 			}
 
 			ctrl := controller.New(clientset, crdClient, r, p, recorder)
+			watcher.AddConfigController(r, ctrl)
 			ctrl.Start(ctx)
 		}()
 	}
 
+	// Aggregate member Probes into ProbeGroup status
+	groupClient, err := controller.NewGroupClient(k8sConfig)
+	if err != nil {
+		log.Fatalf("Failed to create CRD client: %v", err)
+	}
+	groups := controller.NewGroupController(groupClient, probeClient, scope, 10*time.Second)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		groups.Start(ctx)
+	}()
+
+	// Hold off the Probes covered by a MaintenanceWindow
+	maintenanceClient, err := controller.NewMaintenanceClient(k8sConfig)
+	if err != nil {
+		log.Fatalf("Failed to create CRD client: %v", err)
+	}
+	windows := controller.NewMaintenanceController(maintenanceClient, probeClient, scope, 10*time.Second)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		windows.Start(ctx)
+	}()
+
+	// Create Probes for annotated Services, Ingresses and HTTPRoutes
+	if resources := os.Getenv("DISCOVERY"); resources != "" {
+		var kinds []string
+		for _, r := range strings.Split(resources, ",") {
+			kinds = append(kinds, strings.TrimSpace(r))
+		}
+		discovery, err := controller.NewDiscoveryController(dynamicClient, clientset.Discovery(), probeClient, scope, defaults, recorder, kinds)
+		if err != nil {
+			log.Fatalf("Invalid DISCOVERY: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			discovery.Run(ctx)
+		}()
+	}
+
+	waitForSignal()
+	log.Println("Shutting down...")
+	cancel()
+	wg.Wait()
+}
+
+// configureProbers applies the limits and policies of the environment to
+// the check types.
+func configureProbers() {
+	if v := os.Getenv("MAX_EXEC_JOBS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("Invalid MAX_EXEC_JOBS %q: must be a positive number", v)
+		}
+		prober.SetMaxExecJobs(n)
+	}
+	execPolicy, err := config.LoadExecPolicy()
+	if err != nil {
+		log.Fatalf("Invalid exec policy: %v", err)
+	}
+	if err := prober.SetExecPolicy(execPolicy); err != nil {
+		log.Fatalf("Invalid exec policy: %v", err)
+	}
+	if path := os.Getenv("TARGET_POLICY_PATH"); path != "" {
+		targetPolicy, err := config.LoadTargetPolicy(path)
+		if err != nil {
+			log.Fatalf("Failed to load target policy: %v", err)
+		}
+		if err := prober.SetTargetPolicy(targetPolicy); err != nil {
+			log.Fatalf("Invalid target policy: %v", err)
+		}
+	}
+}
+
+// startPlugins starts the prober plugins listed in PLUGINS.
+func startPlugins(ctx context.Context) {
+	if plugins := os.Getenv("PLUGINS"); plugins != "" {
+		for _, endpoint := range strings.Split(plugins, ",") {
+			endpoint = strings.TrimSpace(endpoint)
+			types, err := prober.StartPlugin(ctx, endpoint)
+			if err != nil {
+				log.Fatalf("Failed to start plugin %s: %v", endpoint, err)
+			}
+			log.Printf("Started plugin %s serving %v", endpoint, types)
+		}
+	}
+}
+
+func waitForSignal() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	<-stop
+}
+
+// kubeConfig returns the in-cluster config inside a pod, otherwise the one
+// of a kubeconfig: path, else $KUBECONFIG or ~/.kube/config, with context
+// selected when set.
+func kubeConfig(path, context string) (*rest.Config, error) {
+	if path == "" && context == "" {
+		if cfg, err := rest.InClusterConfig(); err == nil {
+			return cfg, nil
+		}
+	}
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = path
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+}
+
+// runStandalone runs the config file rules without Kubernetes until a
+// signal. Their CRs are kept in memory and their events logged, results
+// are reported through metrics and the UI. Check types that need the API
+// fail to build and their rules are skipped.
+func runStandalone(ctx context.Context, rules []config.GateRule) {
+	log.Println("Running standalone, without Kubernetes")
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for _, rule := range rules {
+		r := rule
+		p, err := newProber(r)
+		if err != nil {
+			log.Printf("[%s] %v, skipping rule", r.Name, err)
+			continue
+		}
+		ctrl := controller.New(nil, controller.NewMemoryClient(), r, p, controller.LogRecorder{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctrl.Start(ctx)
+		}()
+	}
+
+	waitForSignal()
 	log.Println("Shutting down...")
 	cancel()
 	wg.Wait()
 }
+
+// scopedRules drops the rules outside the namespaces of scope and labels the
+// others with its selector, so their CRs belong to this instance.
+func scopedRules(rules []config.GateRule, scope config.Scope) []config.GateRule {
+	var out []config.GateRule
+	for _, r := range rules {
+		ns := r.Namespace
+		if r.Kind == config.KindClusterProbe {
+			ns = ""
+		}
+		if !scope.Watches(ns) {
+			log.Printf("[%s] %s is outside the watched namespaces, skipping rule", r.Name, r.Key())
+			continue
+		}
+		r.Labels = scope.AddLabels(r.Labels)
+		out = append(out, r)
+	}
+	return out
+}
+
+// newProber builds the prober of a rule from the check types registered
+// with the prober package.
+func newProber(r config.GateRule) (prober.Prober, error) {
+	probe := prober.ProbeInfo{Name: r.Name, Interval: config.ParseInterval(r.Interval), Schedule: r.Schedule}
+	if timeout, err := time.ParseDuration(r.Timeout); err == nil {
+		probe.Timeout = timeout
+	}
+	if r.Kind != config.KindClusterProbe {
+		probe.Namespace = r.Namespace
+	}
+	return prober.NewFor(probe, r.CheckType, r.CheckTarget, r.Options)
+}
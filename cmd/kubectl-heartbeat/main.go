@@ -0,0 +1,257 @@
+// kubectl-heartbeat is a kubectl plugin for Probes: installed on the PATH
+// it runs as "kubectl heartbeat".
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/internal/controller"
+	"heartbeat-operator/internal/kubectl"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
+)
+
+const usage = `Inspect and trigger the Probes of the heartbeat operator.
+
+Usage:
+  kubectl heartbeat list [-n namespace]       status, latency and streak of the Probes of all namespaces
+  kubectl heartbeat describe <probe>          status, last failure and recent history of a Probe
+  kubectl heartbeat run <probe> [--timeout]   check a Probe now and wait for the result
+  kubectl heartbeat watch [-n namespace]      print Probes as they pass and fail
+
+Flags:
+`
+
+type options struct {
+	kubeconfig string
+	context    string
+	namespace  string
+	selector   string
+	timeout    time.Duration
+}
+
+func main() {
+	var opts options
+	flags := flag.NewFlagSet("kubectl heartbeat", flag.ExitOnError)
+	flags.StringVar(&opts.kubeconfig, "kubeconfig", "", "path of the kubeconfig file")
+	flags.StringVar(&opts.context, "context", "", "kubeconfig context to use")
+	flags.StringVar(&opts.namespace, "namespace", "", "namespace of the Probes, all for list and watch when empty")
+	flags.StringVar(&opts.namespace, "n", "", "shorthand for --namespace")
+	flags.StringVar(&opts.selector, "l", "", "label selector of the Probes for list and watch")
+	flags.DurationVar(&opts.timeout, "timeout", 2*time.Minute, "how long run waits for the result")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	args := parseInterspersed(flags, os.Args[1:])
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var err error
+	switch cmd := args[0]; {
+	case cmd == "list" && len(args) == 1:
+		err = list(ctx, opts)
+	case cmd == "describe" && len(args) == 2:
+		err = describe(ctx, opts, args[1])
+	case cmd == "run" && len(args) == 2:
+		err = run(ctx, opts, args[1])
+	case cmd == "watch" && len(args) == 1:
+		err = watchProbes(ctx, opts)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// parseInterspersed parses flags before and after the command and its
+// arguments, as kubectl does, and returns the arguments.
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var rest []string
+	for {
+		_ = flags.Parse(args)
+		if flags.NArg() == 0 {
+			return rest
+		}
+		rest = append(rest, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+// client returns a Probe client for the namespace of the flags. With
+// allByDefault it is all namespaces when none is set, otherwise that of
+// the kubeconfig context.
+func client(opts options, allByDefault bool) (*controller.CrdClient, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = opts.kubeconfig
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: opts.context})
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	namespace := opts.namespace
+	if namespace == "" && !allByDefault {
+		if namespace, _, err = config.Namespace(); err != nil {
+			return nil, err
+		}
+	}
+	if namespace == "" {
+		namespace = metav1.NamespaceAll
+	}
+	return controller.NewCrdClient(restConfig, namespace)
+}
+
+func list(ctx context.Context, opts options) error {
+	probes, err := client(opts, true)
+	if err != nil {
+		return err
+	}
+	selector, err := labels.Parse(opts.selector)
+	if err != nil {
+		return err
+	}
+	result, err := probes.List(ctx, selector)
+	if err != nil {
+		return err
+	}
+	if len(result.Items) == 0 {
+		fmt.Fprintln(os.Stderr, "No Probes found.")
+		return nil
+	}
+	return kubectl.PrintList(os.Stdout, result.Items, time.Now())
+}
+
+func describe(ctx context.Context, opts options, name string) error {
+	probes, err := client(opts, false)
+	if err != nil {
+		return err
+	}
+	p, err := probes.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	return kubectl.PrintDescribe(os.Stdout, p, time.Now())
+}
+
+// run sets the run-now annotation of a Probe and waits until the status
+// records the check that served it. It fails when the check does.
+func run(ctx context.Context, opts options, name string) error {
+	probes, err := client(opts, false)
+	if err != nil {
+		return err
+	}
+	id := time.Now().UTC().Format(time.RFC3339Nano)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		p, err := probes.Get(ctx, name)
+		if err != nil {
+			return err
+		}
+		if p.Annotations == nil {
+			p.Annotations = map[string]string{}
+		}
+		p.Annotations[v1alpha1.AnnotationRunNow] = id
+		_, err = probes.Update(ctx, p)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Requested a check of %s as %s, waiting for the result...\n", name, id)
+
+	var p *v1alpha1.Probe
+	err = wait.PollUntilContextTimeout(ctx, time.Second, opts.timeout, false, func(ctx context.Context) (bool, error) {
+		p, err = probes.Get(ctx, name)
+		if err != nil {
+			return false, err
+		}
+		return p.Status.LastRunRequest == id, nil
+	})
+	if err != nil {
+		return fmt.Errorf("no result for %s: %v", name, err)
+	}
+	if err := kubectl.PrintDescribe(os.Stdout, p, time.Now()); err != nil {
+		return err
+	}
+	if !p.Status.Healthy {
+		return fmt.Errorf("check of %s failed", name)
+	}
+	return nil
+}
+
+// watchProbes prints a line whenever a Probe starts passing or failing,
+// until interrupted.
+func watchProbes(ctx context.Context, opts options) error {
+	probes, err := client(opts, true)
+	if err != nil {
+		return err
+	}
+	selector, err := labels.Parse(opts.selector)
+	if err != nil {
+		return err
+	}
+	lw := probes.ListWatch(selector.String())
+
+	seen := map[string]*v1alpha1.Probe{}
+	for ctx.Err() == nil {
+		// (Re)list to pick up changes missed while the watch was down
+		result, err := probes.List(ctx, selector)
+		if err != nil {
+			return err
+		}
+		for i := range result.Items {
+			p := &result.Items[i]
+			if old, ok := seen[key(p)]; ok {
+				if line, changed := kubectl.Transition(old, p, time.Now()); changed {
+					fmt.Println(line)
+				}
+			}
+			seen[key(p)] = p
+		}
+
+		w, err := lw.WatchWithContext(ctx, metav1.ListOptions{ResourceVersion: result.ResourceVersion})
+		if err != nil {
+			return err
+		}
+		for ev := range w.ResultChan() {
+			p, ok := ev.Object.(*v1alpha1.Probe)
+			if !ok {
+				continue
+			}
+			switch ev.Type {
+			case watch.Added, watch.Modified:
+				if line, changed := kubectl.Transition(seen[key(p)], p, time.Now()); changed {
+					fmt.Println(line)
+				}
+				seen[key(p)] = p
+			case watch.Deleted:
+				delete(seen, key(p))
+			}
+		}
+		w.Stop()
+	}
+	return nil
+}
+
+func key(p *v1alpha1.Probe) string {
+	return p.Namespace + "/" + p.Name
+}
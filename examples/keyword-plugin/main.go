@@ -0,0 +1,65 @@
+// Command keyword-plugin is an example prober plugin. Its http-keyword
+// check fetches a URL and expects the body to contain a keyword:
+//
+//	checkType: http-keyword
+//	checkTarget: https://status.example.com
+//	options:
+//	  keyword: "All systems operational"
+//
+// Run the operator with PLUGINS=/plugins/keyword-plugin to use it.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"heartbeat-operator/pkg/plugin"
+)
+
+type keywordOptions struct {
+	Keyword string `json:"keyword"`
+}
+
+func checkKeyword(ctx context.Context, target string, options json.RawMessage) (bool, string) {
+	var opts keywordOptions
+	if len(options) > 0 {
+		if err := json.Unmarshal(options, &opts); err != nil {
+			return false, fmt.Sprintf("invalid options: %v", err)
+		}
+	}
+	if opts.Keyword == "" {
+		return false, "options.keyword is required"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false, err.Error()
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, err.Error()
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false, fmt.Sprintf("status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return false, err.Error()
+	}
+	if !strings.Contains(string(body), opts.Keyword) {
+		return false, fmt.Sprintf("%q not found in response", opts.Keyword)
+	}
+	return true, fmt.Sprintf("found %q", opts.Keyword)
+}
+
+func main() {
+	plugin.Main(plugin.Checks{
+		"http-keyword": checkKeyword,
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"heartbeat-operator/pkg/plugin/plugintest"
+)
+
+// The test binary doubles as the plugin when started by the conformance suite.
+func TestMain(m *testing.M) {
+	if os.Getenv("KEYWORD_PLUGIN_SERVE") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestConformance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "All systems operational")
+	}))
+	defer server.Close()
+
+	t.Setenv("KEYWORD_PLUGIN_SERVE", "1")
+	keyword := json.RawMessage(`{"keyword": "operational"}`)
+	plugintest.Conformance(t, []string{os.Args[0]}, []plugintest.Case{
+		{CheckType: "http-keyword", Target: server.URL, Options: keyword, Healthy: true},
+		{CheckType: "http-keyword", Target: server.URL, Options: json.RawMessage(`{"keyword": "degraded"}`), Healthy: false},
+		{CheckType: "http-keyword", Target: server.URL + "/down", Options: keyword, Healthy: false},
+		{CheckType: "http-keyword", Target: server.URL, Healthy: false},
+	})
+}
@@ -0,0 +1,210 @@
+// Package check runs gate rules once and reports their results, for the
+// check command used in CI pipelines and smoke tests.
+package check
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"heartbeat-operator/internal/config"
+	"heartbeat-operator/internal/prober"
+)
+
+// Output formats of Write.
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+)
+
+// Result is the outcome of one rule.
+type Result struct {
+	Name      string  `json:"name"`
+	Namespace string  `json:"namespace,omitempty"`
+	Kind      string  `json:"kind"`
+	CheckType string  `json:"checkType"`
+	Target    string  `json:"checkTarget"`
+	Healthy   bool    `json:"healthy"`
+	Message   string  `json:"message"`
+	Duration  float64 `json:"durationSeconds"`
+	Attempts  int     `json:"attempts"`
+}
+
+// Select returns the rules named in only, by name or by key, all rules
+// when only is empty. A name matching no rule is an error.
+func Select(rules []config.GateRule, only []string) ([]config.GateRule, error) {
+	if len(only) == 0 {
+		return rules, nil
+	}
+	var out []config.GateRule
+	for _, name := range only {
+		found := false
+		for _, r := range rules {
+			if r.Name == name || r.Key() == name {
+				out = append(out, r)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no rule named %s", name)
+		}
+	}
+	return out, nil
+}
+
+// Run checks every rule once, concurrently, and returns the results in the
+// order of rules. A rule whose prober cannot be built fails with the error.
+func Run(ctx context.Context, rules []config.GateRule, newProber func(config.GateRule) (prober.Prober, error)) []Result {
+	results := make([]Result, len(rules))
+	var wg sync.WaitGroup
+	for i, r := range rules {
+		results[i] = Result{Name: r.Name, Kind: r.Kind, CheckType: r.CheckType, Target: r.CheckTarget}
+		if r.Kind != config.KindClusterProbe {
+			results[i].Namespace = r.Namespace
+		}
+		wg.Add(1)
+		go func(res *Result, r config.GateRule) {
+			defer wg.Done()
+			start := time.Now()
+			p, err := newProber(r)
+			if err != nil {
+				res.Message = err.Error()
+				return
+			}
+			if sp, ok := p.(prober.StoppingProber); ok {
+				defer sp.Stop()
+			}
+			out := prober.RunWithRetry(ctx, p, r.Retry())
+			res.Healthy, res.Message, res.Attempts = out.Healthy, out.Message, out.Attempts
+			res.Duration = time.Since(start).Seconds()
+		}(&results[i], r)
+	}
+	wg.Wait()
+	return results
+}
+
+// Failed tells whether any result is unhealthy.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if !r.Healthy {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateFormat returns an error when Write does not know format.
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatTable, FormatJSON, FormatJUnit:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q, want %s, %s or %s", format, FormatTable, FormatJSON, FormatJUnit)
+}
+
+// Write prints results to w in format.
+func Write(w io.Writer, format string, results []Result) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	case FormatJUnit:
+		return writeJUnit(w, results)
+	case FormatTable:
+		return writeTable(w, results)
+	}
+	return ValidateFormat(format)
+}
+
+func writeTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tNAMESPACE\tTYPE\tTARGET\tRESULT\tDURATION\tMESSAGE")
+	for _, r := range results {
+		status := "PASS"
+		if !r.Healthy {
+			status = "FAIL"
+		}
+		ns := r.Namespace
+		if ns == "" {
+			ns = "-"
+		}
+		duration := time.Duration(r.Duration * float64(time.Second)).Round(time.Millisecond)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Name, ns, r.CheckType, r.Target, status, duration, oneLine(r.Message))
+	}
+	return tw.Flush()
+}
+
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func writeJUnit(w io.Writer, results []Result) error {
+	suite := junitSuite{Name: "heartbeat-operator", Tests: len(results)}
+	var total float64
+	for _, r := range results {
+		total += r.Duration
+		class := r.Namespace
+		if class == "" {
+			class = r.Kind
+		}
+		tc := junitCase{ClassName: class, Name: r.Name, Time: seconds(r.Duration)}
+		if r.Healthy {
+			tc.SystemOut = r.Message
+		} else {
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: oneLine(r.Message),
+				Type:    r.CheckType,
+				Text:    fmt.Sprintf("%s check of %s failed: %s", r.CheckType, r.Target, r.Message),
+			}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(s float64) string {
+	return fmt.Sprintf("%.3f", s)
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
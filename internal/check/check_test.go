@@ -0,0 +1,104 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"heartbeat-operator/internal/config"
+	"heartbeat-operator/internal/prober"
+)
+
+type stubProber struct{ healthy bool }
+
+func (p stubProber) Check() bool { return p.healthy }
+
+var rules = []config.GateRule{
+	{Name: "web", Namespace: "shop", Kind: config.KindProbe, CheckType: "http", CheckTarget: "http://web"},
+	{Name: "db", Namespace: "shop", Kind: config.KindProbe, CheckType: "tcp", CheckTarget: "db:5432"},
+	{Name: "dns", Namespace: "ignored", Kind: config.KindClusterProbe, CheckType: "dns", CheckTarget: "example.com"},
+}
+
+func newStub(r config.GateRule) (prober.Prober, error) {
+	switch r.CheckType {
+	case "http":
+		return stubProber{healthy: true}, nil
+	case "tcp":
+		return stubProber{healthy: false}, nil
+	}
+	return nil, errors.New("unknown CheckType 'dns'")
+}
+
+func TestSelect(t *testing.T) {
+	got, err := Select(rules, []string{"db", "ClusterProbe/dns"})
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "db" || got[1].Name != "dns" {
+		t.Errorf("Select = %v, want db and dns", got)
+	}
+	if got, _ := Select(rules, nil); len(got) != len(rules) {
+		t.Errorf("Select without names returned %d rules, want all", len(got))
+	}
+	if _, err := Select(rules, []string{"nope"}); err == nil {
+		t.Error("Select of an unknown rule succeeded")
+	}
+}
+
+func TestRun(t *testing.T) {
+	results := Run(context.Background(), rules, newStub)
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if !results[0].Healthy || results[1].Healthy || results[2].Healthy {
+		t.Errorf("healthy = %v %v %v, want true false false", results[0].Healthy, results[1].Healthy, results[2].Healthy)
+	}
+	if results[2].Message != "unknown CheckType 'dns'" || results[2].Namespace != "" {
+		t.Errorf("result of the unbuildable rule = %+v", results[2])
+	}
+	if !Failed(results) || Failed(results[:1]) {
+		t.Error("Failed does not report the failing check")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	results := Run(context.Background(), rules, newStub)
+
+	var table bytes.Buffer
+	if err := Write(&table, FormatTable, results); err != nil {
+		t.Fatalf("table: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[1], "PASS") || !strings.Contains(lines[2], "FAIL") {
+		t.Errorf("table:\n%s", table.String())
+	}
+
+	var js bytes.Buffer
+	if err := Write(&js, FormatJSON, results); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	var decoded []Result
+	if err := json.Unmarshal(js.Bytes(), &decoded); err != nil || len(decoded) != 3 || decoded[1].Target != "db:5432" {
+		t.Errorf("json = %s (%v)", js.String(), err)
+	}
+
+	var junit bytes.Buffer
+	if err := Write(&junit, FormatJUnit, results); err != nil {
+		t.Fatalf("junit: %v", err)
+	}
+	var suite junitSuite
+	if err := xml.Unmarshal(junit.Bytes(), &suite); err != nil {
+		t.Fatalf("junit does not parse: %v", err)
+	}
+	if suite.Tests != 3 || suite.Failures != 2 || suite.Cases[0].Failure != nil || suite.Cases[1].Failure == nil {
+		t.Errorf("junit = %s", junit.String())
+	}
+
+	if err := Write(&js, "yaml", results); err == nil {
+		t.Error("Write of an unknown format succeeded")
+	}
+}
@@ -2,20 +2,60 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 	"time"
+
+	"heartbeat-operator/internal/prober"
+)
+
+// Kinds of custom resource a rule is reported through.
+const (
+	KindProbe        = "Probe"
+	KindClusterProbe = "ClusterProbe"
 )
 
 type GateRule struct {
 	Name        string `json:"name"`        // Unique ID for the rule
 	GateName    string `json:"gateName"`    // The string injected into Pods
 	TargetLabel string `json:"targetLabel"` // Which pods to gate
-	Namespace   string `json:"namespace"`   // Namespace of those pods
-	CheckType   string `json:"checkType"`   // "http", "tcp", "exec"
+	Namespace   string `json:"namespace"`   // Namespace of those pods, empty for ClusterProbe
+	CheckType   string `json:"checkType"`   // A registered prober type: "http", "tcp", "exec", "dns", ...
 	CheckTarget string `json:"checkTarget"` // URL or Address
 	Interval    string `json:"interval"`    // "5s", "10s"
+	Timeout     string `json:"timeout"`     // Optional, bounds a single check, 2s when empty
+	Schedule    string `json:"schedule"`    // Optional cron expressions separated by ";", replacing Interval
+	TimeZone    string `json:"timeZone"`    // Of Schedule, UTC when empty
+	Kind        string `json:"kind"`        // "Probe" (default) or "ClusterProbe"
+
+	// Labels are set on the Probe CR so ProbeGroups can select it.
+	Labels map[string]string `json:"labels"`
+	// DependsOn lists the rules this one relies on, see DependencyKey.
+	DependsOn []string `json:"dependsOn"`
+	// Options are decoded by the prober type of CheckType.
+	Options json.RawMessage `json:"options"`
+	// Suspend stops the checks of the rule.
+	Suspend bool `json:"suspend"`
+	// Retries repeats a failed check before it counts as failed.
+	Retries *Retries `json:"retries"`
+	// SuccessThreshold and FailureThreshold are the consecutive results
+	// that change the health of the rule, 1 when unset.
+	SuccessThreshold int32 `json:"successThreshold"`
+	FailureThreshold int32 `json:"failureThreshold"`
+}
+
+// Retries of a failed check within one execution of a rule.
+type Retries struct {
+	Count    int32  `json:"count"`    // Attempts after the first one
+	Delay    string `json:"delay"`    // Before the first retry, 1s when empty
+	Backoff  string `json:"backoff"`  // "Fixed" (default) or "Exponential", doubling the delay
+	MaxDelay string `json:"maxDelay"` // Caps exponential delays
 }
 
+// DefaultRetryDelay is the wait before the first retry when none is set.
+const DefaultRetryDelay = time.Second
+
 func LoadRules(path string) ([]GateRule, error) {
 	file, err := os.ReadFile(path)
 	if err != nil {
@@ -26,9 +66,71 @@ func LoadRules(path string) ([]GateRule, error) {
 	if err := json.Unmarshal(file, &rules); err != nil {
 		return nil, err
 	}
+	for i := range rules {
+		if rules[i].Kind == "" {
+			rules[i].Kind = KindProbe
+		}
+	}
+
+	deps := make(map[string][]string, len(rules))
+	for _, r := range rules {
+		deps[r.Key()] = r.DependencyKeys()
+	}
+	for _, r := range rules {
+		cycle, _ := FindCycle(r.Key(), func(key string) ([]string, error) { return deps[key], nil })
+		if cycle != nil {
+			return nil, fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
 	return rules, nil
 }
 
+// Key identifies the custom resource of a rule across kinds and namespaces.
+func (r GateRule) Key() string {
+	if r.Kind == KindClusterProbe {
+		return r.Kind + "/" + r.Name
+	}
+	return r.Kind + "/" + r.Namespace + "/" + r.Name
+}
+
+// DependencyKeys returns the Keys of the rules listed in DependsOn.
+func (r GateRule) DependencyKeys() []string {
+	keys := make([]string, 0, len(r.DependsOn))
+	for _, dep := range r.DependsOn {
+		keys = append(keys, DependencyKey(r.Kind, r.Namespace, dep))
+	}
+	return keys
+}
+
+// Retry returns the retry policy of the rule for prober.RunWithRetry. The
+// attempts fit in the timeout of the rule, or its interval when it has
+// none; a scheduled rule without timeout gets a minute.
+func (r GateRule) Retry() prober.Retry {
+	if r.Retries == nil || r.Retries.Count <= 0 {
+		return prober.Retry{}
+	}
+	retry := prober.Retry{
+		Count:       int(r.Retries.Count),
+		Delay:       DefaultRetryDelay,
+		Exponential: r.Retries.Backoff == "Exponential",
+	}
+	if d, err := time.ParseDuration(r.Retries.Delay); err == nil && d >= 0 {
+		retry.Delay = d
+	}
+	if d, err := time.ParseDuration(r.Retries.MaxDelay); err == nil && d > 0 {
+		retry.MaxDelay = d
+	}
+	switch timeout, err := time.ParseDuration(r.Timeout); {
+	case err == nil && timeout > 0:
+		retry.Budget = timeout
+	case r.Schedule != "":
+		retry.Budget = time.Minute
+	default:
+		retry.Budget = ParseInterval(r.Interval)
+	}
+	return retry
+}
+
 func ParseInterval(durationStr string) time.Duration {
 	d, err := time.ParseDuration(durationStr)
 	if err != nil {
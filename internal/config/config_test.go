@@ -1,8 +1,15 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"heartbeat-operator/internal/prober"
+
+	k8slabels "k8s.io/apimachinery/pkg/labels"
 )
 
 func TestParseInterval(t *testing.T) {
@@ -23,3 +30,227 @@ func TestParseInterval(t *testing.T) {
 		}
 	}
 }
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		rule GateRule
+		want prober.Retry
+	}{
+		{"none", GateRule{Interval: "10s"}, prober.Retry{}},
+		{"defaults within the interval", GateRule{Interval: "10s", Retries: &Retries{Count: 2}},
+			prober.Retry{Count: 2, Delay: time.Second, Budget: 10 * time.Second}},
+		{"exponential within the timeout", GateRule{Interval: "30s", Timeout: "5s", Retries: &Retries{Count: 3, Delay: "200ms", Backoff: "Exponential", MaxDelay: "1s"}},
+			prober.Retry{Count: 3, Delay: 200 * time.Millisecond, Exponential: true, MaxDelay: time.Second, Budget: 5 * time.Second}},
+		{"scheduled", GateRule{Schedule: "@hourly", Retries: &Retries{Count: 1, Delay: "5s"}},
+			prober.Retry{Count: 1, Delay: 5 * time.Second, Budget: time.Minute}},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.Retry(); got != tt.want {
+			t.Errorf("%s: Retry() = %+v; want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("DEFAULT_INTERVAL", "1m")
+	t.Setenv("DEFAULT_FAILURE_THRESHOLD", "3")
+
+	d, err := LoadDefaults()
+	if err != nil {
+		t.Fatalf("LoadDefaults() error: %v", err)
+	}
+	if d.Interval != time.Minute {
+		t.Errorf("Interval = %v; want 1m", d.Interval)
+	}
+	if d.FailureThreshold != 3 {
+		t.Errorf("FailureThreshold = %d; want 3", d.FailureThreshold)
+	}
+	if d.Timeout != 2*time.Second {
+		t.Errorf("Timeout = %v; want built-in 2s", d.Timeout)
+	}
+
+	t.Setenv("DEFAULT_TIMEOUT", "banana")
+	if _, err := LoadDefaults(); err == nil {
+		t.Errorf("expected error for malformed DEFAULT_TIMEOUT")
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gates.json")
+	data := `[
+		{"name": "api", "namespace": "default", "checkType": "http", "checkTarget": "http://api", "interval": "5s"},
+		{"name": "coredns", "kind": "ClusterProbe", "checkType": "dns", "checkTarget": "kubernetes.default", "interval": "30s"}
+	]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("failed to write rules: %v", err)
+	}
+
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatalf("LoadRules() error: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(rules))
+	}
+	if got := rules[0].Key(); got != "Probe/default/api" {
+		t.Errorf("rules[0].Key() = %q; want Probe/default/api", got)
+	}
+	if got := rules[1].Key(); got != "ClusterProbe/coredns" {
+		t.Errorf("rules[1].Key() = %q; want ClusterProbe/coredns", got)
+	}
+}
+
+func TestLoadRulesDependencyCycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gates.json")
+	data := `[
+		{"name": "a", "namespace": "default", "checkType": "tcp", "checkTarget": "a:80", "interval": "5s", "dependsOn": ["b"]},
+		{"name": "b", "namespace": "default", "checkType": "tcp", "checkTarget": "b:80", "interval": "5s", "dependsOn": ["a"]}
+	]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("failed to write rules: %v", err)
+	}
+
+	if _, err := LoadRules(path); err == nil {
+		t.Errorf("expected error for dependency cycle")
+	}
+}
+
+func TestDependencyKey(t *testing.T) {
+	tests := []struct {
+		kind, namespace, dep string
+		expected             string
+	}{
+		{KindProbe, "shop", "db", "Probe/shop/db"},
+		{KindProbe, "shop", "ClusterProbe/egress", "ClusterProbe/egress"},
+		{KindClusterProbe, "", "coredns", "ClusterProbe/coredns"},
+	}
+
+	for _, tt := range tests {
+		if got := DependencyKey(tt.kind, tt.namespace, tt.dep); got != tt.expected {
+			t.Errorf("DependencyKey(%q, %q, %q) = %q; want %q", tt.kind, tt.namespace, tt.dep, got, tt.expected)
+		}
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	graph := map[string][]string{
+		"a": {"b", "c"},
+		"b": {"d"},
+		"c": {"d"},
+		"d": {"a"},
+		"e": {"d"},
+	}
+	parents := func(key string) ([]string, error) { return graph[key], nil }
+
+	cycle, err := FindCycle("a", parents)
+	if err != nil {
+		t.Fatalf("FindCycle() error: %v", err)
+	}
+	if got := strings.Join(cycle, " -> "); got != "a -> b -> d -> a" {
+		t.Errorf("FindCycle(a) = %q; want a -> b -> d -> a", got)
+	}
+
+	// e reaches the cycle but is not part of it
+	if cycle, _ := FindCycle("e", parents); cycle != nil {
+		t.Errorf("FindCycle(e) = %v; want nil", cycle)
+	}
+}
+
+func TestLoadExecPolicy(t *testing.T) {
+	t.Setenv("EXEC_ALLOWED_COMMANDS", "/usr/bin/pg_isready, /opt/checks/")
+	t.Setenv("EXEC_NAMESPACES", "ops")
+	t.Setenv("EXEC_CPU_TIME", "5s")
+	t.Setenv("EXEC_MEMORY", "128Mi")
+	t.Setenv("EXEC_MAX_OUTPUT", "64Ki")
+	t.Setenv("EXEC_UID", "65534")
+
+	p, err := LoadExecPolicy()
+	if err != nil {
+		t.Fatalf("LoadExecPolicy() error: %v", err)
+	}
+	if len(p.AllowedCommands) != 2 || p.AllowedCommands[1] != "/opt/checks/" {
+		t.Errorf("AllowedCommands = %q", p.AllowedCommands)
+	}
+	if len(p.Namespaces) != 1 || p.CPUTime != 5*time.Second || p.Memory != 128<<20 || p.MaxOutput != 64<<10 {
+		t.Errorf("policy = %+v", p)
+	}
+	if p.UID == nil || *p.UID != 65534 || p.GID != nil {
+		t.Errorf("UID = %v, GID = %v; want 65534 and unset", p.UID, p.GID)
+	}
+
+	t.Setenv("EXEC_MEMORY", "lots")
+	if _, err := LoadExecPolicy(); err == nil {
+		t.Errorf("expected error for malformed EXEC_MEMORY")
+	}
+}
+
+func TestLoadTargetPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "target-policy.json")
+	if p, err := LoadTargetPolicy(path); err != nil || len(p.DenyCIDRs) != 0 {
+		t.Fatalf("missing file = %+v, %v; want an empty policy", p, err)
+	}
+
+	data := `{"denyCIDRs": ["169.254.0.0/16"], "denyPorts": [10250],
+		"namespaces": {"team-a": {"allowHosts": ["*.team-a.svc.cluster.local"]}}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadTargetPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadTargetPolicy() error: %v", err)
+	}
+	if len(p.DenyCIDRs) != 1 || len(p.DenyPorts) != 1 || len(p.Namespaces["team-a"].AllowHosts) != 1 {
+		t.Errorf("policy = %+v", p)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"denyCIDR": ["10.0.0.0/8"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTargetPolicy(path); err == nil {
+		t.Errorf("expected error for an unknown field")
+	}
+}
+
+func TestLoadScope(t *testing.T) {
+	s, err := LoadScope()
+	if err != nil || !s.AllNamespaces() || !s.Watches("") || s.SelectorString() != "" || s.Labels() != nil {
+		t.Fatalf("default scope = %+v, %v; want everything", s, err)
+	}
+	if got := s.WatchedNamespaces(); len(got) != 1 || got[0] != "" {
+		t.Errorf("WatchedNamespaces() = %q; want all", got)
+	}
+
+	t.Setenv("WATCH_NAMESPACES", "team-a, team-b")
+	t.Setenv("PROBE_SELECTOR", "instance=team-a,tier in (web),env!=dev")
+	s, err = LoadScope()
+	if err != nil {
+		t.Fatalf("LoadScope() error: %v", err)
+	}
+	if s.AllNamespaces() || !s.Watches("team-b") || s.Watches("team-c") || s.Watches("") {
+		t.Errorf("namespaces = %q", s.Namespaces)
+	}
+	labels := s.AddLabels(map[string]string{"app": "api"})
+	want := map[string]string{"app": "api", "instance": "team-a", "tier": "web"}
+	if len(labels) != len(want) {
+		t.Errorf("labels = %v; want %v", labels, want)
+	}
+	for k, v := range want {
+		if labels[k] != v {
+			t.Errorf("label %s = %q; want %q", k, labels[k], v)
+		}
+	}
+	if !s.Selector.Matches(k8slabels.Set(labels)) {
+		t.Errorf("selector %s does not match the labels it sets", s.SelectorString())
+	}
+
+	t.Setenv("WATCH_NAMESPACES", "Team_A")
+	if _, err := LoadScope(); err == nil {
+		t.Errorf("expected error for a malformed namespace")
+	}
+	t.Setenv("WATCH_NAMESPACES", "")
+	t.Setenv("PROBE_SELECTOR", "instance in (")
+	if _, err := LoadScope(); err == nil {
+		t.Errorf("expected error for a malformed selector")
+	}
+}
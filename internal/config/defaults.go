@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Defaults are the operator-wide values filled into probes that leave them unset.
+type Defaults struct {
+	Interval         time.Duration
+	Timeout          time.Duration
+	SuccessThreshold int32
+	FailureThreshold int32
+	// MinInterval is the shortest interval a probe may ask for.
+	MinInterval time.Duration
+}
+
+// LoadDefaults reads the operator-wide defaults from the environment,
+// falling back to built-in values for unset variables.
+func LoadDefaults() (Defaults, error) {
+	d := Defaults{
+		Interval:         30 * time.Second,
+		Timeout:          2 * time.Second,
+		SuccessThreshold: 1,
+		FailureThreshold: 1,
+		MinInterval:      time.Second,
+	}
+
+	var err error
+	if d.Interval, err = durationEnv("DEFAULT_INTERVAL", d.Interval); err != nil {
+		return d, err
+	}
+	if d.Timeout, err = durationEnv("DEFAULT_TIMEOUT", d.Timeout); err != nil {
+		return d, err
+	}
+	if d.MinInterval, err = durationEnv("MIN_INTERVAL", d.MinInterval); err != nil {
+		return d, err
+	}
+	if d.SuccessThreshold, err = thresholdEnv("DEFAULT_SUCCESS_THRESHOLD", d.SuccessThreshold); err != nil {
+		return d, err
+	}
+	if d.FailureThreshold, err = thresholdEnv("DEFAULT_FAILURE_THRESHOLD", d.FailureThreshold); err != nil {
+		return d, err
+	}
+
+	if d.Timeout > d.Interval {
+		return d, fmt.Errorf("DEFAULT_TIMEOUT (%s) must not exceed DEFAULT_INTERVAL (%s)", d.Timeout, d.Interval)
+	}
+	if d.Interval < d.MinInterval {
+		return d, fmt.Errorf("DEFAULT_INTERVAL (%s) is below MIN_INTERVAL (%s)", d.Interval, d.MinInterval)
+	}
+	return d, nil
+}
+
+func durationEnv(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return fallback, fmt.Errorf("%s: %q is not a positive duration", key, v)
+	}
+	return d, nil
+}
+
+func thresholdEnv(key string, fallback int32) (int32, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 32)
+	if err != nil || n < 1 {
+		return fallback, fmt.Errorf("%s: %q is not a positive integer", key, v)
+	}
+	return int32(n), nil
+}
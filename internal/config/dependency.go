@@ -0,0 +1,40 @@
+package config
+
+import "strings"
+
+// DependencyKey resolves a dependsOn entry of a rule of the given kind and
+// namespace to the Key of the rule it names. A plain name refers to the same
+// kind (and namespace), "ClusterProbe/<name>" to a ClusterProbe.
+func DependencyKey(kind, namespace, dep string) string {
+	if name, ok := strings.CutPrefix(dep, KindClusterProbe+"/"); ok {
+		return GateRule{Kind: KindClusterProbe, Name: name}.Key()
+	}
+	return GateRule{Kind: kind, Namespace: namespace, Name: dep}.Key()
+}
+
+// FindCycle walks the dependencies of start, as returned by parents, and
+// returns the first path leading back to start, or nil if there is none.
+func FindCycle(start string, parents func(key string) ([]string, error)) ([]string, error) {
+	visited := map[string]bool{}
+	var walk func(path []string) ([]string, error)
+	walk = func(path []string) ([]string, error) {
+		deps, err := parents(path[len(path)-1])
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range deps {
+			if dep == start {
+				return append(path, dep), nil
+			}
+			if visited[dep] {
+				continue
+			}
+			visited[dep] = true
+			if cycle, err := walk(append(path[:len(path):len(path)], dep)); cycle != nil || err != nil {
+				return cycle, err
+			}
+		}
+		return nil, nil
+	}
+	return walk([]string{start})
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"heartbeat-operator/internal/prober"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// LoadExecPolicy reads the policy of exec checks that run in the operator
+// from the environment. Unset variables leave the policy open, as it was
+// before there was one.
+func LoadExecPolicy() (prober.ExecPolicy, error) {
+	p := prober.ExecPolicy{
+		AllowedCommands: listEnv("EXEC_ALLOWED_COMMANDS"),
+		Namespaces:      listEnv("EXEC_NAMESPACES"),
+	}
+
+	var err error
+	if p.CPUTime, err = durationEnv("EXEC_CPU_TIME", 0); err != nil {
+		return p, err
+	}
+	memory, err := quantityEnv("EXEC_MEMORY")
+	if err != nil {
+		return p, err
+	}
+	p.Memory = uint64(memory)
+	if p.MaxOutput, err = quantityEnv("EXEC_MAX_OUTPUT"); err != nil {
+		return p, err
+	}
+	if p.UID, err = idEnv("EXEC_UID"); err != nil {
+		return p, err
+	}
+	if p.GID, err = idEnv("EXEC_GID"); err != nil {
+		return p, err
+	}
+	return p, nil
+}
+
+func listEnv(key string) []string {
+	var out []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func quantityEnv(key string) (int64, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+	q, err := resource.ParseQuantity(v)
+	if err != nil || q.Sign() <= 0 {
+		return 0, fmt.Errorf("%s: %q is not a positive quantity such as 64Mi", key, v)
+	}
+	return q.Value(), nil
+}
+
+func idEnv(key string) (*uint32, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %q is not a numeric id", key, v)
+	}
+	id := uint32(n)
+	return &id, nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Scope limits what an operator instance works on, so several instances can
+// share a cluster. The zero value covers everything.
+type Scope struct {
+	// Namespaces the instance watches, all of them when empty.
+	// ClusterProbes are only run by instances that watch all namespaces.
+	Namespaces []string
+	// Selector picks the Probes, ClusterProbes and ProbeGroups of the
+	// instance, nil for all of them.
+	Selector labels.Selector
+}
+
+// LoadScope reads WATCH_NAMESPACES, a comma-separated list, and
+// PROBE_SELECTOR, a label selector such as "team=payments".
+func LoadScope() (Scope, error) {
+	s := Scope{}
+	for _, ns := range listEnv("WATCH_NAMESPACES") {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			return s, fmt.Errorf("WATCH_NAMESPACES: %s: %s", ns, msg)
+		}
+		s.Namespaces = append(s.Namespaces, ns)
+	}
+	if v := os.Getenv("PROBE_SELECTOR"); v != "" {
+		sel, err := labels.Parse(v)
+		if err != nil {
+			return s, fmt.Errorf("PROBE_SELECTOR: %v", err)
+		}
+		s.Selector = sel
+	}
+	return s, nil
+}
+
+// AllNamespaces reports whether the instance watches the whole cluster.
+func (s Scope) AllNamespaces() bool {
+	return len(s.Namespaces) == 0
+}
+
+// WatchedNamespaces returns the namespaces to list and watch, a single
+// metav1.NamespaceAll when all are watched.
+func (s Scope) WatchedNamespaces() []string {
+	if s.AllNamespaces() {
+		return []string{""}
+	}
+	return s.Namespaces
+}
+
+// Watches reports whether objects of namespace belong to the instance, ""
+// standing for cluster-scoped ones.
+func (s Scope) Watches(namespace string) bool {
+	if s.AllNamespaces() {
+		return true
+	}
+	for _, ns := range s.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectorString returns the selector for list options, empty for all.
+func (s Scope) SelectorString() string {
+	if s.Selector == nil {
+		return ""
+	}
+	return s.Selector.String()
+}
+
+// Labels returns the labels the selector requires to be equal to a value.
+// They are set on the Probes the instance creates, so it picks them up.
+func (s Scope) Labels() map[string]string {
+	if s.Selector == nil {
+		return nil
+	}
+	reqs, _ := s.Selector.Requirements()
+	out := map[string]string{}
+	for _, r := range reqs {
+		switch r.Operator() {
+		case selection.Equals, selection.DoubleEquals, selection.In:
+			if values := r.Values().List(); len(values) == 1 {
+				out[r.Key()] = values[0]
+			}
+		}
+	}
+	return out
+}
+
+// AddLabels sets the labels of the selector on l, allocating it when nil.
+func (s Scope) AddLabels(l map[string]string) map[string]string {
+	for k, v := range s.Labels() {
+		if l == nil {
+			l = map[string]string{}
+		}
+		l[k] = v
+	}
+	return l
+}
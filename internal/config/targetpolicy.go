@@ -0,0 +1,29 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"heartbeat-operator/internal/prober"
+)
+
+// LoadTargetPolicy reads the target policy of http and tcp checks from a
+// JSON file. A missing file is an empty policy that allows every target.
+func LoadTargetPolicy(path string) (prober.TargetPolicy, error) {
+	var policy prober.TargetPolicy
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&policy); err != nil {
+		return policy, fmt.Errorf("%s: %v", path, err)
+	}
+	return policy, nil
+}
@@ -2,66 +2,234 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"heartbeat-operator/api/v1alpha1"
 	"heartbeat-operator/internal/config"
 	"heartbeat-operator/internal/metrics"
 	"heartbeat-operator/internal/prober"
+	"heartbeat-operator/internal/schedule"
 	"heartbeat-operator/internal/ui"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
 )
 
 type ReadinessController struct {
 	client    *kubernetes.Clientset
-	crdClient *CrdClient
+	crdClient ProbeClient
 	rule      config.GateRule
 	probe     prober.Prober
 	recorder  record.EventRecorder
+	// ownsCR is set for config file rules, whose CR is created (and
+	// re-created) by the controller. CRs created by users are left alone.
+	ownsCR bool
+	// notified is the check result of the last recorded event. Events are
+	// held while the probe is suppressed and caught up afterwards.
+	notified *bool
+	// endpoints are the endpoint addresses reported by the last check, to
+	// drop the series of endpoints that went away.
+	endpoints map[string]bool
+	// trigger signals a check requested out of schedule, see Trigger. The
+	// ID of the request waits in pending.
+	trigger chan struct{}
+	mu      sync.Mutex
+	pending string
+	// request is the ID of the request the running check serves, its
+	// result is always written to the status.
+	request string
+	// schedule replaces interval when the rule has one. due is when the
+	// next scheduled check runs, zero when it never does.
+	schedule schedule.Set
+	interval time.Duration
+	due      time.Time
+	// reported is the health the probe reports, nil before its first
+	// check. streak counts the consecutive checks since that disagree with
+	// it, see applyThresholds.
+	reported *bool
+	streak   int32
 }
 
-// New creates a new ReadinessController
-func New(client *kubernetes.Clientset, crdClient *CrdClient, rule config.GateRule, p prober.Prober, recorder record.EventRecorder) *ReadinessController {
+// maxHistory is the number of changes of Healthy kept in the status.
+const maxHistory = 10
+
+// New creates a new ReadinessController for a config file rule
+func New(client *kubernetes.Clientset, crdClient ProbeClient, rule config.GateRule, p prober.Prober, recorder record.EventRecorder) *ReadinessController {
 	return &ReadinessController{
 		client:    client,
 		crdClient: crdClient,
 		rule:      rule,
 		probe:     p,
 		recorder:  recorder,
+		ownsCR:    true,
+		trigger:   make(chan struct{}, 1),
 	}
 }
 
+// NewForCR creates a ReadinessController for a rule read from an existing
+// Probe or ClusterProbe, which it only updates the status of.
+func NewForCR(client *kubernetes.Clientset, crdClient ProbeClient, rule config.GateRule, p prober.Prober, recorder record.EventRecorder) *ReadinessController {
+	c := New(client, crdClient, rule, p, recorder)
+	c.ownsCR = false
+	return c
+}
+
 func (c *ReadinessController) Start(ctx context.Context) {
 	log.Printf("[%s] Started watching %s (Targeting CRD)", c.rule.Name, c.rule.TargetLabel)
 
 	// Ensure CR exists
-	err := c.ensureCR(ctx)
-	if err != nil {
-		log.Printf("[%s] Failed to ensure CRD: %v", c.rule.Name, err)
-		// Don't exit, maybe CRD isn't installed yet, retry in loop
+	if c.ownsCR {
+		err := c.ensureCR(ctx)
+		if err != nil {
+			log.Printf("[%s] Failed to ensure CRD: %v", c.rule.Name, err)
+			// Don't exit, maybe CRD isn't installed yet, retry in loop
+		}
 	}
 
-	interval := config.ParseInterval(c.rule.Interval)
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	defer health.remove(c.rule.Key())
+	defer c.recordEndpoints(nil)
+	if sp, ok := c.probe.(prober.StoppingProber); ok {
+		defer sp.Stop()
+	}
 
-	// Initial check
-	c.reconcile(ctx)
+	c.interval = config.ParseInterval(c.rule.Interval)
+	if c.rule.Schedule != "" {
+		set, err := parseSchedule(c.rule.Schedule, c.rule.TimeZone)
+		if err != nil {
+			log.Printf("[%s] Invalid schedule, checking every %s instead: %v", c.rule.Name, c.interval, err)
+		}
+		c.schedule = set
+	}
+	c.due = c.nextCheck(time.Time{}, time.Now())
+	timer := time.NewTimer(time.Until(c.due))
+	defer timer.Stop()
+	if c.due.IsZero() {
+		log.Printf("[%s] Schedule %q never fires", c.rule.Name, c.rule.Schedule)
+		timer.Stop()
+	}
+
+	// Initial check, it serves a request made before the start. A
+	// scheduled probe waits for its schedule, the status shows when.
+	select {
+	case <-c.trigger:
+	default:
+	}
+	if id := c.takeRequest(); c.schedule == nil || id != "" {
+		c.check(ctx, id)
+	} else {
+		c.recordNextProbeTime(ctx)
+	}
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-timer.C:
+			c.due = c.nextCheck(c.due, time.Now())
 			c.reconcile(ctx)
+			if !c.due.IsZero() {
+				timer.Reset(time.Until(c.due))
+			}
+		case <-c.trigger:
+			if id := c.takeRequest(); id != "" {
+				log.Printf("[%s] Check requested by %s", c.rule.Name, id)
+				c.check(ctx, id)
+			}
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// Trigger asks for a check right away, its status records id as the
+// request served. The regular checks keep their schedule; a request made
+// while another is pending replaces it.
+func (c *ReadinessController) Trigger(id string) {
+	c.mu.Lock()
+	c.pending = id
+	c.mu.Unlock()
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (c *ReadinessController) takeRequest() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.pending
+	c.pending = ""
+	return id
+}
+
+// check runs a check serving the request id, none when it is empty.
+func (c *ReadinessController) check(ctx context.Context, id string) {
+	c.request = id
+	defer func() { c.request = "" }()
+	c.reconcile(ctx)
+}
+
+// parseSchedule parses the cron expressions of a rule in its time zone.
+func parseSchedule(expr, timeZone string) (schedule.Set, error) {
+	loc := time.UTC
+	if timeZone != "" {
+		l, err := time.LoadLocation(timeZone)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", timeZone)
+		}
+		loc = l
+	}
+	return schedule.ParseSet(expr, loc)
+}
+
+// nextCheck returns when the check after the one due at last runs: the
+// next activation of the schedule after now, or an interval after last.
+// Checks missed meanwhile are dropped, as a time.Ticker does.
+func (c *ReadinessController) nextCheck(last, now time.Time) time.Time {
+	if c.schedule != nil {
+		return c.schedule.Next(now)
+	}
+	next := last.Add(c.interval)
+	if last.IsZero() || !next.After(now) {
+		next = now.Add(c.interval)
+	}
+	return next
+}
+
+// nextProbeTime is the due time for the status, nil when there is none.
+// The API keeps seconds.
+func (c *ReadinessController) nextProbeTime() *metav1.Time {
+	if c.due.IsZero() {
+		return nil
+	}
+	t := metav1.NewTime(c.due.Truncate(time.Second))
+	return &t
+}
+
+// recordNextProbeTime writes when the next check runs to the status,
+// before the first check of a scheduled probe.
+func (c *ReadinessController) recordNextProbeTime(ctx context.Context) {
+	cr, err := c.crdClient.Get(ctx, c.rule.Name)
+	if err != nil {
+		log.Printf("[%s] Failed to get CR: %v", c.rule.Name, err)
+		return
+	}
+	next := c.nextProbeTime()
+	if equality.Semantic.DeepEqual(cr.Status.NextProbeTime, next) {
+		return
+	}
+	cr.Status.NextProbeTime = next
+	if _, err := c.crdClient.UpdateStatus(ctx, cr); err != nil {
+		log.Printf("[%s] Failed to update CR status: %v", c.rule.Name, err)
+	}
+}
+
 func (c *ReadinessController) ensureCR(ctx context.Context) error {
 	// Check if already exists
 	_, err := c.crdClient.Get(ctx, c.rule.Name)
@@ -74,36 +242,97 @@ func (c *ReadinessController) ensureCR(ctx context.Context) error {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      c.rule.Name,
 			Namespace: c.rule.Namespace,
+			Labels:    c.rule.Labels,
 		},
 		Spec: v1alpha1.ProbeSpec{
 			CheckType:   c.rule.CheckType,
 			CheckTarget: c.rule.CheckTarget,
 			Interval:    c.rule.Interval,
+			Timeout:     c.rule.Timeout,
+			Schedule:    c.rule.Schedule,
+			TimeZone:    c.rule.TimeZone,
+			DependsOn:   c.rule.DependsOn,
+			Suspend:     c.rule.Suspend,
+			Retries:     retryPolicy(c.rule.Retries),
+
+			SuccessThreshold: c.rule.SuccessThreshold,
+			FailureThreshold: c.rule.FailureThreshold,
 		},
 	}
+	if len(c.rule.Options) > 0 {
+		dc.Spec.Options = &runtime.RawExtension{Raw: c.rule.Options}
+	}
 	log.Printf("[%s] Creating Probe CR...", c.rule.Name)
 	_, err = c.crdClient.Create(ctx, dc)
 	return err
 }
 
+// retryPolicy is the API form of the retries of a rule.
+func retryPolicy(r *config.Retries) *v1alpha1.RetryPolicy {
+	if r == nil {
+		return nil
+	}
+	return &v1alpha1.RetryPolicy{Count: r.Count, Delay: r.Delay, Backoff: r.Backoff, MaxDelay: r.MaxDelay}
+}
+
 func (c *ReadinessController) reconcile(ctx context.Context) {
+	window, held := c.maintenanceWindow()
+	metrics.ProbeMaintenance.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Set(boolToFloat(held))
+	if held && window.mode == v1alpha1.MaintenanceModePause {
+		c.hold(ctx, window)
+		return
+	}
+
 	start := time.Now()
-	isHealthy := c.probe.Check()
-	duration := time.Since(start).Seconds()
+	res := prober.RunWithRetry(ctx, c.probe, c.rule.Retry())
+	isHealthy := res.Healthy
+	elapsed := time.Since(start)
+	duration := elapsed.Seconds()
 
 	metrics.ProbeDuration.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Observe(duration)
+	metrics.ProbeAttempts.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Observe(float64(res.Attempts))
 	metrics.ProbeLastTimestamp.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Set(float64(time.Now().Unix()))
 
+	// The metric is the result of the check, before the thresholds
 	if isHealthy {
-		metrics.ProbeSuccess.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Set(1)
+		metrics.ProbeSuccess.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType, "").Set(1)
 	} else {
-		metrics.ProbeSuccess.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Set(0)
+		metrics.ProbeSuccess.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType, "").Set(0)
+	}
+	c.recordEndpoints(res.Endpoints)
+	if hb := res.Heartbeat; hb != nil {
+		if !hb.LastPing.IsZero() {
+			metrics.ProbeHeartbeatLastPing.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Set(float64(hb.LastPing.Unix()))
+		}
+		metrics.ProbeHeartbeatRunDuration.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Set(hb.RunDuration.Seconds())
+	}
+	if res.HTTP != nil {
+		c.recordHTTP(res.HTTP)
 	}
 
-	ui.UpdateState(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType, isHealthy)
+	// A failure in a muting maintenance window changes neither the status
+	// nor dependents and events, the last result stands
+	muted := held && !isHealthy
+	msg := res.Message
+	if !muted {
+		isHealthy, msg = c.applyThresholds(res)
+		health.set(c.rule.Key(), isHealthy, c.rule.DependencyKeys())
+		ui.UpdateState(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType, isHealthy)
+	}
+
+	// A failing probe upstream explains this one, report it as suppressed
+	suppressedBy := health.failingAncestor(c.rule.Key())
+	metrics.ProbeSuppressed.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Set(boolToFloat(suppressedBy != ""))
+
+	ui.UpdateDependencies(c.rule.Name, c.rule.Key(), c.rule.DependencyKeys(), suppressedBy)
+	ui.UpdateEndpoints(c.rule.Name, uiEndpoints(res.Endpoints))
 
 	// Fetch current CR to update status
 	cr, err := c.crdClient.Get(ctx, c.rule.Name)
+	if err != nil && !c.ownsCR {
+		log.Printf("[%s] Failed to get CR: %v", c.rule.Name, err)
+		return
+	}
 	if err != nil {
 		// Try to re-create if missing?
 		if err := c.ensureCR(ctx); err != nil {
@@ -118,19 +347,74 @@ func (c *ReadinessController) reconcile(ctx context.Context) {
 		}
 	}
 
+	if muted {
+		isHealthy = cr.Status.Healthy
+	} else {
+		c.notify(cr, isHealthy, suppressedBy)
+	}
+
 	// Update status logic
 	// Only update if changed or if it's been a while?
 	// For now, simple update
 	now := metav1.Now()
-	msg := "Check passed"
+	cond := metav1.Condition{
+		Type:               v1alpha1.ConditionHealthy,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: cr.Generation,
+		Reason:             v1alpha1.ReasonCheckPassed,
+	}
 	if !isHealthy {
-		msg = "Check failed"
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = v1alpha1.ReasonCheckFailed
 	}
+	if res.PolicyDenied {
+		cond.Reason = v1alpha1.ReasonPolicyDenied
+	}
+	if suppressedBy != "" {
+		msg = fmt.Sprintf("Suppressed: %s is failing", suppressedBy)
+		cond.Status = metav1.ConditionUnknown
+		cond.Reason = v1alpha1.ReasonSuppressed
+	}
+	if muted {
+		msg = fmt.Sprintf("In maintenance (%s): %s", window.name, res.Message)
+		cond.Status = metav1.ConditionUnknown
+		cond.Reason = v1alpha1.ReasonInMaintenance
+	}
+	cond.Message = msg
 
-	if cr.Status.Healthy != isHealthy || cr.Status.Message != msg {
+	endpoints := statusEndpoints(res.Endpoints)
+	hb := statusHeartbeat(res.Heartbeat)
+	denied := meta.FindStatusCondition(cr.Status.Conditions, v1alpha1.ConditionPolicyDenied)
+	if cr.Status.Healthy != isHealthy || cr.Status.Message != msg || meta.FindStatusCondition(cr.Status.Conditions, cond.Type) == nil ||
+		!equality.Semantic.DeepEqual(cr.Status.Endpoints, endpoints) || !equality.Semantic.DeepEqual(cr.Status.Heartbeat, hb) ||
+		(denied != nil) != res.PolicyDenied || (len(cr.Status.History) == 0 && !muted) || c.request != "" ||
+		(c.schedule != nil && !equality.Semantic.DeepEqual(cr.Status.NextProbeTime, c.nextProbeTime())) {
+		if !muted {
+			recordHistory(&cr.Status, now, isHealthy, msg)
+		}
 		cr.Status.Healthy = isHealthy
 		cr.Status.Message = msg
+		cr.Status.Endpoints = endpoints
+		cr.Status.Heartbeat = hb
 		cr.Status.LastProbeTime = &now
+		cr.Status.LastDuration = &metav1.Duration{Duration: elapsed}
+		cr.Status.NextProbeTime = c.nextProbeTime()
+		cr.Status.Attempts = int32(res.Attempts)
+		if c.request != "" {
+			cr.Status.LastRunRequest = c.request
+		}
+		meta.SetStatusCondition(&cr.Status.Conditions, cond)
+		if res.PolicyDenied {
+			meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+				Type:               v1alpha1.ConditionPolicyDenied,
+				Status:             metav1.ConditionTrue,
+				ObservedGeneration: cr.Generation,
+				Reason:             v1alpha1.ReasonTargetDenied,
+				Message:            res.Message,
+			})
+		} else {
+			meta.RemoveStatusCondition(&cr.Status.Conditions, v1alpha1.ConditionPolicyDenied)
+		}
 		_, err := c.crdClient.UpdateStatus(ctx, cr)
 		if err != nil {
 			log.Printf("[%s] Failed to update CR status: %v", c.rule.Name, err)
@@ -142,9 +426,193 @@ func (c *ReadinessController) reconcile(ctx context.Context) {
 		// Let's update timestamp if it's been > 1 minute or if we want liveliness
 		if cr.Status.LastProbeTime == nil || time.Since(cr.Status.LastProbeTime.Time) > time.Minute {
 			cr.Status.LastProbeTime = &now
+			cr.Status.LastDuration = &metav1.Duration{Duration: elapsed}
+			cr.Status.NextProbeTime = c.nextProbeTime()
 			if _, err := c.crdClient.UpdateStatus(ctx, cr); err != nil {
 				log.Printf("[%s] Failed to update CR timestamp: %v", c.rule.Name, err)
 			}
 		}
 	}
 }
+
+// applyThresholds turns the result of a check into the health the probe
+// reports and its message. The health changes after SuccessThreshold
+// consecutive passed or FailureThreshold consecutive failed checks, the
+// first check and a target the policy refuses set it right away.
+func (c *ReadinessController) applyThresholds(res prober.Result) (bool, string) {
+	if c.reported == nil || *c.reported == res.Healthy || res.PolicyDenied {
+		healthy := res.Healthy
+		c.reported = &healthy
+		c.streak = 0
+		return healthy, res.Message
+	}
+	c.streak++
+	threshold, counted := c.rule.FailureThreshold, "failed"
+	if res.Healthy {
+		threshold, counted = c.rule.SuccessThreshold, "passed"
+	}
+	if c.streak >= threshold {
+		healthy := res.Healthy
+		c.reported = &healthy
+		c.streak = 0
+		return healthy, res.Message
+	}
+	return *c.reported, fmt.Sprintf("%s (%d/%d checks %s)", res.Message, c.streak, threshold, counted)
+}
+
+// maintenanceWindow returns the window holding off the checks: a Pause
+// one without a name while the rule is suspended, otherwise the
+// MaintenanceWindow covering the Probe, if any.
+func (c *ReadinessController) maintenanceWindow() (activeWindow, bool) {
+	if c.rule.Suspend {
+		return activeWindow{mode: v1alpha1.MaintenanceModePause}, true
+	}
+	return maintenance.get(c.rule.Key())
+}
+
+// hold reports a paused probe in its status instead of checking it.
+// Healthy and the last probe time keep the last result.
+func (c *ReadinessController) hold(ctx context.Context, window activeWindow) {
+	cr, err := c.crdClient.Get(ctx, c.rule.Name)
+	if err != nil && c.ownsCR {
+		if err = c.ensureCR(ctx); err == nil {
+			cr, err = c.crdClient.Get(ctx, c.rule.Name)
+		}
+	}
+	if err != nil {
+		log.Printf("[%s] Failed to get CR: %v", c.rule.Name, err)
+		return
+	}
+
+	cond := metav1.Condition{
+		Type:               v1alpha1.ConditionHealthy,
+		Status:             metav1.ConditionUnknown,
+		ObservedGeneration: cr.Generation,
+		Reason:             v1alpha1.ReasonSuspended,
+		Message:            "Suspended",
+	}
+	if window.name != "" {
+		cond.Reason = v1alpha1.ReasonInMaintenance
+		cond.Message = fmt.Sprintf("In maintenance (%s), not checked", window.name)
+	}
+	old := meta.FindStatusCondition(cr.Status.Conditions, cond.Type)
+	if old != nil && old.Status == cond.Status && old.Reason == cond.Reason && cr.Status.Message == cond.Message && c.request == "" {
+		return
+	}
+	cr.Status.Message = cond.Message
+	if c.request != "" {
+		cr.Status.LastRunRequest = c.request
+	}
+	meta.SetStatusCondition(&cr.Status.Conditions, cond)
+	if _, err := c.crdClient.UpdateStatus(ctx, cr); err != nil {
+		log.Printf("[%s] Failed to update CR status: %v", c.rule.Name, err)
+	} else {
+		log.Printf("[%s] Not checked: %s", c.rule.Name, cond.Message)
+	}
+}
+
+// recordHistory adds a change of Healthy to the history of status, or its
+// first result, and keeps the last failure.
+func recordHistory(status *v1alpha1.ProbeStatus, now metav1.Time, healthy bool, msg string) {
+	if !healthy {
+		status.LastFailure = &v1alpha1.ProbeResult{Time: now, Healthy: false, Message: msg}
+	}
+	if n := len(status.History); n > 0 && status.History[n-1].Healthy == healthy {
+		return
+	}
+	status.History = append(status.History, v1alpha1.ProbeResult{Time: now, Healthy: healthy, Message: msg})
+	if n := len(status.History); n > maxHistory {
+		status.History = status.History[n-maxHistory:]
+	}
+}
+
+// notify records an event on the CR when the check result changes. Nothing
+// is recorded while the probe is suppressed, the parent already reports the
+// outage; a change that happened meanwhile is recorded once it is lifted.
+func (c *ReadinessController) notify(cr *v1alpha1.Probe, healthy bool, suppressedBy string) {
+	if c.recorder == nil || suppressedBy != "" {
+		return
+	}
+	if c.notified != nil && *c.notified == healthy {
+		return
+	}
+	first := c.notified == nil
+	c.notified = &healthy
+	if first && healthy {
+		return
+	}
+
+	var obj runtime.Object = cr
+	if c.rule.Kind == config.KindClusterProbe {
+		obj = toClusterProbe(cr)
+	}
+	if healthy {
+		c.recorder.Eventf(obj, corev1.EventTypeNormal, "ProbeRecovered", "Check of %s passed", c.rule.CheckTarget)
+	} else {
+		c.recorder.Eventf(obj, corev1.EventTypeWarning, "ProbeFailed", "Check of %s failed", c.rule.CheckTarget)
+	}
+}
+
+// recordEndpoints sets the probe_success series of every endpoint of a
+// fanned out check and deletes those of endpoints no longer reported.
+func (c *ReadinessController) recordEndpoints(results []prober.EndpointResult) {
+	current := make(map[string]bool, len(results))
+	for _, r := range results {
+		current[r.Address] = true
+		metrics.ProbeSuccess.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType, r.Address).Set(boolToFloat(r.Healthy))
+	}
+	for addr := range c.endpoints {
+		if !current[addr] {
+			metrics.ProbeSuccess.DeleteLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType, addr)
+		}
+	}
+	c.endpoints = current
+}
+
+// recordHTTP sets the per-phase timings and response details of an http
+// check.
+func (c *ReadinessController) recordHTTP(h *prober.HTTPResult) {
+	for phase, d := range h.Phases {
+		metrics.ProbeHTTPDuration.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType, phase).Set(d.Seconds())
+	}
+	metrics.ProbeHTTPStatusCode.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Set(float64(h.StatusCode))
+	metrics.ProbeHTTPVersion.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Set(h.Version)
+	metrics.ProbeHTTPRedirects.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Set(float64(h.Redirects))
+	metrics.ProbeHTTPContentLength.WithLabelValues(c.rule.Name, c.rule.CheckTarget, c.rule.CheckType).Set(float64(h.ContentLength))
+}
+
+func statusEndpoints(results []prober.EndpointResult) []v1alpha1.EndpointStatus {
+	var out []v1alpha1.EndpointStatus
+	for _, r := range results {
+		out = append(out, v1alpha1.EndpointStatus{Address: r.Address, Healthy: r.Healthy, Message: r.Message})
+	}
+	return out
+}
+
+func statusHeartbeat(hb *prober.HeartbeatResult) *v1alpha1.HeartbeatStatus {
+	if hb == nil {
+		return nil
+	}
+	// The API keeps seconds, compare with what it will return
+	out := &v1alpha1.HeartbeatStatus{}
+	if !hb.LastPing.IsZero() {
+		t := metav1.NewTime(hb.LastPing.Truncate(time.Second))
+		out.LastPingTime = &t
+	}
+	if !hb.LastSuccess.IsZero() {
+		t := metav1.NewTime(hb.LastSuccess.Truncate(time.Second))
+		out.LastSuccessTime = &t
+	}
+	if hb.RunDuration > 0 {
+		out.RunDuration = &metav1.Duration{Duration: hb.RunDuration.Round(time.Second)}
+	}
+	return out
+}
+
+func uiEndpoints(results []prober.EndpointResult) []ui.EndpointStatus {
+	var out []ui.EndpointStatus
+	for _, r := range results {
+		out = append(out, ui.EndpointStatus{Address: r.Address, IsHealthy: r.Healthy, Message: r.Message})
+	}
+	return out
+}
@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/internal/config"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecordHistory(t *testing.T) {
+	var status v1alpha1.ProbeStatus
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(i int) metav1.Time { return metav1.NewTime(start.Add(time.Duration(i) * time.Minute)) }
+
+	recordHistory(&status, at(0), true, "Check passed")
+	recordHistory(&status, at(1), true, "Check passed")
+	if len(status.History) != 1 || status.LastFailure != nil {
+		t.Fatalf("history after two passes = %+v, last failure %+v", status.History, status.LastFailure)
+	}
+
+	recordHistory(&status, at(2), false, "connection refused")
+	recordHistory(&status, at(3), false, "timeout")
+	if len(status.History) != 2 || status.History[1].Message != "connection refused" {
+		t.Errorf("history after failures = %+v", status.History)
+	}
+	if f := status.LastFailure; f == nil || f.Message != "timeout" || !f.Time.Equal(&metav1.Time{Time: at(3).Time}) {
+		t.Errorf("last failure = %+v, want the timeout at minute 3", f)
+	}
+
+	for i := 4; i < 4+2*maxHistory; i++ {
+		recordHistory(&status, at(i), i%2 == 0, "")
+	}
+	if len(status.History) != maxHistory {
+		t.Fatalf("history holds %d entries, want %d", len(status.History), maxHistory)
+	}
+	if last := status.History[maxHistory-1]; !last.Time.Equal(&metav1.Time{Time: at(3 + 2*maxHistory).Time}) {
+		t.Errorf("newest entry at %v, want minute %d", last.Time, 3+2*maxHistory)
+	}
+}
+
+func TestTriggeredCheckWritesStatus(t *testing.T) {
+	ctx := context.Background()
+	client := NewMemoryClient()
+	rule := config.GateRule{Name: "triggered", Namespace: "ops", CheckType: "tcp", CheckTarget: "db:5432", Interval: "1h"}
+	c := New(nil, client, rule, &stubProber{healthy: true}, nil)
+	if err := c.ensureCR(ctx); err != nil {
+		t.Fatalf("ensureCR: %v", err)
+	}
+	c.reconcile(ctx)
+
+	// An unchanged result is not written again within a minute
+	cr, _ := client.Get(ctx, rule.Name)
+	old := metav1.NewTime(time.Now().Add(-30 * time.Second).Truncate(time.Second))
+	cr.Status.LastProbeTime = &old
+	if _, err := client.UpdateStatus(ctx, cr); err != nil {
+		t.Fatal(err)
+	}
+	c.reconcile(ctx)
+	if cr, _ = client.Get(ctx, rule.Name); !cr.Status.LastProbeTime.Equal(&old) {
+		t.Fatal("unchanged result written")
+	}
+
+	c.Trigger("first")
+	c.Trigger("second")
+	if len(c.trigger) != 1 {
+		t.Fatalf("%d signals pending, want the requests merged into 1", len(c.trigger))
+	}
+	<-c.trigger
+	c.check(ctx, c.takeRequest())
+	cr, _ = client.Get(ctx, rule.Name)
+	if cr.Status.LastProbeTime.Equal(&old) || cr.Status.LastDuration == nil {
+		t.Errorf("requested check not written: %+v", cr.Status)
+	}
+	if cr.Status.LastRunRequest != "second" {
+		t.Errorf("served request %q, want the latest one", cr.Status.LastRunRequest)
+	}
+}
+
+func TestRequestRun(t *testing.T) {
+	r := &runningProbe{ctrl: New(nil, NewMemoryClient(), config.GateRule{Name: "p"}, &stubProber{}, nil)}
+	probe := func(runNow, served string) *v1alpha1.Probe {
+		p := &v1alpha1.Probe{ObjectMeta: metav1.ObjectMeta{Name: "p", Annotations: map[string]string{}}}
+		if runNow != "" {
+			p.Annotations[v1alpha1.AnnotationRunNow] = runNow
+		}
+		p.Status.LastRunRequest = served
+		return p
+	}
+
+	// Served before a restart
+	r.requestRun(probe("t1", "t1"))
+	if id := r.ctrl.takeRequest(); id != "" {
+		t.Errorf("served request %s triggered again", id)
+	}
+	r.requestRun(probe("t2", "t1"))
+	if id := r.ctrl.takeRequest(); id != "t2" {
+		t.Errorf("pending request = %q, want t2", id)
+	}
+	// The status update of the check comes back with the same annotation
+	r.requestRun(probe("t2", ""))
+	if id := r.ctrl.takeRequest(); id != "" {
+		t.Errorf("request %s triggered twice", id)
+	}
+}
+
+func TestNextCheck(t *testing.T) {
+	now := time.Date(2026, 3, 16, 10, 7, 30, 0, time.UTC) // a Monday
+	c := &ReadinessController{interval: 30 * time.Second}
+	if got, want := c.nextCheck(time.Time{}, now), now.Add(30*time.Second); !got.Equal(want) {
+		t.Errorf("first check at %s; want %s", got, want)
+	}
+	if got, want := c.nextCheck(now.Add(-10*time.Second), now), now.Add(20*time.Second); !got.Equal(want) {
+		t.Errorf("next check at %s; want %s, keeping the rate", got, want)
+	}
+	if got, want := c.nextCheck(now.Add(-time.Minute), now), now.Add(30*time.Second); !got.Equal(want) {
+		t.Errorf("next check after a slow one at %s; want %s", got, want)
+	}
+
+	set, err := parseSchedule("*/15 9-17 * * mon-fri; 0 */4 * * *", "Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.schedule = set
+	if got, want := c.nextCheck(now, now), time.Date(2026, 3, 16, 10, 15, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("business hours check at %s; want %s", got, want)
+	}
+	night := time.Date(2026, 3, 16, 19, 0, 0, 0, time.UTC)
+	if got, want := c.nextCheck(night, night), time.Date(2026, 3, 16, 23, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("night check at %s; want %s", got, want)
+	}
+	if _, err := parseSchedule("@daily", "Mars/Olympus"); err == nil {
+		t.Error("unknown time zone accepted")
+	}
+}
+
+func TestScheduledCheckWritesNextProbeTime(t *testing.T) {
+	ctx := context.Background()
+	client := NewMemoryClient()
+	rule := config.GateRule{Name: "synthetic-order", Namespace: "shop", CheckType: "http", CheckTarget: "https://shop.example.com", Schedule: "@hourly"}
+	c := New(nil, client, rule, &stubProber{healthy: true}, nil)
+	if err := c.ensureCR(ctx); err != nil {
+		t.Fatalf("ensureCR: %v", err)
+	}
+	c.schedule, _ = parseSchedule(rule.Schedule, "")
+	c.due = c.nextCheck(time.Time{}, time.Now())
+
+	c.recordNextProbeTime(ctx)
+	cr, _ := client.Get(ctx, rule.Name)
+	if cr.Status.NextProbeTime == nil || !cr.Status.NextProbeTime.Time.Equal(c.due) || cr.Status.LastProbeTime != nil {
+		t.Fatalf("status before the first check = %+v; want only nextProbeTime %s", cr.Status, c.due)
+	}
+
+	// A new due time is written even when the result did not change
+	c.reconcile(ctx)
+	c.due = c.due.Add(time.Hour)
+	c.reconcile(ctx)
+	cr, _ = client.Get(ctx, rule.Name)
+	if !cr.Status.NextProbeTime.Time.Equal(c.due) {
+		t.Errorf("nextProbeTime = %s; want %s", cr.Status.NextProbeTime, c.due)
+	}
+}
+
+// flakyProber fails its first failures checks.
+type flakyProber struct{ failures, calls int }
+
+func (p *flakyProber) Check() bool {
+	p.calls++
+	return p.calls > p.failures
+}
+
+func TestRetriedCheckRecordsAttempts(t *testing.T) {
+	ctx := context.Background()
+	client := NewMemoryClient()
+	rule := config.GateRule{Name: "vpn", Namespace: "ops", CheckType: "tcp", CheckTarget: "vpn:443", Interval: "10s",
+		Retries: &config.Retries{Count: 2, Delay: "1ms"}}
+	c := New(nil, client, rule, &flakyProber{failures: 2}, nil)
+	c.reconcile(ctx)
+
+	cr, _ := client.Get(ctx, rule.Name)
+	if !cr.Status.Healthy || cr.Status.Attempts != 3 {
+		t.Errorf("status = healthy %v after %d attempts; want a pass after 3", cr.Status.Healthy, cr.Status.Attempts)
+	}
+	if cr.Spec.Retries == nil || cr.Spec.Retries.Count != 2 {
+		t.Errorf("retries of the rule not set on its CR: %+v", cr.Spec.Retries)
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	ctx := context.Background()
+	client := NewMemoryClient()
+	rule := config.GateRule{Name: "flappy", Namespace: "ops", CheckType: "tcp", CheckTarget: "db:5432", Interval: "1h",
+		SuccessThreshold: 2, FailureThreshold: 3}
+	p := &stubProber{healthy: true}
+	c := New(nil, client, rule, p, nil)
+
+	steps := []struct {
+		healthy bool
+		want    bool
+		message string
+	}{
+		{true, true, "Check passed"},
+		{false, true, "Check failed (1/3 checks failed)"},
+		{true, true, "Check passed"},
+		{false, true, "Check failed (1/3 checks failed)"},
+		{false, true, "Check failed (2/3 checks failed)"},
+		{false, false, "Check failed"},
+		{true, false, "Check passed (1/2 checks passed)"},
+		{true, true, "Check passed"},
+	}
+	for i, s := range steps {
+		p.healthy = s.healthy
+		c.reconcile(ctx)
+		cr, _ := client.Get(ctx, rule.Name)
+		if cr.Status.Healthy != s.want || cr.Status.Message != s.message {
+			t.Errorf("check %d: healthy %v, %q; want %v, %q", i, cr.Status.Healthy, cr.Status.Message, s.want, s.message)
+		}
+	}
+	if cr, _ := client.Get(ctx, rule.Name); cr.Spec.FailureThreshold != 3 {
+		t.Errorf("thresholds of the rule not set on its CR: %+v", cr.Spec)
+	}
+}
@@ -5,16 +5,39 @@ import (
 
 	"heartbeat-operator/api/v1alpha1"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 )
 
+// ProbeClient reads and writes the custom resource backing a rule.
+// ClusterProbes are exchanged as Probes, they share spec and status.
+type ProbeClient interface {
+	Get(ctx context.Context, name string) (*v1alpha1.Probe, error)
+	Create(ctx context.Context, check *v1alpha1.Probe) (*v1alpha1.Probe, error)
+	UpdateStatus(ctx context.Context, check *v1alpha1.Probe) (*v1alpha1.Probe, error)
+}
+
 type CrdClient struct {
 	restClient rest.Interface
 	ns         string
 }
 
 func NewCrdClient(config *rest.Config, namespace string) (*CrdClient, error) {
+	client, err := newRestClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CrdClient{
+		restClient: client,
+		ns:         namespace,
+	}, nil
+}
+
+func newRestClient(config *rest.Config) (rest.Interface, error) {
 	if err := v1alpha1.AddToScheme(scheme.Scheme); err != nil {
 		return nil, err
 	}
@@ -25,15 +48,33 @@ func NewCrdClient(config *rest.Config, namespace string) (*CrdClient, error) {
 	crdConfig.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
 	crdConfig.UserAgent = rest.DefaultKubernetesUserAgent()
 
-	client, err := rest.RESTClientFor(&crdConfig)
-	if err != nil {
-		return nil, err
-	}
+	return rest.RESTClientFor(&crdConfig)
+}
 
-	return &CrdClient{
-		restClient: client,
-		ns:         namespace,
-	}, nil
+// InNamespace returns a client for namespace sharing the same connection.
+func (c *CrdClient) InNamespace(namespace string) *CrdClient {
+	return &CrdClient{restClient: c.restClient, ns: namespace}
+}
+
+// ListWatch lists and watches the Probes of the client namespace matching
+// the label selector, of all namespaces when the client was created for
+// metav1.NamespaceAll. An empty selector matches all Probes.
+func (c *CrdClient) ListWatch(selector string) *cache.ListWatch {
+	return cache.NewFilteredListWatchFromClient(c.restClient, "probes", c.ns, func(o *metav1.ListOptions) {
+		o.LabelSelector = selector
+	})
+}
+
+// List returns the Probes of the client namespace matching selector.
+func (c *CrdClient) List(ctx context.Context, selector labels.Selector) (*v1alpha1.ProbeList, error) {
+	result := &v1alpha1.ProbeList{}
+	err := c.restClient.Get().
+		Namespace(c.ns).
+		Resource("probes").
+		VersionedParams(&metav1.ListOptions{LabelSelector: selector.String()}, metav1.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return result, err
 }
 
 func (c *CrdClient) Create(ctx context.Context, check *v1alpha1.Probe) (*v1alpha1.Probe, error) {
@@ -47,6 +88,28 @@ func (c *CrdClient) Create(ctx context.Context, check *v1alpha1.Probe) (*v1alpha
 	return result, err
 }
 
+// Update replaces the spec and metadata of a Probe.
+func (c *CrdClient) Update(ctx context.Context, check *v1alpha1.Probe) (*v1alpha1.Probe, error) {
+	result := &v1alpha1.Probe{}
+	err := c.restClient.Put().
+		Namespace(c.ns).
+		Resource("probes").
+		Name(check.Name).
+		Body(check).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+func (c *CrdClient) Delete(ctx context.Context, name string) error {
+	return c.restClient.Delete().
+		Namespace(c.ns).
+		Resource("probes").
+		Name(name).
+		Do(ctx).
+		Error()
+}
+
 func (c *CrdClient) Get(ctx context.Context, name string) (*v1alpha1.Probe, error) {
 	result := &v1alpha1.Probe{}
 	err := c.restClient.Get().
@@ -70,3 +133,149 @@ func (c *CrdClient) UpdateStatus(ctx context.Context, check *v1alpha1.Probe) (*v
 		Into(result)
 	return result, err
 }
+
+// ClusterCrdClient is the ProbeClient of the cluster-scoped ClusterProbe kind.
+type ClusterCrdClient struct {
+	restClient rest.Interface
+}
+
+func NewClusterCrdClient(config *rest.Config) (*ClusterCrdClient, error) {
+	client, err := newRestClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterCrdClient{restClient: client}, nil
+}
+
+// ListWatch lists and watches the ClusterProbes matching the label selector.
+func (c *ClusterCrdClient) ListWatch(selector string) *cache.ListWatch {
+	return cache.NewFilteredListWatchFromClient(c.restClient, "clusterprobes", "", func(o *metav1.ListOptions) {
+		o.LabelSelector = selector
+	})
+}
+
+func (c *ClusterCrdClient) Create(ctx context.Context, check *v1alpha1.Probe) (*v1alpha1.Probe, error) {
+	result := &v1alpha1.ClusterProbe{}
+	err := c.restClient.Post().
+		Resource("clusterprobes").
+		Body(toClusterProbe(check)).
+		Do(ctx).
+		Into(result)
+	return fromClusterProbe(result), err
+}
+
+func (c *ClusterCrdClient) Get(ctx context.Context, name string) (*v1alpha1.Probe, error) {
+	result := &v1alpha1.ClusterProbe{}
+	err := c.restClient.Get().
+		Resource("clusterprobes").
+		Name(name).
+		Do(ctx).
+		Into(result)
+	return fromClusterProbe(result), err
+}
+
+func (c *ClusterCrdClient) UpdateStatus(ctx context.Context, check *v1alpha1.Probe) (*v1alpha1.Probe, error) {
+	result := &v1alpha1.ClusterProbe{}
+	err := c.restClient.Put().
+		Resource("clusterprobes").
+		Name(check.Name).
+		SubResource("status").
+		Body(toClusterProbe(check)).
+		Do(ctx).
+		Into(result)
+	return fromClusterProbe(result), err
+}
+
+func toClusterProbe(p *v1alpha1.Probe) *v1alpha1.ClusterProbe {
+	return &v1alpha1.ClusterProbe{
+		ObjectMeta: p.ObjectMeta,
+		Spec:       p.Spec,
+		Status:     p.Status,
+	}
+}
+
+func fromClusterProbe(cp *v1alpha1.ClusterProbe) *v1alpha1.Probe {
+	return &v1alpha1.Probe{
+		ObjectMeta: cp.ObjectMeta,
+		Spec:       cp.Spec,
+		Status:     cp.Status,
+	}
+}
+
+// GroupClient reads ProbeGroups and writes their status.
+type GroupClient struct {
+	restClient rest.Interface
+}
+
+func NewGroupClient(config *rest.Config) (*GroupClient, error) {
+	client, err := newRestClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &GroupClient{restClient: client}, nil
+}
+
+// List returns the ProbeGroups of namespace, all when it is
+// metav1.NamespaceAll, matching the label selector.
+func (c *GroupClient) List(ctx context.Context, namespace, selector string) (*v1alpha1.ProbeGroupList, error) {
+	result := &v1alpha1.ProbeGroupList{}
+	err := c.restClient.Get().
+		Namespace(namespace).
+		Resource("probegroups").
+		VersionedParams(&metav1.ListOptions{LabelSelector: selector}, metav1.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+func (c *GroupClient) UpdateStatus(ctx context.Context, group *v1alpha1.ProbeGroup) (*v1alpha1.ProbeGroup, error) {
+	result := &v1alpha1.ProbeGroup{}
+	err := c.restClient.Put().
+		Namespace(group.Namespace).
+		Resource("probegroups").
+		Name(group.Name).
+		SubResource("status").
+		Body(group).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+// MaintenanceClient reads MaintenanceWindows and writes their status.
+type MaintenanceClient struct {
+	restClient rest.Interface
+}
+
+func NewMaintenanceClient(config *rest.Config) (*MaintenanceClient, error) {
+	client, err := newRestClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &MaintenanceClient{restClient: client}, nil
+}
+
+// List returns the MaintenanceWindows of namespace, all when it is
+// metav1.NamespaceAll, matching the label selector.
+func (c *MaintenanceClient) List(ctx context.Context, namespace, selector string) (*v1alpha1.MaintenanceWindowList, error) {
+	result := &v1alpha1.MaintenanceWindowList{}
+	err := c.restClient.Get().
+		Namespace(namespace).
+		Resource("maintenancewindows").
+		VersionedParams(&metav1.ListOptions{LabelSelector: selector}, metav1.ParameterCodec).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
+
+func (c *MaintenanceClient) UpdateStatus(ctx context.Context, window *v1alpha1.MaintenanceWindow) (*v1alpha1.MaintenanceWindow, error) {
+	result := &v1alpha1.MaintenanceWindow{}
+	err := c.restClient.Put().
+		Namespace(window.Namespace).
+		Resource("maintenancewindows").
+		Name(window.Name).
+		SubResource("status").
+		Body(window).
+		Do(ctx).
+		Into(result)
+	return result, err
+}
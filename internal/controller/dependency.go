@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/internal/config"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// health holds the last check result and dependencies of every running
+// rule, by config.GateRule Key, so children can tell when to suppress.
+var health = newHealthRegistry()
+
+type healthRegistry struct {
+	mu     sync.RWMutex
+	states map[string]probeHealth
+}
+
+type probeHealth struct {
+	healthy   bool
+	dependsOn []string
+}
+
+func newHealthRegistry() *healthRegistry {
+	return &healthRegistry{states: make(map[string]probeHealth)}
+}
+
+func (h *healthRegistry) set(key string, healthy bool, dependsOn []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.states[key] = probeHealth{healthy: healthy, dependsOn: dependsOn}
+}
+
+func (h *healthRegistry) remove(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.states, key)
+}
+
+// failingAncestor returns the closest rule among the dependencies of key,
+// direct or transitive, whose last check failed. Rules that are not running
+// yet count as healthy, and a dependency cycle is walked only once.
+func (h *healthRegistry) failingAncestor(key string) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	visited := map[string]bool{key: true}
+	queue := append([]string(nil), h.states[key].dependsOn...)
+	for len(queue) > 0 {
+		dep := queue[0]
+		queue = queue[1:]
+		if visited[dep] {
+			continue
+		}
+		visited[dep] = true
+
+		state, ok := h.states[dep]
+		if !ok {
+			continue
+		}
+		if !state.healthy {
+			return dep
+		}
+		queue = append(queue, state.dependsOn...)
+	}
+	return ""
+}
+
+// NewDependencyLookup returns a function that reads the dependencies of a
+// stored Probe or ClusterProbe by config.GateRule Key, for the webhook cycle
+// check. probes must be a client for all namespaces.
+func NewDependencyLookup(probes *CrdClient, clusterProbes *ClusterCrdClient) func(ctx context.Context, key string) ([]string, error) {
+	return func(ctx context.Context, key string) ([]string, error) {
+		parts := strings.Split(key, "/")
+		var p *v1alpha1.Probe
+		var err error
+		switch {
+		case len(parts) == 2 && parts[0] == config.KindClusterProbe:
+			p, err = clusterProbes.Get(ctx, parts[1])
+		case len(parts) == 3 && parts[0] == config.KindProbe:
+			p, err = probes.InNamespace(parts[1]).Get(ctx, parts[2])
+		default:
+			return nil, fmt.Errorf("malformed probe key %q", key)
+		}
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return ruleFromProbe(parts[0], p).DependencyKeys(), nil
+	}
+}
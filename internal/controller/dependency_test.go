@@ -0,0 +1,36 @@
+package controller
+
+import "testing"
+
+func TestFailingAncestor(t *testing.T) {
+	h := newHealthRegistry()
+	h.set("ClusterProbe/egress", false, nil)
+	h.set("Probe/shop/proxy", true, []string{"ClusterProbe/egress"})
+	h.set("Probe/shop/payments", false, []string{"Probe/shop/proxy"})
+	h.set("Probe/shop/db", false, []string{"Probe/shop/unknown"})
+	h.set("Probe/shop/a", false, []string{"Probe/shop/b"})
+	h.set("Probe/shop/b", true, []string{"Probe/shop/a"})
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"ClusterProbe/egress", ""},
+		{"Probe/shop/proxy", "ClusterProbe/egress"},
+		{"Probe/shop/payments", "ClusterProbe/egress"},
+		{"Probe/shop/db", ""}, // parent not running yet
+		{"Probe/shop/a", ""},
+		{"Probe/shop/b", "Probe/shop/a"},
+	}
+
+	for _, tt := range tests {
+		if got := h.failingAncestor(tt.key); got != tt.expected {
+			t.Errorf("failingAncestor(%q) = %q; want %q", tt.key, got, tt.expected)
+		}
+	}
+
+	h.remove("ClusterProbe/egress")
+	if got := h.failingAncestor("Probe/shop/payments"); got != "" {
+		t.Errorf("failingAncestor after remove = %q; want none", got)
+	}
+}
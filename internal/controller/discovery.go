@@ -0,0 +1,420 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/internal/config"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// Annotations that opt a Service, Ingress or HTTPRoute into discovery, in
+// the spirit of the prometheus.io/scrape annotations.
+const (
+	AnnotationEnabled   = "heartbeat.probes.ready.io/enabled"
+	AnnotationPath      = "heartbeat.probes.ready.io/path"
+	AnnotationPort      = "heartbeat.probes.ready.io/port"
+	AnnotationScheme    = "heartbeat.probes.ready.io/scheme"
+	AnnotationInterval  = "heartbeat.probes.ready.io/interval"
+	AnnotationCheckType = "heartbeat.probes.ready.io/type"
+	// AnnotationEndpoints probes every endpoint of a Service: "All", "Any"
+	// or a percentage such as "75%".
+	AnnotationEndpoints = "heartbeat.probes.ready.io/endpoints"
+)
+
+// LabelDiscoveredFrom marks the Probes created by discovery with the kind of
+// the object they were discovered from.
+const LabelDiscoveredFrom = "probes.ready.io/discovered-from"
+
+// discoverySource is a kind discovery watches.
+type discoverySource struct {
+	resource schema.GroupVersionResource
+	// prefix starts the names of the Probes created for it.
+	prefix string
+}
+
+// DiscoverySources are the kinds discovery can watch, by resource name.
+var DiscoverySources = map[string]discoverySource{
+	"services":   {resource: schema.GroupVersionResource{Version: "v1", Resource: "services"}, prefix: "svc"},
+	"ingresses":  {resource: schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}, prefix: "ingress"},
+	"httproutes": {resource: schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}, prefix: "httproute"},
+}
+
+// DiscoveryController creates a Probe for every annotated Service, Ingress
+// and HTTPRoute. The Probes are owned by the object they were created for,
+// so they are garbage collected with it, and are deleted when the
+// annotation is removed.
+type DiscoveryController struct {
+	client    dynamic.Interface
+	discovery discovery.DiscoveryInterface
+	probes    *CrdClient
+	scope     config.Scope
+	defaults  config.Defaults
+	recorder  record.EventRecorder
+	resources []string
+}
+
+// NewDiscoveryController creates a DiscoveryController for the given
+// resources, keys of DiscoverySources, in the namespaces of scope. The
+// Probes it creates carry the labels of the scope selector.
+func NewDiscoveryController(client dynamic.Interface, disc discovery.DiscoveryInterface, probes *CrdClient, scope config.Scope, defaults config.Defaults, recorder record.EventRecorder, resources []string) (*DiscoveryController, error) {
+	for _, r := range resources {
+		if _, ok := DiscoverySources[r]; !ok {
+			return nil, fmt.Errorf("discovery of %q is not supported", r)
+		}
+	}
+	return &DiscoveryController{
+		client:    client,
+		discovery: disc,
+		probes:    probes,
+		scope:     scope,
+		defaults:  defaults,
+		recorder:  recorder,
+		resources: resources,
+	}, nil
+}
+
+// Run watches the annotated objects until ctx is cancelled. Kinds the API
+// server does not serve, e.g. HTTPRoute without the Gateway API installed,
+// are skipped.
+func (d *DiscoveryController) Run(ctx context.Context) {
+	var factories []dynamicinformer.DynamicSharedInformerFactory
+	for _, ns := range d.scope.WatchedNamespaces() {
+		factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(d.client, 10*time.Minute, ns, nil)
+		d.watch(ctx, factory)
+		factory.Start(ctx.Done())
+		factories = append(factories, factory)
+	}
+	<-ctx.Done()
+	for _, factory := range factories {
+		factory.Shutdown()
+	}
+}
+
+// watch registers the handlers of the annotated kinds with factory.
+func (d *DiscoveryController) watch(ctx context.Context, factory dynamicinformer.DynamicSharedInformerFactory) {
+	for _, r := range d.resources {
+		src := DiscoverySources[r]
+		if !d.served(src.resource) {
+			log.Printf("[Discovery] %s are not served by the API server, not watching them", src.resource.GroupResource())
+			continue
+		}
+		log.Printf("[Discovery] Watching %s for %s annotations", src.resource.GroupResource(), AnnotationEnabled)
+		sync := func(obj interface{}) {
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				d.sync(ctx, src, u)
+			}
+		}
+		_, _ = factory.ForResource(src.resource).Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    sync,
+			UpdateFunc: func(_, obj interface{}) { sync(obj) },
+			// Deleted objects take their Probes with them through the owner reference
+		})
+	}
+}
+
+func (d *DiscoveryController) served(gvr schema.GroupVersionResource) bool {
+	if d.discovery == nil {
+		return true
+	}
+	list, err := d.discovery.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		return false
+	}
+	for _, r := range list.APIResources {
+		if r.Name == gvr.Resource {
+			return true
+		}
+	}
+	return false
+}
+
+// sync makes the Probes owned by obj match its annotations.
+func (d *DiscoveryController) sync(ctx context.Context, src discoverySource, obj *unstructured.Unstructured) {
+	desired, err := discoveredProbes(src, obj, d.defaults)
+	if err != nil {
+		log.Printf("[Discovery] %s %s/%s: %v", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+		if d.recorder != nil {
+			d.recorder.Eventf(obj, corev1.EventTypeWarning, "DiscoveryFailed", "Cannot create a Probe: %v", err)
+		}
+		return
+	}
+
+	client := d.probes.InNamespace(obj.GetNamespace())
+	selector := labels.SelectorFromSet(labels.Set{LabelDiscoveredFrom: strings.ToLower(obj.GetKind())})
+	existing, err := client.List(ctx, selector)
+	if err != nil {
+		log.Printf("[Discovery] Failed to list Probes of %s/%s: %v", obj.GetNamespace(), obj.GetName(), err)
+		return
+	}
+
+	owned := map[string]*v1alpha1.Probe{}
+	for i := range existing.Items {
+		p := &existing.Items[i]
+		if metav1.IsControlledBy(p, obj) {
+			owned[p.Name] = p
+		}
+	}
+
+	for _, want := range desired {
+		have, ok := owned[want.Name]
+		delete(owned, want.Name)
+		if !ok {
+			want.Labels = d.scope.AddLabels(want.Labels)
+			if _, err := client.Create(ctx, want); err != nil {
+				log.Printf("[Discovery] Failed to create Probe %s/%s: %v", want.Namespace, want.Name, err)
+				continue
+			}
+			log.Printf("[Discovery] Created Probe %s/%s for %s %s", want.Namespace, want.Name, obj.GetKind(), obj.GetName())
+			continue
+		}
+		if !setDiscoveredSpec(&have.Spec, &want.Spec) {
+			continue
+		}
+		if _, err := client.Update(ctx, have); err != nil {
+			log.Printf("[Discovery] Failed to update Probe %s/%s: %v", have.Namespace, have.Name, err)
+			continue
+		}
+		log.Printf("[Discovery] Updated Probe %s/%s", have.Namespace, have.Name)
+	}
+
+	// Left over: the annotation was removed, or a host went away
+	for name := range owned {
+		if err := client.Delete(ctx, name); err != nil {
+			log.Printf("[Discovery] Failed to delete Probe %s/%s: %v", obj.GetNamespace(), name, err)
+			continue
+		}
+		log.Printf("[Discovery] Deleted Probe %s/%s", obj.GetNamespace(), name)
+	}
+}
+
+// discoveredProbes returns the Probes the annotations of obj ask for, none
+// when it is not enabled. Ingresses and HTTPRoutes get one Probe per host.
+func discoveredProbes(src discoverySource, obj *unstructured.Unstructured, defaults config.Defaults) ([]*v1alpha1.Probe, error) {
+	ann := obj.GetAnnotations()
+	if enabled, _ := strconv.ParseBool(ann[AnnotationEnabled]); !enabled {
+		return nil, nil
+	}
+
+	checkType := ann[AnnotationCheckType]
+	if checkType == "" {
+		checkType = "http"
+	}
+	if checkType != "http" && checkType != "tcp" {
+		return nil, fmt.Errorf("%s must be http or tcp, not %q", AnnotationCheckType, checkType)
+	}
+	interval := defaults.Interval.String()
+	if v := ann[AnnotationInterval]; v != "" {
+		if _, err := time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("%s: %v", AnnotationInterval, err)
+		}
+		interval = v
+	}
+	path := ann[AnnotationPath]
+	if path == "" {
+		path = "/"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	var targets []string
+	var options json.RawMessage
+	switch obj.GetKind() {
+	case "Service":
+		var svc corev1.Service
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &svc); err != nil {
+			return nil, err
+		}
+		port, err := servicePort(&svc, ann[AnnotationPort])
+		if err != nil {
+			return nil, err
+		}
+		host := fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace)
+		targets = []string{target(checkType, schemeOf(ann, "http"), host, strconv.Itoa(int(port)), path)}
+		if v := ann[AnnotationEndpoints]; v != "" {
+			if options, err = endpointOptions(v); err != nil {
+				return nil, err
+			}
+		}
+	case "Ingress":
+		var ing networkingv1.Ingress
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &ing); err != nil {
+			return nil, err
+		}
+		tls := map[string]bool{}
+		for _, t := range ing.Spec.TLS {
+			for _, h := range t.Hosts {
+				tls[h] = true
+			}
+		}
+		for _, rule := range ing.Spec.Rules {
+			scheme := "http"
+			if tls[rule.Host] {
+				scheme = "https"
+			}
+			targets = appendHost(targets, checkType, schemeOf(ann, scheme), rule.Host, ann[AnnotationPort], path)
+		}
+	case "HTTPRoute":
+		hostnames, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "hostnames")
+		for _, h := range hostnames {
+			targets = appendHost(targets, checkType, schemeOf(ann, "http"), h, ann[AnnotationPort], path)
+		}
+	default:
+		return nil, fmt.Errorf("discovery of %s is not supported", obj.GetKind())
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no host to probe, wildcard hosts are skipped")
+	}
+
+	gvk := obj.GroupVersionKind()
+	var probes []*v1alpha1.Probe
+	for i, t := range targets {
+		name := src.prefix + "-" + obj.GetName()
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d", name, i)
+		}
+		p := &v1alpha1.Probe{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            name,
+				Namespace:       obj.GetNamespace(),
+				Labels:          map[string]string{LabelDiscoveredFrom: strings.ToLower(gvk.Kind)},
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(obj, gvk)},
+			},
+			Spec: v1alpha1.ProbeSpec{
+				CheckType:   checkType,
+				CheckTarget: t,
+				Interval:    interval,
+			},
+		}
+		if options != nil {
+			p.Spec.Options = &runtime.RawExtension{Raw: options}
+		}
+		probes = append(probes, p)
+	}
+	return probes, nil
+}
+
+// setDiscoveredSpec copies the fields discovery owns from want to have and
+// reports whether one of them changed. The rest, such as the timeout the
+// webhook defaults, is left as it is.
+func setDiscoveredSpec(have, want *v1alpha1.ProbeSpec) bool {
+	if have.CheckType == want.CheckType && have.CheckTarget == want.CheckTarget &&
+		have.Interval == want.Interval && sameOptions(have.Options, want.Options) {
+		return false
+	}
+	have.CheckType = want.CheckType
+	have.CheckTarget = want.CheckTarget
+	have.Interval = want.Interval
+	have.Options = want.Options
+	return true
+}
+
+// sameOptions compares options by their JSON value, the API server does not
+// keep the encoding they were written with.
+func sameOptions(a, b *runtime.RawExtension) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var av, bv interface{}
+	if json.Unmarshal(a.Raw, &av) != nil || json.Unmarshal(b.Raw, &bv) != nil {
+		return false
+	}
+	return equality.Semantic.DeepEqual(av, bv)
+}
+
+func schemeOf(ann map[string]string, fallback string) string {
+	if s := ann[AnnotationScheme]; s != "" {
+		return s
+	}
+	return fallback
+}
+
+// appendHost adds the target of an Ingress or HTTPRoute host. Wildcard and
+// empty hosts have nothing to resolve and are skipped, as are duplicates.
+func appendHost(targets []string, checkType, scheme, host, port, path string) []string {
+	if host == "" || strings.HasPrefix(host, "*") {
+		return targets
+	}
+	if port == "" {
+		port = "80"
+		if scheme == "https" {
+			port = "443"
+		}
+	}
+	t := target(checkType, scheme, host, port, path)
+	for _, existing := range targets {
+		if existing == t {
+			return targets
+		}
+	}
+	return append(targets, t)
+}
+
+func target(checkType, scheme, host, port, path string) string {
+	if checkType == "tcp" {
+		return net.JoinHostPort(host, port)
+	}
+	u := url.URL{Scheme: scheme, Host: net.JoinHostPort(host, port), Path: path}
+	if (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		u.Host = host
+	}
+	return u.String()
+}
+
+// servicePort picks the port named or numbered by the annotation, the
+// first port of the Service without one.
+func servicePort(svc *corev1.Service, annotation string) (int32, error) {
+	if len(svc.Spec.Ports) == 0 {
+		return 0, fmt.Errorf("service has no ports")
+	}
+	if annotation == "" {
+		return svc.Spec.Ports[0].Port, nil
+	}
+	for _, p := range svc.Spec.Ports {
+		if p.Name == annotation || strconv.Itoa(int(p.Port)) == annotation {
+			return p.Port, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: service has no port %q", AnnotationPort, annotation)
+}
+
+// endpointOptions turns the endpoints annotation into check options.
+func endpointOptions(v string) (json.RawMessage, error) {
+	fanOut := map[string]interface{}{}
+	switch {
+	case v == "All" || v == "Any":
+		fanOut["policy"] = v
+	case strings.HasSuffix(v, "%"):
+		n, err := strconv.Atoi(strings.TrimSuffix(v, "%"))
+		if err != nil || n < 1 || n > 100 {
+			return nil, fmt.Errorf("%s: %q is not a percentage between 1%% and 100%%", AnnotationEndpoints, v)
+		}
+		fanOut["policy"] = "Percentage"
+		fanOut["minHealthyPercent"] = n
+	default:
+		return nil, fmt.Errorf("%s must be All, Any or a percentage, not %q", AnnotationEndpoints, v)
+	}
+	return json.Marshal(map[string]interface{}{"endpoints": fanOut})
+}
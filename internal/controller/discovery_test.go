@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/internal/config"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func annotated(apiVersion, kind, name string, annotations map[string]interface{}, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"namespace":   "shop",
+			"name":        name,
+			"uid":         "uid-" + name,
+			"annotations": annotations,
+		},
+		"spec": spec,
+	}}
+}
+
+func TestDiscoveredProbes(t *testing.T) {
+	defaults := config.Defaults{Interval: 30 * time.Second}
+	ports := map[string]interface{}{"ports": []interface{}{
+		map[string]interface{}{"name": "http", "port": int64(80)},
+		map[string]interface{}{"name": "admin", "port": int64(9000)},
+	}}
+
+	tests := []struct {
+		name     string
+		source   string
+		obj      *unstructured.Unstructured
+		targets  []string
+		interval string
+		options  string
+		err      string
+	}{
+		{
+			name:   "not annotated",
+			source: "services",
+			obj:    annotated("v1", "Service", "api", nil, ports),
+		},
+		{
+			name:     "service on its first port",
+			source:   "services",
+			obj:      annotated("v1", "Service", "api", map[string]interface{}{AnnotationEnabled: "true", AnnotationPath: "healthz"}, ports),
+			targets:  []string{"http://api.shop.svc/healthz"},
+			interval: "30s",
+		},
+		{
+			name:   "service named port fanned out",
+			source: "services",
+			obj: annotated("v1", "Service", "api", map[string]interface{}{
+				AnnotationEnabled: "true", AnnotationPort: "admin", AnnotationInterval: "10s", AnnotationEndpoints: "75%",
+			}, ports),
+			targets:  []string{"http://api.shop.svc:9000/"},
+			interval: "10s",
+			options:  `{"endpoints":{"minHealthyPercent":75,"policy":"Percentage"}}`,
+		},
+		{
+			name:     "service tcp",
+			source:   "services",
+			obj:      annotated("v1", "Service", "db", map[string]interface{}{AnnotationEnabled: "true", AnnotationCheckType: "tcp"}, ports),
+			targets:  []string{"db.shop.svc:80"},
+			interval: "30s",
+		},
+		{
+			name:   "service unknown port",
+			source: "services",
+			obj:    annotated("v1", "Service", "api", map[string]interface{}{AnnotationEnabled: "true", AnnotationPort: "grpc"}, ports),
+			err:    "no port",
+		},
+		{
+			name:   "ingress hosts with tls",
+			source: "ingresses",
+			obj: annotated("networking.k8s.io/v1", "Ingress", "shop", map[string]interface{}{AnnotationEnabled: "true", AnnotationPath: "/ping"}, map[string]interface{}{
+				"tls": []interface{}{map[string]interface{}{"hosts": []interface{}{"shop.example.com"}}},
+				"rules": []interface{}{
+					map[string]interface{}{"host": "shop.example.com"},
+					map[string]interface{}{"host": "*.example.com"},
+					map[string]interface{}{"host": "legacy.example.com"},
+				},
+			}),
+			targets:  []string{"https://shop.example.com/ping", "http://legacy.example.com/ping"},
+			interval: "30s",
+		},
+		{
+			name:   "httproute hostnames",
+			source: "httproutes",
+			obj: annotated("gateway.networking.k8s.io/v1", "HTTPRoute", "shop", map[string]interface{}{AnnotationEnabled: "true", AnnotationScheme: "https"}, map[string]interface{}{
+				"hostnames": []interface{}{"shop.example.com"},
+			}),
+			targets:  []string{"https://shop.example.com/"},
+			interval: "30s",
+		},
+		{
+			name:   "httproute without hostnames",
+			source: "httproutes",
+			obj:    annotated("gateway.networking.k8s.io/v1", "HTTPRoute", "shop", map[string]interface{}{AnnotationEnabled: "true"}, map[string]interface{}{}),
+			err:    "no host",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := DiscoverySources[tt.source]
+			probes, err := discoveredProbes(src, tt.obj, defaults)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v; want it to contain %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("discoveredProbes: %v", err)
+			}
+			if len(probes) != len(tt.targets) {
+				t.Fatalf("got %d probes; want %d", len(probes), len(tt.targets))
+			}
+			for i, p := range probes {
+				if p.Spec.CheckTarget != tt.targets[i] {
+					t.Errorf("probe %d target = %q; want %q", i, p.Spec.CheckTarget, tt.targets[i])
+				}
+				if p.Spec.Interval != tt.interval {
+					t.Errorf("probe %d interval = %q; want %q", i, p.Spec.Interval, tt.interval)
+				}
+				var options string
+				if p.Spec.Options != nil {
+					options = string(p.Spec.Options.Raw)
+				}
+				if options != tt.options {
+					t.Errorf("probe %d options = %s; want %s", i, options, tt.options)
+				}
+				if len(p.OwnerReferences) != 1 || p.OwnerReferences[0].UID != tt.obj.GetUID() {
+					t.Errorf("probe %d owner references = %+v; want the %s", i, p.OwnerReferences, tt.obj.GetKind())
+				}
+				if p.Labels[LabelDiscoveredFrom] != strings.ToLower(tt.obj.GetKind()) {
+					t.Errorf("probe %d labels = %v", i, p.Labels)
+				}
+			}
+			if len(probes) > 1 && probes[0].Name == probes[1].Name {
+				t.Errorf("probes share the name %s", probes[0].Name)
+			}
+		})
+	}
+}
+
+func TestSetDiscoveredSpec(t *testing.T) {
+	want := v1alpha1.ProbeSpec{
+		CheckType:   "http",
+		CheckTarget: "http://api.shop.svc:80/",
+		Interval:    "30s",
+		Options:     &runtime.RawExtension{Raw: []byte(`{"endpoints":{"policy":"Any"}}`)},
+	}
+	// As read back: defaulted by the webhook, options re-encoded
+	have := want
+	have.Timeout = "2s"
+	have.FailureThreshold = 3
+	have.Options = &runtime.RawExtension{Raw: []byte(`{ "endpoints": { "policy": "Any" } }`)}
+	if setDiscoveredSpec(&have, &want) {
+		t.Errorf("defaulted Probe reported as changed")
+	}
+
+	want.Interval = "1m"
+	if !setDiscoveredSpec(&have, &want) {
+		t.Fatalf("changed interval not reported")
+	}
+	if have.Interval != "1m" || have.Timeout != "2s" || have.FailureThreshold != 3 {
+		t.Errorf("spec = %+v; want the new interval and the defaulted fields kept", have)
+	}
+}
@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/internal/config"
+	"heartbeat-operator/internal/metrics"
+	"heartbeat-operator/internal/ui"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GroupController evaluates every ProbeGroup from the status of its member
+// Probes and publishes the aggregate to the group status, metrics and UI.
+type GroupController struct {
+	groups   *GroupClient
+	probes   *CrdClient
+	scope    config.Scope
+	interval time.Duration
+}
+
+// NewGroupController creates a GroupController for the ProbeGroups in scope.
+// probes is narrowed to the namespace of each group.
+func NewGroupController(groups *GroupClient, probes *CrdClient, scope config.Scope, interval time.Duration) *GroupController {
+	return &GroupController{
+		groups:   groups,
+		probes:   probes,
+		scope:    scope,
+		interval: interval,
+	}
+}
+
+func (c *GroupController) Start(ctx context.Context) {
+	log.Printf("Evaluating ProbeGroups every %s", c.interval)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.reconcile(ctx)
+
+	for {
+		select {
+		case <-ticker.C:
+			c.reconcile(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *GroupController) reconcile(ctx context.Context) {
+	for _, ns := range c.scope.WatchedNamespaces() {
+		list, err := c.groups.List(ctx, ns, c.scope.SelectorString())
+		if err != nil {
+			log.Printf("Failed to list ProbeGroups: %v", err)
+			continue
+		}
+		for i := range list.Items {
+			c.evaluate(ctx, &list.Items[i])
+		}
+	}
+}
+
+func (c *GroupController) evaluate(ctx context.Context, g *v1alpha1.ProbeGroup) {
+	selector, err := metav1.LabelSelectorAsSelector(&g.Spec.Selector)
+	if err != nil {
+		log.Printf("[%s/%s] Invalid selector: %v", g.Namespace, g.Name, err)
+		return
+	}
+	members, err := c.probes.InNamespace(g.Namespace).List(ctx, selector)
+	if err != nil {
+		log.Printf("[%s/%s] Failed to list member Probes: %v", g.Namespace, g.Name, err)
+		return
+	}
+
+	res := evaluateGroup(&g.Spec, members.Items)
+	policy := groupPolicy(&g.Spec)
+
+	metrics.ProbeGroupSuccess.WithLabelValues(g.Name, g.Namespace, policy).Set(boolToFloat(res.healthy))
+	metrics.ProbeGroupMembers.WithLabelValues(g.Name, g.Namespace, "healthy").Set(float64(res.healthyMembers))
+	metrics.ProbeGroupMembers.WithLabelValues(g.Name, g.Namespace, "unhealthy").Set(float64(res.totalMembers - res.healthyMembers))
+	uiMembers := make([]ui.GroupMember, 0, len(res.members))
+	for _, m := range res.members {
+		uiMembers = append(uiMembers, ui.GroupMember{Name: m.Name, IsHealthy: m.Healthy})
+	}
+	ui.UpdateGroupState(g.Namespace, g.Name, policy, res.healthy, uiMembers, res.message)
+
+	status := g.Status.DeepCopy()
+	status.Healthy = res.healthy
+	status.HealthyMembers = res.healthyMembers
+	status.TotalMembers = res.totalMembers
+	status.Members = res.members
+	status.Message = res.message
+	cond := metav1.Condition{
+		Type:               "Healthy",
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: g.Generation,
+		Reason:             res.reason,
+		Message:            res.message,
+	}
+	if res.healthy {
+		cond.Status = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(&status.Conditions, cond)
+
+	changed := !reflect.DeepEqual(status.Members, g.Status.Members) ||
+		!reflect.DeepEqual(status.Conditions, g.Status.Conditions) ||
+		status.Healthy != g.Status.Healthy
+	if !changed && g.Status.LastEvaluationTime != nil && time.Since(g.Status.LastEvaluationTime.Time) < time.Minute {
+		return
+	}
+
+	now := metav1.Now()
+	status.LastEvaluationTime = &now
+	g.Status = *status
+	if _, err := c.groups.UpdateStatus(ctx, g); err != nil {
+		log.Printf("[%s/%s] Failed to update ProbeGroup status: %v", g.Namespace, g.Name, err)
+	} else if changed {
+		log.Printf("[%s/%s] Updated ProbeGroup status: healthy=%v (%s)", g.Namespace, g.Name, res.healthy, res.message)
+	}
+}
+
+type groupResult struct {
+	healthy        bool
+	healthyMembers int32
+	totalMembers   int32
+	members        []v1alpha1.ProbeGroupMember
+	reason         string
+	message        string
+}
+
+// evaluateGroup applies the group policy to the last known status of its
+// member Probes.
+func evaluateGroup(spec *v1alpha1.ProbeGroupSpec, probes []v1alpha1.Probe) groupResult {
+	res := groupResult{totalMembers: int32(len(probes))}
+
+	var weight, healthyWeight int64
+	for _, p := range probes {
+		res.members = append(res.members, v1alpha1.ProbeGroupMember{Name: p.Name, Healthy: p.Status.Healthy})
+		w := int64(1)
+		if v, ok := spec.Weights[p.Name]; ok {
+			w = int64(v)
+		}
+		weight += w
+		if p.Status.Healthy {
+			res.healthyMembers++
+			healthyWeight += w
+		}
+	}
+
+	if res.totalMembers == 0 {
+		res.reason = "NoMembers"
+		res.message = "No Probes match the selector"
+		return res
+	}
+
+	var need string
+	switch groupPolicy(spec) {
+	case v1alpha1.GroupPolicyAny:
+		res.healthy = res.healthyMembers > 0
+		need = "need 1"
+	case v1alpha1.GroupPolicyQuorum:
+		min := spec.MinHealthy
+		if min == 0 {
+			min = res.totalMembers/2 + 1
+		}
+		res.healthy = res.healthyMembers >= min
+		need = fmt.Sprintf("need %d", min)
+	case v1alpha1.GroupPolicyWeighted:
+		pct := int64(spec.MinHealthyPercent)
+		if pct == 0 {
+			pct = 50
+		}
+		res.healthy = weight > 0 && healthyWeight*100 >= pct*weight
+		need = fmt.Sprintf("%d/%d weight, need %d%%", healthyWeight, weight, pct)
+	default:
+		res.healthy = res.healthyMembers == res.totalMembers
+		need = fmt.Sprintf("need %d", res.totalMembers)
+	}
+
+	res.reason = "PolicyNotSatisfied"
+	if res.healthy {
+		res.reason = "PolicySatisfied"
+	}
+	res.message = fmt.Sprintf("%d/%d members healthy (%s, %s)", res.healthyMembers, res.totalMembers, groupPolicy(spec), need)
+	return res
+}
+
+func groupPolicy(spec *v1alpha1.ProbeGroupSpec) string {
+	if spec.Policy == "" {
+		return v1alpha1.GroupPolicyAll
+	}
+	return spec.Policy
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
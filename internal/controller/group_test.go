@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"testing"
+
+	"heartbeat-operator/api/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func members(healthy ...bool) []v1alpha1.Probe {
+	probes := make([]v1alpha1.Probe, len(healthy))
+	for i, h := range healthy {
+		probes[i] = v1alpha1.Probe{
+			ObjectMeta: metav1.ObjectMeta{Name: string(rune('a' + i))},
+			Status:     v1alpha1.ProbeStatus{Healthy: h},
+		}
+	}
+	return probes
+}
+
+func TestEvaluateGroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    v1alpha1.ProbeGroupSpec
+		probes  []v1alpha1.Probe
+		healthy bool
+		reason  string
+	}{
+		{"all healthy", v1alpha1.ProbeGroupSpec{}, members(true, true), true, "PolicySatisfied"},
+		{"all one down", v1alpha1.ProbeGroupSpec{Policy: v1alpha1.GroupPolicyAll}, members(true, false), false, "PolicyNotSatisfied"},
+		{"any one up", v1alpha1.ProbeGroupSpec{Policy: v1alpha1.GroupPolicyAny}, members(false, true), true, "PolicySatisfied"},
+		{"any none up", v1alpha1.ProbeGroupSpec{Policy: v1alpha1.GroupPolicyAny}, members(false, false), false, "PolicyNotSatisfied"},
+		{"quorum majority", v1alpha1.ProbeGroupSpec{Policy: v1alpha1.GroupPolicyQuorum}, members(true, true, false), true, "PolicySatisfied"},
+		{"quorum no majority", v1alpha1.ProbeGroupSpec{Policy: v1alpha1.GroupPolicyQuorum}, members(true, false, false, true), false, "PolicyNotSatisfied"},
+		{"quorum min healthy", v1alpha1.ProbeGroupSpec{Policy: v1alpha1.GroupPolicyQuorum, MinHealthy: 1}, members(true, false, false), true, "PolicySatisfied"},
+		{"weighted default half", v1alpha1.ProbeGroupSpec{Policy: v1alpha1.GroupPolicyWeighted}, members(true, false), true, "PolicySatisfied"},
+		{"weighted heavy member down", v1alpha1.ProbeGroupSpec{
+			Policy:  v1alpha1.GroupPolicyWeighted,
+			Weights: map[string]int32{"a": 3},
+		}, members(false, true, true), false, "PolicyNotSatisfied"},
+		{"weighted percent", v1alpha1.ProbeGroupSpec{
+			Policy:            v1alpha1.GroupPolicyWeighted,
+			Weights:           map[string]int32{"a": 3},
+			MinHealthyPercent: 75,
+		}, members(true, false), true, "PolicySatisfied"},
+		{"no members", v1alpha1.ProbeGroupSpec{Policy: v1alpha1.GroupPolicyAny}, nil, false, "NoMembers"},
+	}
+
+	for _, tt := range tests {
+		res := evaluateGroup(&tt.spec, tt.probes)
+		if res.healthy != tt.healthy || res.reason != tt.reason {
+			t.Errorf("%s: healthy=%v reason=%s; want healthy=%v reason=%s (%s)", tt.name, res.healthy, res.reason, tt.healthy, tt.reason, res.message)
+		}
+		if int(res.totalMembers) != len(tt.probes) {
+			t.Errorf("%s: totalMembers = %d; want %d", tt.name, res.totalMembers, len(tt.probes))
+		}
+	}
+}
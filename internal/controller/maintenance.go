@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/internal/config"
+	"heartbeat-operator/internal/schedule"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maintenance holds the MaintenanceWindow active for every Probe, by
+// config.GateRule Key, as last evaluated by the MaintenanceController.
+var maintenance = newMaintenanceRegistry()
+
+type maintenanceRegistry struct {
+	mu      sync.RWMutex
+	windows map[string]activeWindow
+}
+
+// activeWindow is a MaintenanceWindow covering a Probe.
+type activeWindow struct {
+	// name is "<namespace>/<name>" of the window.
+	name string
+	mode string
+}
+
+func newMaintenanceRegistry() *maintenanceRegistry {
+	return &maintenanceRegistry{windows: make(map[string]activeWindow)}
+}
+
+func (m *maintenanceRegistry) get(key string) (activeWindow, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	w, ok := m.windows[key]
+	return w, ok
+}
+
+func (m *maintenanceRegistry) replace(windows map[string]activeWindow) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.windows = windows
+}
+
+// MaintenanceController evaluates every MaintenanceWindow, publishes the
+// Probes it covers to the ReadinessControllers and its state to its status.
+type MaintenanceController struct {
+	windows  *MaintenanceClient
+	probes   *CrdClient
+	scope    config.Scope
+	interval time.Duration
+}
+
+// NewMaintenanceController creates a MaintenanceController for the
+// MaintenanceWindows in the watched namespaces. probes is narrowed to the
+// namespace of each window, so ClusterProbes are never covered.
+func NewMaintenanceController(windows *MaintenanceClient, probes *CrdClient, scope config.Scope, interval time.Duration) *MaintenanceController {
+	return &MaintenanceController{
+		windows:  windows,
+		probes:   probes,
+		scope:    scope,
+		interval: interval,
+	}
+}
+
+func (c *MaintenanceController) Start(ctx context.Context) {
+	log.Printf("Evaluating MaintenanceWindows every %s", c.interval)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.reconcile(ctx)
+
+	for {
+		select {
+		case <-ticker.C:
+			c.reconcile(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *MaintenanceController) reconcile(ctx context.Context) {
+	covered := make(map[string]activeWindow)
+	for _, ns := range c.scope.WatchedNamespaces() {
+		// Windows apply to every Probe of their namespace the instance
+		// runs, whatever their labels, so the Probe selector is not theirs
+		list, err := c.windows.List(ctx, ns, "")
+		if err != nil {
+			// Keep the windows of the last evaluation rather than lift them
+			log.Printf("Failed to list MaintenanceWindows: %v", err)
+			return
+		}
+		for i := range list.Items {
+			if err := c.evaluate(ctx, &list.Items[i], covered); err != nil {
+				return
+			}
+		}
+	}
+	maintenance.replace(covered)
+}
+
+// evaluate updates the status of w and adds the Probes it covers while
+// active to covered. It fails when the Probes cannot be listed.
+func (c *MaintenanceController) evaluate(ctx context.Context, w *v1alpha1.MaintenanceWindow, covered map[string]activeWindow) error {
+	name := w.Namespace + "/" + w.Name
+	now := time.Now()
+	status := w.Status.DeepCopy()
+	status.Message = ""
+
+	state, err := evaluateWindow(&w.Spec, now)
+	if err != nil {
+		status.Message = err.Error()
+	}
+	status.Active = state.active
+	status.ActiveUntil = optionalTime(state.until)
+	status.NextStart = optionalTime(state.next)
+
+	selector, err := metav1.LabelSelectorAsSelector(&w.Spec.Selector)
+	if err != nil {
+		status.Active = false
+		status.Message = fmt.Sprintf("invalid selector: %v", err)
+		status.Probes = 0
+	} else {
+		probes, err := c.probes.InNamespace(w.Namespace).List(ctx, selector)
+		if err != nil {
+			log.Printf("[%s] Failed to list Probes: %v", name, err)
+			return err
+		}
+		status.Probes = int32(len(probes.Items))
+		if status.Active {
+			for i := range probes.Items {
+				key := ruleFromProbe(config.KindProbe, &probes.Items[i]).Key()
+				// Pause is the stronger of two overlapping windows
+				if prev, ok := covered[key]; ok && prev.mode == v1alpha1.MaintenanceModePause {
+					continue
+				}
+				covered[key] = activeWindow{name: name, mode: maintenanceMode(&w.Spec)}
+			}
+		}
+	}
+
+	changed := status.Active != w.Status.Active || status.Probes != w.Status.Probes || status.Message != w.Status.Message ||
+		!reflect.DeepEqual(status.ActiveUntil, w.Status.ActiveUntil) || !reflect.DeepEqual(status.NextStart, w.Status.NextStart)
+	if !changed && w.Status.LastEvaluationTime != nil && time.Since(w.Status.LastEvaluationTime.Time) < time.Minute {
+		return nil
+	}
+
+	evaluated := metav1.NewTime(now)
+	status.LastEvaluationTime = &evaluated
+	w.Status = *status
+	if _, err := c.windows.UpdateStatus(ctx, w); err != nil {
+		log.Printf("[%s] Failed to update MaintenanceWindow status: %v", name, err)
+	} else if changed {
+		log.Printf("[%s] Updated MaintenanceWindow status: active=%v, %d Probes", name, status.Active, status.Probes)
+	}
+	return nil
+}
+
+type windowState struct {
+	active bool
+	// until is the end of the active periods, next the start of the next
+	// one. Either is zero when there is none.
+	until, next time.Time
+}
+
+// evaluateWindow tells whether the schedule or a range of spec covers now.
+// Times are truncated to seconds, as the API keeps them.
+func evaluateWindow(spec *v1alpha1.MaintenanceWindowSpec, now time.Time) (windowState, error) {
+	var state windowState
+	cover := func(start, end time.Time) {
+		switch {
+		case !now.Before(start) && now.Before(end):
+			state.active = true
+			if end.After(state.until) {
+				state.until = end
+			}
+		case start.After(now) && (state.next.IsZero() || start.Before(state.next)):
+			state.next = start
+		}
+	}
+
+	for _, r := range spec.Ranges {
+		cover(r.Start.Time, r.End.Time)
+	}
+
+	var err error
+	if spec.Schedule != "" {
+		err = func() error {
+			if spec.Duration == nil || spec.Duration.Duration <= 0 {
+				return fmt.Errorf("schedule needs a positive duration")
+			}
+			loc := time.UTC
+			if spec.TimeZone != "" {
+				l, err := time.LoadLocation(spec.TimeZone)
+				if err != nil {
+					return fmt.Errorf("unknown time zone %q", spec.TimeZone)
+				}
+				loc = l
+			}
+			sched, err := schedule.ParseInLocation(spec.Schedule, loc)
+			if err != nil {
+				return fmt.Errorf("schedule %q: %v", spec.Schedule, err)
+			}
+			if prev := sched.Prev(now); !prev.IsZero() {
+				cover(prev, prev.Add(spec.Duration.Duration))
+			}
+			if next := sched.Next(now); !next.IsZero() {
+				cover(next, next.Add(spec.Duration.Duration))
+			}
+			return nil
+		}()
+	}
+
+	state.until = state.until.Truncate(time.Second)
+	state.next = state.next.Truncate(time.Second)
+	return state, err
+}
+
+func maintenanceMode(spec *v1alpha1.MaintenanceWindowSpec) string {
+	if spec.Mode == "" {
+		return v1alpha1.MaintenanceModeMute
+	}
+	return spec.Mode
+}
+
+func optionalTime(t time.Time) *metav1.Time {
+	if t.IsZero() {
+		return nil
+	}
+	mt := metav1.NewTime(t)
+	return &mt
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/internal/config"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluateWindow(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	hours := func(h int) *metav1.Duration { return &metav1.Duration{Duration: time.Duration(h) * time.Hour} }
+	ranges := []v1alpha1.TimeRange{
+		{Start: metav1.NewTime(at("2026-03-10T08:00:00Z")), End: metav1.NewTime(at("2026-03-10T12:00:00Z"))},
+		{Start: metav1.NewTime(at("2026-03-20T08:00:00Z")), End: metav1.NewTime(at("2026-03-20T09:00:00Z"))},
+	}
+
+	tests := []struct {
+		name   string
+		spec   v1alpha1.MaintenanceWindowSpec
+		now    string
+		active bool
+		until  string
+		next   string
+		err    string
+	}{
+		{"nightly window running", v1alpha1.MaintenanceWindowSpec{Schedule: "0 2 * * *", Duration: hours(2)},
+			"2026-03-15T03:30:00Z", true, "2026-03-15T04:00:00Z", "2026-03-16T02:00:00Z", ""},
+		{"nightly window over", v1alpha1.MaintenanceWindowSpec{Schedule: "0 2 * * *", Duration: hours(2)},
+			"2026-03-15T04:00:00Z", false, "", "2026-03-16T02:00:00Z", ""},
+		{"time zone", v1alpha1.MaintenanceWindowSpec{Schedule: "0 2 * * *", Duration: hours(1), TimeZone: "Europe/Berlin"},
+			"2026-03-15T01:30:00Z", true, "2026-03-15T02:00:00Z", "2026-03-16T01:00:00Z", ""},
+		{"before the ranges", v1alpha1.MaintenanceWindowSpec{Ranges: ranges},
+			"2026-03-01T00:00:00Z", false, "", "2026-03-10T08:00:00Z", ""},
+		{"in a range", v1alpha1.MaintenanceWindowSpec{Ranges: ranges},
+			"2026-03-10T11:59:59Z", true, "2026-03-10T12:00:00Z", "2026-03-20T08:00:00Z", ""},
+		{"after the ranges", v1alpha1.MaintenanceWindowSpec{Ranges: ranges},
+			"2026-04-01T00:00:00Z", false, "", "", ""},
+		{"schedule without duration", v1alpha1.MaintenanceWindowSpec{Schedule: "@daily"},
+			"2026-03-15T00:30:00Z", false, "", "", "positive duration"},
+		{"unknown time zone", v1alpha1.MaintenanceWindowSpec{Schedule: "@daily", Duration: hours(1), TimeZone: "Mars/Olympus"},
+			"2026-03-15T00:30:00Z", false, "", "", "unknown time zone"},
+	}
+
+	for _, tt := range tests {
+		state, err := evaluateWindow(&tt.spec, at(tt.now))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: err = %v; want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if state.active != tt.active {
+			t.Errorf("%s: active = %v; want %v", tt.name, state.active, tt.active)
+		}
+		for _, c := range []struct {
+			field string
+			got   time.Time
+			want  string
+		}{{"until", state.until, tt.until}, {"next", state.next, tt.next}} {
+			if (c.want == "" && !c.got.IsZero()) || (c.want != "" && !c.got.Equal(at(c.want))) {
+				t.Errorf("%s: %s = %v; want %q", tt.name, c.field, c.got, c.want)
+			}
+		}
+	}
+}
+
+func TestReconcileHeldOff(t *testing.T) {
+	ctx := context.Background()
+	healthyCondition := func(p *v1alpha1.Probe) *metav1.Condition {
+		return meta.FindStatusCondition(p.Status.Conditions, v1alpha1.ConditionHealthy)
+	}
+	setup := func(name string, rule config.GateRule) (*ReadinessController, *MemoryClient, *stubProber) {
+		rule.Name = name
+		rule.Namespace = "ops"
+		rule.CheckType = "tcp"
+		rule.CheckTarget = "db:5432"
+		rule.Interval = "1h"
+		client := NewMemoryClient()
+		p := &stubProber{healthy: true}
+		c := New(nil, client, rule, p, nil)
+		c.reconcile(ctx)
+		return c, client, p
+	}
+	defer maintenance.replace(map[string]activeWindow{})
+
+	t.Run("suspended", func(t *testing.T) {
+		c, client, _ := setup("suspended", config.GateRule{})
+		c.rule.Suspend = true
+		before, _ := client.Get(ctx, "suspended")
+		c.reconcile(ctx)
+		cr, _ := client.Get(ctx, "suspended")
+		if cond := healthyCondition(cr); cond.Reason != v1alpha1.ReasonSuspended || cond.Status != metav1.ConditionUnknown {
+			t.Errorf("condition = %+v; want Unknown/Suspended", cond)
+		}
+		if !cr.Status.Healthy || !cr.Status.LastProbeTime.Equal(before.Status.LastProbeTime) {
+			t.Errorf("suspended probe changed its result: %+v", cr.Status)
+		}
+	})
+
+	t.Run("muted failure", func(t *testing.T) {
+		c, client, p := setup("muted", config.GateRule{})
+		maintenance.replace(map[string]activeWindow{c.rule.Key(): {name: "ops/upgrade", mode: v1alpha1.MaintenanceModeMute}})
+		p.healthy = false
+		c.reconcile(ctx)
+		cr, _ := client.Get(ctx, "muted")
+		if !cr.Status.Healthy || cr.Status.LastFailure != nil || len(cr.Status.History) != 1 {
+			t.Errorf("muted failure recorded: %+v", cr.Status)
+		}
+		if cond := healthyCondition(cr); cond.Reason != v1alpha1.ReasonInMaintenance || !strings.Contains(cond.Message, "ops/upgrade") {
+			t.Errorf("condition = %+v; want InMaintenance naming the window", cond)
+		}
+		if state := health.states[c.rule.Key()]; !state.healthy {
+			t.Error("muted failure reached the dependents")
+		}
+
+		// Passes are still reported, failures count again once it ends
+		p.healthy = true
+		c.reconcile(ctx)
+		if cr, _ = client.Get(ctx, "muted"); healthyCondition(cr).Reason != v1alpha1.ReasonCheckPassed {
+			t.Errorf("pass in maintenance: condition %+v", healthyCondition(cr))
+		}
+		maintenance.replace(map[string]activeWindow{})
+		p.healthy = false
+		c.reconcile(ctx)
+		if cr, _ = client.Get(ctx, "muted"); cr.Status.Healthy || cr.Status.LastFailure == nil {
+			t.Errorf("failure after the window not recorded: %+v", cr.Status)
+		}
+	})
+
+	t.Run("paused", func(t *testing.T) {
+		c, client, _ := setup("paused", config.GateRule{})
+		maintenance.replace(map[string]activeWindow{c.rule.Key(): {name: "ops/upgrade", mode: v1alpha1.MaintenanceModePause}})
+		c.check(ctx, "now")
+		cr, _ := client.Get(ctx, "paused")
+		if cond := healthyCondition(cr); cond.Reason != v1alpha1.ReasonInMaintenance || cond.Status != metav1.ConditionUnknown {
+			t.Errorf("condition = %+v; want Unknown/InMaintenance", cond)
+		}
+		if cr.Status.LastRunRequest != "now" {
+			t.Errorf("request not served while paused: %+v", cr.Status)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"heartbeat-operator/api/v1alpha1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// MemoryClient is a ProbeClient keeping the CRs in memory, for running
+// config file rules without Kubernetes.
+type MemoryClient struct {
+	mu     sync.Mutex
+	probes map[string]*v1alpha1.Probe
+}
+
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{probes: make(map[string]*v1alpha1.Probe)}
+}
+
+func (c *MemoryClient) Get(ctx context.Context, name string) (*v1alpha1.Probe, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.probes[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: v1alpha1.SchemeGroupVersion.Group, Resource: "probes"}, name)
+	}
+	return p.DeepCopy(), nil
+}
+
+func (c *MemoryClient) Create(ctx context.Context, check *v1alpha1.Probe) (*v1alpha1.Probe, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.probes[check.Name]; ok {
+		return nil, apierrors.NewAlreadyExists(schema.GroupResource{Group: v1alpha1.SchemeGroupVersion.Group, Resource: "probes"}, check.Name)
+	}
+	c.probes[check.Name] = check.DeepCopy()
+	return check.DeepCopy(), nil
+}
+
+// UpdateStatus replaces the status of a Probe, keeping its spec.
+func (c *MemoryClient) UpdateStatus(ctx context.Context, check *v1alpha1.Probe) (*v1alpha1.Probe, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.probes[check.Name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: v1alpha1.SchemeGroupVersion.Group, Resource: "probes"}, check.Name)
+	}
+	p.Status = *check.Status.DeepCopy()
+	return p.DeepCopy(), nil
+}
+
+// LogRecorder is an EventRecorder writing events to the log, the
+// notifications of an operator running without Kubernetes.
+type LogRecorder struct{}
+
+func (LogRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	name := "unknown"
+	if m, err := meta.Accessor(object); err == nil {
+		name = m.GetName()
+		if ns := m.GetNamespace(); ns != "" {
+			name = ns + "/" + name
+		}
+	}
+	log.Printf("[%s] %s %s: %s", name, eventtype, reason, message)
+}
+
+func (r LogRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r LogRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Eventf(object, eventtype, reason, messageFmt, args...)
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"heartbeat-operator/internal/config"
+)
+
+type stubProber struct{ healthy bool }
+
+func (p *stubProber) Check() bool { return p.healthy }
+
+func TestStandaloneReconcile(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	ctx := context.Background()
+	client := NewMemoryClient()
+	p := &stubProber{healthy: true}
+	rule := config.GateRule{Name: "standalone-db", Namespace: "ops", CheckType: "tcp", CheckTarget: "db:5432", Interval: "10s"}
+	c := New(nil, client, rule, p, LogRecorder{})
+
+	if err := c.ensureCR(ctx); err != nil {
+		t.Fatalf("ensureCR: %v", err)
+	}
+	c.reconcile(ctx)
+	cr, err := client.Get(ctx, rule.Name)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !cr.Status.Healthy || cr.Spec.CheckTarget != "db:5432" {
+		t.Errorf("got status healthy=%v target %q, want healthy db:5432", cr.Status.Healthy, cr.Spec.CheckTarget)
+	}
+
+	p.healthy = false
+	c.reconcile(ctx)
+	if cr, _ = client.Get(ctx, rule.Name); cr.Status.Healthy {
+		t.Error("status still healthy after a failed check")
+	}
+	if !strings.Contains(out.String(), "[ops/standalone-db] Warning ProbeFailed: Check of db:5432 failed") {
+		t.Errorf("failure not logged as an event:\n%s", out.String())
+	}
+
+	if _, err := client.Create(ctx, cr); err == nil {
+		t.Error("Create of an existing Probe succeeded")
+	}
+}
@@ -0,0 +1,261 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/internal/config"
+	"heartbeat-operator/internal/prober"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// ProberFactory builds the prober for a rule.
+type ProberFactory func(rule config.GateRule) (prober.Prober, error)
+
+// Watcher runs a ReadinessController for every Probe and ClusterProbe
+// created through the API. CRs that belong to a config file rule are skipped,
+// the config rule already runs them.
+type Watcher struct {
+	client        *kubernetes.Clientset
+	probes        *CrdClient
+	clusterProbes *ClusterCrdClient
+	scope         config.Scope
+	recorder      record.EventRecorder
+	newProber     ProberFactory
+	configRules   map[string]bool
+	// configProbes are the controllers of config file rules, only
+	// triggered through the run-now annotation of their CR.
+	configProbes map[string]*runningProbe
+
+	mu      sync.Mutex
+	running map[string]*runningProbe
+	// done is closed when the last controller started for a key returned,
+	// a replacement waits for it so the two never run at once.
+	done map[string]chan struct{}
+	wg   sync.WaitGroup
+}
+
+type runningProbe struct {
+	generation int64
+	cancel     context.CancelFunc
+	ctrl       *ReadinessController
+	// runNow is the value of the run-now annotation last acted on.
+	runNow string
+}
+
+// NewWatcher creates a Watcher for the Probes in scope. probes is narrowed
+// to each watched namespace.
+func NewWatcher(client *kubernetes.Clientset, probes *CrdClient, clusterProbes *ClusterCrdClient, scope config.Scope, recorder record.EventRecorder, newProber ProberFactory, configRules []config.GateRule) *Watcher {
+	skip := make(map[string]bool, len(configRules))
+	for _, r := range configRules {
+		skip[r.Key()] = true
+	}
+	return &Watcher{
+		client:        client,
+		probes:        probes,
+		clusterProbes: clusterProbes,
+		scope:         scope,
+		recorder:      recorder,
+		newProber:     newProber,
+		configRules:   skip,
+		configProbes:  make(map[string]*runningProbe),
+		running:       make(map[string]*runningProbe),
+		done:          make(map[string]chan struct{}),
+	}
+}
+
+// AddConfigController lets the run-now annotation of the CR of a config
+// file rule trigger ctrl.
+func (w *Watcher) AddConfigController(rule config.GateRule, ctrl *ReadinessController) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.configProbes[rule.Key()] = &runningProbe{ctrl: ctrl}
+}
+
+// Run watches Probes and ClusterProbes until ctx is cancelled, then waits
+// for the controllers it started to return.
+func (w *Watcher) Run(ctx context.Context) {
+	selector := w.scope.SelectorString()
+	if w.scope.AllNamespaces() {
+		log.Println("Watching Probe and ClusterProbe resources...")
+	} else {
+		log.Printf("Watching Probe resources in %v...", w.scope.Namespaces)
+	}
+
+	// One informer per namespace, so Roles in those namespaces suffice
+	for _, ns := range w.scope.WatchedNamespaces() {
+		probeInformer := cache.NewSharedIndexInformer(w.probes.InNamespace(ns).ListWatch(selector), &v1alpha1.Probe{}, 10*time.Minute, cache.Indexers{})
+		_, _ = probeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { w.sync(ctx, config.KindProbe, obj.(*v1alpha1.Probe)) },
+			UpdateFunc: func(_, obj interface{}) { w.sync(ctx, config.KindProbe, obj.(*v1alpha1.Probe)) },
+			DeleteFunc: func(obj interface{}) {
+				if p, ok := unwrapDeleted(obj).(*v1alpha1.Probe); ok {
+					w.stop(ruleFromProbe(config.KindProbe, p).Key())
+				}
+			},
+		})
+		go probeInformer.Run(ctx.Done())
+	}
+
+	// ClusterProbes need cluster-wide access, instances limited to some
+	// namespaces leave them to one that watches all
+	if w.scope.AllNamespaces() {
+		clusterInformer := cache.NewSharedIndexInformer(w.clusterProbes.ListWatch(selector), &v1alpha1.ClusterProbe{}, 10*time.Minute, cache.Indexers{})
+		_, _ = clusterInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				w.sync(ctx, config.KindClusterProbe, fromClusterProbe(obj.(*v1alpha1.ClusterProbe)))
+			},
+			UpdateFunc: func(_, obj interface{}) {
+				w.sync(ctx, config.KindClusterProbe, fromClusterProbe(obj.(*v1alpha1.ClusterProbe)))
+			},
+			DeleteFunc: func(obj interface{}) {
+				if cp, ok := unwrapDeleted(obj).(*v1alpha1.ClusterProbe); ok {
+					w.stop(ruleFromProbe(config.KindClusterProbe, fromClusterProbe(cp)).Key())
+				}
+			},
+		})
+		go clusterInformer.Run(ctx.Done())
+	}
+
+	<-ctx.Done()
+	w.wg.Wait()
+}
+
+// sync starts a controller for p, or restarts it when its spec changed.
+// Status updates do not bump the generation and are ignored, a new run-now
+// annotation triggers a check.
+func (w *Watcher) sync(ctx context.Context, kind string, p *v1alpha1.Probe) {
+	rule := ruleFromProbe(kind, p)
+	key := rule.Key()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.configRules[key] {
+		if r, ok := w.configProbes[key]; ok {
+			r.requestRun(p)
+		}
+		return
+	}
+
+	if r, ok := w.running[key]; ok {
+		if r.generation == p.Generation {
+			r.requestRun(p)
+			return
+		}
+		log.Printf("[%s] Spec changed, restarting", rule.Name)
+		r.cancel()
+		delete(w.running, key)
+	}
+
+	pr, err := w.newProber(rule)
+	if err != nil {
+		log.Printf("[%s] Skipping %s: %v", rule.Name, kind, err)
+		return
+	}
+
+	var client ProbeClient = w.probes.InNamespace(rule.Namespace)
+	if kind == config.KindClusterProbe {
+		client = w.clusterProbes
+	}
+
+	probeCtx, cancel := context.WithCancel(ctx)
+	r := &runningProbe{generation: p.Generation, cancel: cancel, ctrl: NewForCR(w.client, client, rule, pr, w.recorder)}
+	w.running[key] = r
+	r.requestRun(p)
+
+	// The controller replaced or stopped last must have cleaned up its
+	// health and status before this one starts
+	prev, done := w.done[key], make(chan struct{})
+	w.done[key] = done
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer func() {
+			close(done)
+			w.mu.Lock()
+			if w.done[key] == done {
+				delete(w.done, key)
+			}
+			w.mu.Unlock()
+		}()
+		if prev != nil {
+			<-prev
+		}
+		if probeCtx.Err() != nil {
+			return
+		}
+		r.ctrl.Start(probeCtx)
+	}()
+}
+
+// requestRun triggers a check when p carries a run-now annotation that
+// was neither acted on nor served yet, e.g. before a restart.
+func (r *runningProbe) requestRun(p *v1alpha1.Probe) {
+	runNow := p.Annotations[v1alpha1.AnnotationRunNow]
+	if runNow == r.runNow {
+		return
+	}
+	r.runNow = runNow
+	if runNow != "" && runNow != p.Status.LastRunRequest {
+		r.ctrl.Trigger(runNow)
+	}
+}
+
+func (w *Watcher) stop(key string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if r, ok := w.running[key]; ok {
+		log.Printf("[%s] Resource deleted, stopping", key)
+		r.cancel()
+		delete(w.running, key)
+	}
+}
+
+func ruleFromProbe(kind string, p *v1alpha1.Probe) config.GateRule {
+	var options json.RawMessage
+	if p.Spec.Options != nil {
+		options = p.Spec.Options.Raw
+	}
+	return config.GateRule{
+		Kind:        kind,
+		Name:        p.Name,
+		Namespace:   p.Namespace,
+		CheckType:   p.Spec.CheckType,
+		CheckTarget: p.Spec.CheckTarget,
+		Interval:    p.Spec.Interval,
+		Timeout:     p.Spec.Timeout,
+		Schedule:    p.Spec.Schedule,
+		TimeZone:    p.Spec.TimeZone,
+		DependsOn:   p.Spec.DependsOn,
+		Options:     options,
+		Suspend:     p.Spec.Suspend,
+		Retries:     ruleRetries(p.Spec.Retries),
+
+		SuccessThreshold: p.Spec.SuccessThreshold,
+		FailureThreshold: p.Spec.FailureThreshold,
+	}
+}
+
+func ruleRetries(r *v1alpha1.RetryPolicy) *config.Retries {
+	if r == nil {
+		return nil
+	}
+	return &config.Retries{Count: r.Count, Delay: r.Delay, Backoff: r.Backoff, MaxDelay: r.MaxDelay}
+}
+
+func unwrapDeleted(obj interface{}) interface{} {
+	if d, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		return d.Obj
+	}
+	return obj
+}
@@ -0,0 +1,170 @@
+// Package heartbeat receives the pings of push-based heartbeat probes. Jobs
+// call /ping/<token> when they succeed, and optionally /ping/<token>/start
+// when they begin and /ping/<token>/fail when they fail.
+package heartbeat
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State is what the pings of one token told so far.
+type State struct {
+	LastStart   time.Time
+	LastSuccess time.Time
+	LastFail    time.Time
+	// RunDuration is the time from the last start ping to the success or
+	// fail ping that ended the run, zero without a start ping.
+	RunDuration time.Duration
+}
+
+// LastPing returns the time of the latest ping of any kind.
+func (s State) LastPing() time.Time {
+	last := s.LastStart
+	for _, t := range []time.Time{s.LastSuccess, s.LastFail} {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// Failed reports whether the latest run ended with a fail ping.
+func (s State) Failed() bool {
+	return !s.LastFail.IsZero() && s.LastFail.After(s.LastSuccess)
+}
+
+// retiredStateKept is how long the state of an unregistered token is kept
+// for a probe registering it again, e.g. when it is rebuilt after a spec
+// change.
+const retiredStateKept = 10 * time.Minute
+
+var (
+	mu     sync.RWMutex
+	tokens = make(map[string]*State)
+	// refs counts the probes of every registered token.
+	refs = make(map[string]int)
+	// retired holds the state of unregistered tokens by the time they were
+	// unregistered.
+	retired = make(map[string]retiredState)
+)
+
+type retiredState struct {
+	state *State
+	at    time.Time
+}
+
+// Register makes pings with token accepted until every probe that
+// registered it unregistered it. The state of a token survives registering
+// it again, also shortly after it was unregistered.
+func Register(token string) {
+	mu.Lock()
+	defer mu.Unlock()
+	refs[token]++
+	if _, ok := tokens[token]; ok {
+		return
+	}
+	if r, ok := retired[token]; ok {
+		tokens[token] = r.state
+		delete(retired, token)
+		return
+	}
+	tokens[token] = &State{}
+}
+
+// Unregister drops a registration of token. Pings with it are refused once
+// no probe holds it anymore.
+func Unregister(token string) {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	for t, r := range retired {
+		if now.Sub(r.at) > retiredStateKept {
+			delete(retired, t)
+		}
+	}
+	if refs[token]--; refs[token] > 0 {
+		return
+	}
+	delete(refs, token)
+	if s, ok := tokens[token]; ok {
+		retired[token] = retiredState{state: s, at: now}
+		delete(tokens, token)
+	}
+}
+
+// Get returns the state of token.
+func Get(token string) (State, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	s, ok := tokens[token]
+	if !ok {
+		return State{}, false
+	}
+	return *s, true
+}
+
+// record applies a ping of kind ("", "start" or "fail") at now. It returns
+// false for an unknown token.
+func record(token, kind string, now time.Time) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := tokens[token]
+	if !ok {
+		return false
+	}
+	if kind == "start" {
+		s.LastStart = now
+		return true
+	}
+
+	// A start ping after the end of the previous run begins this one
+	prevEnd := s.LastSuccess
+	if s.LastFail.After(prevEnd) {
+		prevEnd = s.LastFail
+	}
+	s.RunDuration = 0
+	if s.LastStart.After(prevEnd) {
+		s.RunDuration = now.Sub(s.LastStart)
+	}
+	if kind == "fail" {
+		s.LastFail = now
+	} else {
+		s.LastSuccess = now
+	}
+	return true
+}
+
+// Handler serves /ping/<token>, /ping/<token>/start and /ping/<token>/fail
+// for GET, HEAD and POST, so curl and wget work without flags.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodPost:
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/ping/"), "/"), "/")
+		token, kind := parts[0], ""
+		if len(parts) == 2 && (parts[1] == "start" || parts[1] == "fail") {
+			kind = parts[1]
+		} else if len(parts) != 1 {
+			http.NotFound(w, r)
+			return
+		}
+		if token == "" || !record(token, kind, time.Now()) {
+			http.NotFound(w, r)
+			return
+		}
+		if kind == "" {
+			kind = "success"
+		}
+		log.Printf("[Heartbeat] Received %s ping", kind)
+		w.Write([]byte("OK\n"))
+	})
+}
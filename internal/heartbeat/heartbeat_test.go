@@ -0,0 +1,104 @@
+package heartbeat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	Register("s3cr3t")
+	h := Handler()
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodGet, "/ping/s3cr3t/start", http.StatusOK},
+		{http.MethodPost, "/ping/s3cr3t", http.StatusOK},
+		{http.MethodHead, "/ping/s3cr3t/fail", http.StatusOK},
+		{http.MethodGet, "/ping/unknown", http.StatusNotFound},
+		{http.MethodGet, "/ping/s3cr3t/finish", http.StatusNotFound},
+		{http.MethodGet, "/ping/", http.StatusNotFound},
+		{http.MethodDelete, "/ping/s3cr3t", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%s %s = %d; want %d", tt.method, tt.path, rec.Code, tt.code)
+		}
+	}
+
+	s, ok := Get("s3cr3t")
+	if !ok {
+		t.Fatalf("token not registered")
+	}
+	if s.LastStart.IsZero() || s.LastSuccess.IsZero() || !s.Failed() {
+		t.Errorf("state = %+v; want start, success and a failure after it", s)
+	}
+}
+
+func TestRunDuration(t *testing.T) {
+	Register("run")
+	t0 := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+
+	record("run", "", t0)
+	if s, _ := Get("run"); s.RunDuration != 0 {
+		t.Errorf("run duration without start ping = %s; want 0", s.RunDuration)
+	}
+
+	record("run", "start", t0.Add(time.Hour))
+	record("run", "", t0.Add(time.Hour+42*time.Second))
+	s, _ := Get("run")
+	if s.RunDuration != 42*time.Second {
+		t.Errorf("run duration = %s; want 42s", s.RunDuration)
+	}
+	if s.Failed() {
+		t.Errorf("run reported as failed")
+	}
+
+	// The start ping belongs to the previous run, the next one sent none
+	record("run", "fail", t0.Add(2*time.Hour))
+	s, _ = Get("run")
+	if s.RunDuration != 0 || !s.Failed() {
+		t.Errorf("state = %+v; want a failed run without duration", s)
+	}
+
+	if record("missing", "", t0) {
+		t.Errorf("ping of an unregistered token was accepted")
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	h := Handler()
+	ping := func() int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ping/gone", nil))
+		return rec.Code
+	}
+
+	// Two probes share the token
+	Register("gone")
+	Register("gone")
+	Unregister("gone")
+	if code := ping(); code != http.StatusOK {
+		t.Errorf("ping while a probe holds the token = %d; want 200", code)
+	}
+	Unregister("gone")
+	if code := ping(); code != http.StatusNotFound {
+		t.Errorf("ping after the last probe unregistered = %d; want 404", code)
+	}
+	if _, ok := Get("gone"); ok {
+		t.Errorf("unregistered token still has a state")
+	}
+
+	// A probe rebuilt meanwhile keeps the state
+	Register("gone")
+	if s, _ := Get("gone"); s.LastSuccess.IsZero() {
+		t.Errorf("state = %+v; want the ping before it was unregistered", s)
+	}
+	Unregister("gone")
+}
@@ -0,0 +1,194 @@
+// Package kubectl formats Probes for the kubectl heartbeat plugin.
+package kubectl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// PrintList writes one line per Probe, sorted by namespace and name.
+func PrintList(w io.Writer, probes []v1alpha1.Probe, now time.Time) error {
+	sort.Slice(probes, func(i, j int) bool {
+		if probes[i].Namespace != probes[j].Namespace {
+			return probes[i].Namespace < probes[j].Namespace
+		}
+		return probes[i].Name < probes[j].Name
+	})
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tNAME\tTYPE\tHEALTHY\tLATENCY\tSTREAK\tLAST PROBE\tMESSAGE")
+	for i := range probes {
+		p := &probes[i]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", p.Namespace, p.Name, p.Spec.CheckType, healthy(p),
+			latency(p), Streak(p, now), lastProbe(p, now), oneLine(p.Status.Message))
+	}
+	return tw.Flush()
+}
+
+// PrintDescribe writes the spec, status and recent history of a Probe.
+func PrintDescribe(w io.Writer, p *v1alpha1.Probe, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Name:\t%s\n", p.Name)
+	if p.Namespace != "" {
+		fmt.Fprintf(tw, "Namespace:\t%s\n", p.Namespace)
+	}
+	fmt.Fprintf(tw, "Type:\t%s\n", p.Spec.CheckType)
+	fmt.Fprintf(tw, "Target:\t%s\n", p.Spec.CheckTarget)
+	if p.Spec.Schedule != "" {
+		fmt.Fprintf(tw, "Schedule:\t%s%s\n", p.Spec.Schedule, timeZone(p.Spec.TimeZone))
+	} else {
+		fmt.Fprintf(tw, "Interval:\t%s\n", p.Spec.Interval)
+	}
+	if len(p.Spec.DependsOn) > 0 {
+		fmt.Fprintf(tw, "Depends On:\t%s\n", strings.Join(p.Spec.DependsOn, ", "))
+	}
+	if p.Spec.Suspend {
+		fmt.Fprintf(tw, "Suspended:\ttrue\n")
+	}
+	fmt.Fprintf(tw, "Healthy:\t%s (%s)\n", healthy(p), Streak(p, now))
+	fmt.Fprintf(tw, "Message:\t%s\n", p.Status.Message)
+	if t := p.Status.LastProbeTime; t != nil {
+		fmt.Fprintf(tw, "Last Probe:\t%s (%s ago)\n", t.UTC().Format(time.RFC3339), age(t.Time, now))
+	}
+	if t := p.Status.NextProbeTime; t != nil {
+		fmt.Fprintf(tw, "Next Probe:\t%s (in %s)\n", t.UTC().Format(time.RFC3339), age(now, t.Time))
+	}
+	if p.Status.Attempts > 1 {
+		fmt.Fprintf(tw, "Latency:\t%s (%d attempts)\n", latency(p), p.Status.Attempts)
+	} else {
+		fmt.Fprintf(tw, "Latency:\t%s\n", latency(p))
+	}
+	if p.Status.LastRunRequest != "" {
+		fmt.Fprintf(tw, "Last Run Request:\t%s\n", p.Status.LastRunRequest)
+	}
+	if f := p.Status.LastFailure; f != nil {
+		fmt.Fprintf(tw, "Last Failure:\t%s (%s ago): %s\n", f.Time.UTC().Format(time.RFC3339), age(f.Time.Time, now), f.Message)
+	} else {
+		fmt.Fprintf(tw, "Last Failure:\t<none>\n")
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(p.Status.Conditions) > 0 {
+		fmt.Fprintln(w, "Conditions:")
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  TYPE\tSTATUS\tREASON\tSINCE\tMESSAGE")
+		for _, c := range p.Status.Conditions {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", c.Type, c.Status, c.Reason, age(c.LastTransitionTime.Time, now), oneLine(c.Message))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(p.Status.Endpoints) > 0 {
+		fmt.Fprintln(w, "Endpoints:")
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  ADDRESS\tHEALTHY\tMESSAGE")
+		for _, e := range p.Status.Endpoints {
+			fmt.Fprintf(tw, "  %s\t%v\t%s\n", e.Address, e.Healthy, oneLine(e.Message))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w, "History:")
+	if len(p.Status.History) == 0 {
+		fmt.Fprintln(w, "  <none>")
+		return nil
+	}
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  TIME\tRESULT\tMESSAGE")
+	// Newest first, as kubectl describe lists events
+	for i := len(p.Status.History) - 1; i >= 0; i-- {
+		h := p.Status.History[i]
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", h.Time.UTC().Format(time.RFC3339), result(h.Healthy), oneLine(h.Message))
+	}
+	return tw.Flush()
+}
+
+// Transition returns the line printed by watch when a Probe changed from
+// old, nil when it was not seen before. ok is false when Healthy did not
+// change or the Probe was not checked yet.
+func Transition(old, p *v1alpha1.Probe, now time.Time) (line string, ok bool) {
+	if p.Status.LastProbeTime == nil {
+		return "", false
+	}
+	from := "new"
+	if old != nil && old.Status.LastProbeTime != nil {
+		if old.Status.Healthy == p.Status.Healthy {
+			return "", false
+		}
+		from = result(old.Status.Healthy)
+	}
+	name := p.Name
+	if p.Namespace != "" {
+		name = p.Namespace + "/" + name
+	}
+	return fmt.Sprintf("%s  %s  %s -> %s  %s", now.UTC().Format(time.RFC3339), name, from, result(p.Status.Healthy), oneLine(p.Status.Message)), true
+}
+
+// Streak tells how long a Probe has been in its current state, from the
+// last change in its history.
+func Streak(p *v1alpha1.Probe, now time.Time) string {
+	n := len(p.Status.History)
+	if n == 0 || p.Status.History[n-1].Healthy != p.Status.Healthy {
+		return "-"
+	}
+	return fmt.Sprintf("%s for %s", result(p.Status.Healthy), age(p.Status.History[n-1].Time.Time, now))
+}
+
+func healthy(p *v1alpha1.Probe) string {
+	if p.Status.LastProbeTime == nil {
+		return "unknown"
+	}
+	return fmt.Sprint(p.Status.Healthy)
+}
+
+func result(healthy bool) string {
+	if healthy {
+		return "passing"
+	}
+	return "failing"
+}
+
+func latency(p *v1alpha1.Probe) string {
+	if p.Status.LastDuration == nil {
+		return "-"
+	}
+	d := p.Status.LastDuration.Duration
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(10 * time.Millisecond).String()
+}
+
+func lastProbe(p *v1alpha1.Probe, now time.Time) string {
+	if p.Status.LastProbeTime == nil {
+		return "<never>"
+	}
+	return age(p.Status.LastProbeTime.Time, now)
+}
+
+func timeZone(tz string) string {
+	if tz == "" {
+		return ""
+	}
+	return " (" + tz + ")"
+}
+
+func age(t time.Time, now time.Time) string {
+	return duration.HumanDuration(now.Sub(t))
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,104 @@
+package kubectl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var now = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func probe(ns, name string, healthy bool, since time.Duration) v1alpha1.Probe {
+	probed := metav1.NewTime(now.Add(-10 * time.Second))
+	changed := metav1.NewTime(now.Add(-since))
+	return v1alpha1.Probe{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Spec:       v1alpha1.ProbeSpec{CheckType: "http", CheckTarget: "http://" + name, Interval: "30s"},
+		Status: v1alpha1.ProbeStatus{
+			Healthy:       healthy,
+			Message:       "Check failed",
+			LastProbeTime: &probed,
+			LastDuration:  &metav1.Duration{Duration: 41200 * time.Microsecond},
+			History: []v1alpha1.ProbeResult{
+				{Time: metav1.NewTime(changed.Add(-time.Hour)), Healthy: !healthy, Message: "earlier"},
+				{Time: changed, Healthy: healthy, Message: "Check failed"},
+			},
+		},
+	}
+}
+
+func TestPrintList(t *testing.T) {
+	probes := []v1alpha1.Probe{probe("shop", "web", false, 5*time.Minute), probe("billing", "api", true, 3*time.Hour)}
+	var out bytes.Buffer
+	if err := PrintList(&out, probes, now); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "billing") {
+		t.Fatalf("list not sorted by namespace:\n%s", out.String())
+	}
+	for _, want := range []string{"41ms", "failing for 5m", "passing for 3h", "10s"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("list misses %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestPrintDescribe(t *testing.T) {
+	p := probe("shop", "web", false, 5*time.Minute)
+	p.Status.LastFailure = &v1alpha1.ProbeResult{Time: metav1.NewTime(now.Add(-10 * time.Second)), Message: "connection refused"}
+	var out bytes.Buffer
+	if err := PrintDescribe(&out, &p, now); err != nil {
+		t.Fatal(err)
+	}
+	s := out.String()
+	if !strings.Contains(s, "10s ago): connection refused") {
+		t.Errorf("describe misses the last failure:\n%s", s)
+	}
+	history := s[strings.Index(s, "History:"):]
+	if strings.Index(history, "Check failed") > strings.Index(history, "earlier") {
+		t.Errorf("history not newest first:\n%s", history)
+	}
+}
+
+func TestPrintDescribeSchedule(t *testing.T) {
+	p := probe("shop", "synthetic-order", true, time.Hour)
+	p.Spec.Interval = ""
+	p.Spec.Schedule = "*/15 9-17 * * mon-fri"
+	p.Spec.TimeZone = "Europe/Berlin"
+	next := metav1.NewTime(now.Add(12 * time.Minute))
+	p.Status.NextProbeTime = &next
+	var out bytes.Buffer
+	if err := PrintDescribe(&out, &p, now); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"*/15 9-17 * * mon-fri (Europe/Berlin)", "2026-03-01T12:12:00Z (in 12m)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("describe misses %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestTransition(t *testing.T) {
+	passing := probe("shop", "web", true, time.Minute)
+	failing := probe("shop", "web", false, 0)
+	if _, ok := Transition(&passing, &passing, now); ok {
+		t.Error("transition reported without a change")
+	}
+	line, ok := Transition(&passing, &failing, now)
+	if !ok || !strings.Contains(line, "shop/web  passing -> failing  Check failed") {
+		t.Errorf("transition = %q, %v", line, ok)
+	}
+	if line, _ := Transition(nil, &passing, now); !strings.Contains(line, "new -> passing") {
+		t.Errorf("transition of a new Probe = %q", line)
+	}
+	unchecked := v1alpha1.Probe{ObjectMeta: metav1.ObjectMeta{Name: "fresh"}}
+	if _, ok := Transition(nil, &unchecked, now); ok {
+		t.Error("transition reported for a Probe never checked")
+	}
+}
@@ -8,8 +8,8 @@ import (
 var (
 	ProbeSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "probe_success",
-		Help: "Current status of the probe (1 for success, 0 for failure)",
-	}, []string{"name", "target", "type"})
+		Help: "Current status of the probe (1 for success, 0 for failure). Probes of the endpoints of a Service also report every endpoint, with the endpoint label set",
+	}, []string{"name", "target", "type", "endpoint"})
 
 	ProbeDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "probe_duration_seconds",
@@ -17,8 +17,69 @@ var (
 		Buckets: prometheus.DefBuckets,
 	}, []string{"name", "target", "type"})
 
+	ProbeAttempts = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "probe_attempts",
+		Help:    "Number of attempts a probe execution took, more than 1 when a failed check was retried",
+		Buckets: []float64{1, 2, 3, 4, 5, 10},
+	}, []string{"name", "target", "type"})
+
 	ProbeLastTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "probe_last_timestamp_seconds",
 		Help: "Timestamp of the last probe execution",
 	}, []string{"name", "target", "type"})
+
+	ProbeSuppressed = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_suppressed",
+		Help: "Whether the probe is suppressed because a probe it depends on is failing (1 for suppressed)",
+	}, []string{"name", "target", "type"})
+
+	ProbeMaintenance = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_maintenance",
+		Help: "Whether the probe is suspended or covered by a maintenance window (1 while it is)",
+	}, []string{"name", "target", "type"})
+
+	ProbeHeartbeatLastPing = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_heartbeat_last_ping_timestamp_seconds",
+		Help: "Timestamp of the last ping received by a heartbeat probe",
+	}, []string{"name", "target", "type"})
+
+	ProbeHeartbeatRunDuration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_heartbeat_run_duration_seconds",
+		Help: "Duration of the last run reported by a heartbeat probe, from its start ping to its success or fail ping",
+	}, []string{"name", "target", "type"})
+
+	ProbeHTTPDuration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_http_duration_seconds",
+		Help: "Duration of every phase (resolve, connect, tls, processing, transfer) of the last http probe, summed over redirects",
+	}, []string{"name", "target", "type", "phase"})
+
+	ProbeHTTPStatusCode = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_http_status_code",
+		Help: "Status code of the last response of an http probe, 0 without a response",
+	}, []string{"name", "target", "type"})
+
+	ProbeHTTPVersion = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_http_version",
+		Help: "HTTP version of the last response of an http probe",
+	}, []string{"name", "target", "type"})
+
+	ProbeHTTPRedirects = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_http_redirects",
+		Help: "Number of redirects the last http probe followed",
+	}, []string{"name", "target", "type"})
+
+	ProbeHTTPContentLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_http_content_length",
+		Help: "Length of the body of the last response of an http probe",
+	}, []string{"name", "target", "type"})
+
+	ProbeGroupSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_group_success",
+		Help: "Aggregate status of the probe group (1 for healthy, 0 for unhealthy)",
+	}, []string{"name", "namespace", "policy"})
+
+	ProbeGroupMembers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_group_members",
+		Help: "Number of member probes of the group by state (healthy, unhealthy)",
+	}, []string{"name", "namespace", "state"})
 )
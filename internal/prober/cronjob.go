@@ -0,0 +1,291 @@
+package prober
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"heartbeat-operator/internal/schedule"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+)
+
+func init() {
+	Register("cronjob", Type{
+		Decode: decodeCronJobOptions,
+		Validate: func(target string, _ interface{}) []string {
+			if _, _, err := parseCronJobTarget(target); err != nil {
+				return []string{err.Error()}
+			}
+			return nil
+		},
+		NewFor: func(probe ProbeInfo, target string, options interface{}) (Prober, error) {
+			client := kubernetesClient()
+			if client == nil {
+				return nil, errors.New("cronjob checks need access to the Kubernetes API")
+			}
+			namespace, name, err := parseCronJobTarget(target)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case namespace == "" && probe.Namespace == "":
+				return nil, errors.New("cronjob checks of a ClusterProbe must name the namespace as <namespace>/<name>")
+			case namespace == "":
+				namespace = probe.Namespace
+			case probe.Namespace != "" && namespace != probe.Namespace:
+				// Only ClusterProbes may watch CronJobs of other namespaces
+				return nil, fmt.Errorf("cronjob checks of a Probe can only watch CronJobs in namespace %s", probe.Namespace)
+			}
+			p := NewCronJobProber(client, namespace, name, options.(*CronJobOptions))
+			p.Timeout = probe.Timeout
+			return p, nil
+		},
+	})
+}
+
+var (
+	cronJobsResource = schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}
+	jobsResource     = schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+)
+
+// scheduledTimestampAnnotation records the schedule time a CronJob created a
+// Job for, set since Kubernetes 1.28.
+const scheduledTimestampAnnotation = "batch.kubernetes.io/cronjob-scheduled-timestamp"
+
+// CronJobOptions tune a cronjob check.
+type CronJobOptions struct {
+	// Tolerance is how late a scheduled run may start before the check
+	// fails, "5m" by default.
+	Tolerance string `json:"tolerance,omitempty"`
+	// MaxDuration fails the check when a run takes longer. Unset means no
+	// limit.
+	MaxDuration string `json:"maxDuration,omitempty"`
+}
+
+func decodeCronJobOptions(raw json.RawMessage) (interface{}, error) {
+	opts := &CronJobOptions{}
+	if err := decodeStrict(raw, opts); err != nil {
+		return nil, err
+	}
+	if opts.Tolerance != "" {
+		if d, err := time.ParseDuration(opts.Tolerance); err != nil || d < 0 {
+			return nil, fmt.Errorf("tolerance must be a non-negative duration, e.g. 10m")
+		}
+	}
+	if opts.MaxDuration != "" {
+		if d, err := time.ParseDuration(opts.MaxDuration); err != nil || d <= 0 {
+			return nil, fmt.Errorf("maxDuration must be a positive duration, e.g. 2h")
+		}
+	}
+	return opts, nil
+}
+
+// parseCronJobTarget splits "[<namespace>/]<name>".
+func parseCronJobTarget(target string) (namespace, name string, err error) {
+	parts := strings.Split(target, "/")
+	switch len(parts) {
+	case 1:
+		name = parts[0]
+	case 2:
+		namespace, name = parts[0], parts[1]
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return "", "", fmt.Errorf("namespace %q: %s", namespace, strings.Join(errs, "; "))
+		}
+	default:
+		return "", "", fmt.Errorf("must be [<namespace>/]<cronjob name>")
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", "", fmt.Errorf("cronjob name %q: %s", name, strings.Join(errs, "; "))
+	}
+	return namespace, name, nil
+}
+
+// CronJobProber checks the Jobs of a CronJob: the last finished run must
+// have succeeded, the last scheduled run must have started within the
+// tolerance and no run may exceed the max duration.
+type CronJobProber struct {
+	Client      dynamic.Interface
+	Namespace   string
+	Name        string
+	Tolerance   time.Duration
+	MaxDuration time.Duration
+	// Timeout bounds reading the CronJob and its Jobs, DefaultTimeout when
+	// zero.
+	Timeout time.Duration
+}
+
+func NewCronJobProber(client dynamic.Interface, namespace, name string, opts *CronJobOptions) *CronJobProber {
+	p := &CronJobProber{Client: client, Namespace: namespace, Name: name, Tolerance: 5 * time.Minute}
+	if opts.Tolerance != "" {
+		p.Tolerance, _ = time.ParseDuration(opts.Tolerance)
+	}
+	if opts.MaxDuration != "" {
+		p.MaxDuration, _ = time.ParseDuration(opts.MaxDuration)
+	}
+	return p
+}
+
+func (p *CronJobProber) Check() bool {
+	return p.CheckResult().Healthy
+}
+
+func (p *CronJobProber) CheckResult() Result {
+	return p.CheckContext(context.Background())
+}
+
+func (p *CronJobProber) CheckContext(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout(p.Timeout))
+	defer cancel()
+
+	obj, err := p.Client.Resource(cronJobsResource).Namespace(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("[CronJob] Get of %s/%s failed: %v", p.Namespace, p.Name, err)
+		return Result{Message: err.Error()}
+	}
+	cronJob := &batchv1.CronJob{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, cronJob); err != nil {
+		return Result{Message: fmt.Sprintf("decode CronJob: %v", err)}
+	}
+
+	list, err := p.Client.Resource(jobsResource).Namespace(p.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Printf("[CronJob] List of Jobs in %s failed: %v", p.Namespace, err)
+		return Result{Message: err.Error()}
+	}
+	var jobs []batchv1.Job
+	for _, item := range list.Items {
+		if !metav1.IsControlledBy(&item, cronJob) {
+			continue
+		}
+		job := batchv1.Job{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &job); err == nil {
+			jobs = append(jobs, job)
+		}
+	}
+	return evaluateCronJob(cronJob, jobs, p.Tolerance, p.MaxDuration, time.Now())
+}
+
+// evaluateCronJob applies the checks of a cronjob probe to a CronJob and
+// the Jobs it owns at now.
+func evaluateCronJob(cronJob *batchv1.CronJob, jobs []batchv1.Job, tolerance, maxDuration time.Duration, now time.Time) Result {
+	if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+		return Result{Message: "CronJob is suspended"}
+	}
+	loc := time.UTC
+	if tz := cronJob.Spec.TimeZone; tz != nil && *tz != "" {
+		l, err := time.LoadLocation(*tz)
+		if err != nil {
+			return Result{Message: fmt.Sprintf("unknown time zone %q", *tz)}
+		}
+		loc = l
+	}
+	sched, err := schedule.ParseInLocation(cronJob.Spec.Schedule, loc)
+	if err != nil {
+		return Result{Message: fmt.Sprintf("schedule %q: %v", cronJob.Spec.Schedule, err)}
+	}
+
+	// Newest run first
+	sort.Slice(jobs, func(i, j int) bool {
+		return scheduledTime(&jobs[i]).After(scheduledTime(&jobs[j]))
+	})
+
+	var lastFinished *batchv1.Job
+	for i := range jobs {
+		job := &jobs[i]
+		if _, finished := jobFinished(job); finished {
+			if lastFinished == nil {
+				lastFinished = job
+			}
+			continue
+		}
+		if maxDuration > 0 && job.Status.StartTime != nil && now.Sub(job.Status.StartTime.Time) > maxDuration {
+			return Result{Message: fmt.Sprintf("run %s running for %s, max %s", job.Name, age(now, job.Status.StartTime.Time), maxDuration)}
+		}
+	}
+
+	if lastFinished != nil {
+		end, _ := jobFinished(lastFinished)
+		if cond := jobCondition(lastFinished, batchv1.JobFailed); cond != nil {
+			msg := cond.Message
+			if msg == "" {
+				msg = cond.Reason
+			}
+			return Result{Message: fmt.Sprintf("last run %s failed %s ago: %s", lastFinished.Name, age(now, end), msg)}
+		}
+		if start := lastFinished.Status.StartTime; maxDuration > 0 && start != nil && end.Sub(start.Time) > maxDuration {
+			return Result{Message: fmt.Sprintf("last run %s took %s, max %s", lastFinished.Name, end.Sub(start.Time).Round(time.Second), maxDuration)}
+		}
+	}
+
+	// The run of the last activation that should have started by now
+	expected := sched.Prev(now.Add(-tolerance))
+	var lastSchedule time.Time
+	if cronJob.Status.LastScheduleTime != nil {
+		lastSchedule = cronJob.Status.LastScheduleTime.Time
+	}
+	if len(jobs) > 0 && scheduledTime(&jobs[0]).After(lastSchedule) {
+		lastSchedule = scheduledTime(&jobs[0])
+	}
+	if !expected.IsZero() && expected.After(cronJob.CreationTimestamp.Time) && lastSchedule.Before(expected) {
+		return Result{Message: fmt.Sprintf("missed the run scheduled at %s", expected.UTC().Format(time.RFC3339))}
+	}
+
+	switch {
+	case lastFinished != nil:
+		end, _ := jobFinished(lastFinished)
+		msg := fmt.Sprintf("last run %s succeeded %s ago", lastFinished.Name, age(now, end))
+		if start := lastFinished.Status.StartTime; start != nil {
+			msg += fmt.Sprintf(" (took %s)", end.Sub(start.Time).Round(time.Second))
+		}
+		return Result{Healthy: true, Message: msg}
+	case len(jobs) > 0:
+		return Result{Healthy: true, Message: fmt.Sprintf("first run %s in progress", jobs[0].Name)}
+	default:
+		return Result{Healthy: true, Message: "waiting for the first run"}
+	}
+}
+
+// scheduledTime returns the schedule time a Job was created for, or its
+// creation time on clusters that do not record it.
+func scheduledTime(job *batchv1.Job) time.Time {
+	if v, ok := job.Annotations[scheduledTimestampAnnotation]; ok {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+	}
+	return job.CreationTimestamp.Time
+}
+
+// jobFinished returns when a Job completed or failed.
+func jobFinished(job *batchv1.Job) (time.Time, bool) {
+	for _, t := range []batchv1.JobConditionType{batchv1.JobComplete, batchv1.JobFailed} {
+		if cond := jobCondition(job, t); cond != nil {
+			if t == batchv1.JobComplete && job.Status.CompletionTime != nil {
+				return job.Status.CompletionTime.Time, true
+			}
+			return cond.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func jobCondition(job *batchv1.Job, condType batchv1.JobConditionType) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		if c := &job.Status.Conditions[i]; c.Type == condType && c.Status == corev1.ConditionTrue {
+			return c
+		}
+	}
+	return nil
+}
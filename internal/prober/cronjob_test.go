@@ -0,0 +1,176 @@
+package prober
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// nightly runs at 02:00 and was created well before the runs below.
+func nightlyCronJob() *batchv1.CronJob {
+	return &batchv1.CronJob{
+		TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "ops",
+			Name:              "backup",
+			UID:               types.UID("cronjob-uid"),
+			CreationTimestamp: metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+		},
+		Spec: batchv1.CronJobSpec{Schedule: "0 2 * * *"},
+	}
+}
+
+// run is a Job of cronJob scheduled at scheduled, started a minute later and
+// finished with condType after took. An empty condType leaves it running.
+func run(cronJob *batchv1.CronJob, scheduled time.Time, took time.Duration, condType batchv1.JobConditionType) batchv1.Job {
+	start := metav1.NewTime(scheduled.Add(time.Minute))
+	job := batchv1.Job{
+		TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         cronJob.Namespace,
+			Name:              cronJob.Name + "-" + scheduled.Format("0102"),
+			CreationTimestamp: start,
+			Annotations:       map[string]string{scheduledTimestampAnnotation: scheduled.Format(time.RFC3339)},
+			OwnerReferences:   []metav1.OwnerReference{*metav1.NewControllerRef(cronJob, batchv1.SchemeGroupVersion.WithKind("CronJob"))},
+		},
+		Status: batchv1.JobStatus{StartTime: &start},
+	}
+	if condType != "" {
+		end := metav1.NewTime(start.Add(took))
+		job.Status.Conditions = []batchv1.JobCondition{{
+			Type: condType, Status: corev1.ConditionTrue, LastTransitionTime: end, Reason: "BackoffLimitExceeded",
+		}}
+		if condType == batchv1.JobComplete {
+			job.Status.CompletionTime = &end
+		}
+	}
+	return job
+}
+
+func TestEvaluateCronJob(t *testing.T) {
+	cronJob := nightlyCronJob()
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	today := time.Date(2024, 3, 15, 2, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+	suspended := nightlyCronJob()
+	suspended.Spec.Suspend = new(bool)
+	*suspended.Spec.Suspend = true
+	fresh := nightlyCronJob()
+	fresh.CreationTimestamp = metav1.NewTime(now.Add(-time.Hour))
+
+	tests := []struct {
+		name    string
+		cronJob *batchv1.CronJob
+		jobs    []batchv1.Job
+		now     time.Time
+		healthy bool
+		message string
+	}{
+		{"succeeded", cronJob, []batchv1.Job{run(cronJob, yesterday, time.Hour, batchv1.JobFailed), run(cronJob, today, 4*time.Minute, batchv1.JobComplete)}, now, true, "succeeded 9h55m0s ago (took 4m0s)"},
+		{"failed", cronJob, []batchv1.Job{run(cronJob, yesterday, time.Minute, batchv1.JobComplete), run(cronJob, today, time.Minute, batchv1.JobFailed)}, now, false, "last run backup-0315 failed"},
+		{"missed", cronJob, []batchv1.Job{run(cronJob, yesterday, time.Minute, batchv1.JobComplete)}, now, false, "missed the run scheduled at 2024-03-15T02:00:00Z"},
+		{"within tolerance", cronJob, []batchv1.Job{run(cronJob, yesterday, time.Minute, batchv1.JobComplete)}, today.Add(3 * time.Minute), true, "succeeded"},
+		{"running too long", cronJob, []batchv1.Job{run(cronJob, today, 0, "")}, now, false, "run backup-0315 running for 9h59m0s, max 2h0m0s"},
+		{"took too long", cronJob, []batchv1.Job{run(cronJob, today, 3*time.Hour, batchv1.JobComplete)}, now, false, "took 3h0m0s, max 2h0m0s"},
+		{"first run in progress", fresh, []batchv1.Job{run(fresh, today, 0, "")}, today.Add(time.Hour), true, "in progress"},
+		{"no run yet", fresh, nil, now, true, "waiting for the first run"},
+		{"suspended", suspended, nil, now, false, "suspended"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := evaluateCronJob(tt.cronJob, tt.jobs, 5*time.Minute, 2*time.Hour, tt.now)
+			if res.Healthy != tt.healthy {
+				t.Errorf("healthy = %v; want %v (%s)", res.Healthy, tt.healthy, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Errorf("message = %q; want it to contain %q", res.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestCronJobProber(t *testing.T) {
+	toObject := func(obj interface{}) *unstructured.Unstructured {
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &unstructured.Unstructured{Object: u}
+	}
+	cronJob := nightlyCronJob()
+	// Schedule every minute so the last run is always due
+	cronJob.Spec.Schedule = "* * * * *"
+	last := run(cronJob, time.Now().Add(-2*time.Minute), 0, batchv1.JobFailed)
+	other := run(cronJob, time.Now().Add(-time.Minute), 0, batchv1.JobComplete)
+	other.Name, other.OwnerReferences = "manual", nil
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			cronJobsResource: "CronJobList",
+			jobsResource:     "JobList",
+		},
+		toObject(cronJob), toObject(&last), toObject(&other))
+
+	p := NewCronJobProber(client, "ops", "backup", &CronJobOptions{Tolerance: "10m"})
+	// The Job the CronJob does not own is ignored
+	if res := p.CheckResult(); res.Healthy || !strings.Contains(res.Message, "failed") {
+		t.Errorf("result = %+v; want the failed run of the CronJob", res)
+	}
+
+	missing := NewCronJobProber(client, "ops", "restore", &CronJobOptions{})
+	if res := missing.CheckResult(); res.Healthy {
+		t.Errorf("a missing CronJob was healthy")
+	}
+}
+
+func TestCronJobRegistration(t *testing.T) {
+	for _, target := range []string{"backup", "ops/backup"} {
+		if got := Validate("cronjob", target, nil); len(got) != 0 {
+			t.Errorf("Validate(%q) = %v", target, got)
+		}
+	}
+	for _, target := range []string{"", "a/b/c", "Ops/backup", "ops/Backup"} {
+		if got := Validate("cronjob", target, nil); len(got) == 0 {
+			t.Errorf("Validate(%q) succeeded; want an error", target)
+		}
+	}
+	for options, problems := range map[string]int{
+		`{"tolerance": "10m", "maxDuration": "2h"}`: 0,
+		`{"tolerance": "late"}`:                     1,
+		`{"maxDuration": "0s"}`:                     1,
+		`{"tolerence": "10m"}`:                      1,
+	} {
+		if got := Validate("cronjob", "backup", json.RawMessage(options)); len(got) != problems {
+			t.Errorf("Validate(%s) = %v; want %d problems", options, got, problems)
+		}
+	}
+
+	SetKubernetesClient(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()))
+	defer SetKubernetesClient(nil)
+	if _, err := NewFor(ProbeInfo{Name: "backup"}, "cronjob", "backup", nil); err == nil {
+		t.Errorf("expected a ClusterProbe to need the namespace in the target")
+	}
+	if _, err := NewFor(ProbeInfo{Namespace: "shop", Name: "backup"}, "cronjob", "ops/backup", nil); err == nil {
+		t.Errorf("expected a Probe to be limited to the CronJobs of its namespace")
+	}
+	if _, err := NewFor(ProbeInfo{Name: "backup"}, "cronjob", "ops/backup", nil); err != nil {
+		t.Errorf("NewFor() of a ClusterProbe error: %v", err)
+	}
+	p, err := NewFor(ProbeInfo{Namespace: "ops", Name: "backup"}, "cronjob", "backup", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := p.(*CronJobProber); c.Namespace != "ops" || c.Tolerance != 5*time.Minute {
+		t.Errorf("prober = %+v; want the probe namespace and the default tolerance", c)
+	}
+}
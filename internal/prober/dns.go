@@ -0,0 +1,54 @@
+package prober
+
+import (
+	"context"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func init() {
+	Register("dns", Type{
+		Validate: func(target string, _ interface{}) []string { return validateDNSName(target) },
+		New:      func(target string, _ interface{}) (Prober, error) { return NewDnsProber(target), nil },
+		NewFor: func(probe ProbeInfo, target string, _ interface{}) (Prober, error) {
+			return &DnsProber{Host: target, Timeout: probe.Timeout}, nil
+		},
+	})
+}
+
+type DnsProber struct {
+	Host string
+	// Timeout bounds the lookup, DefaultTimeout when zero.
+	Timeout time.Duration
+}
+
+func NewDnsProber(host string) *DnsProber {
+	return &DnsProber{Host: host}
+}
+
+func (p *DnsProber) Check() bool {
+	return p.CheckContext(context.Background()).Healthy
+}
+
+func (p *DnsProber) CheckContext(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout(p.Timeout))
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, p.Host)
+	if err != nil {
+		log.Printf("[DNS] Lookup of %s failed: %v", p.Host, err)
+		return Result{}
+	}
+	return Result{Healthy: len(addrs) > 0}
+}
+
+func validateDNSName(s string) []string {
+	if s == "" {
+		return []string{"must be a DNS name"}
+	}
+	return validation.IsDNS1123Subdomain(strings.ToLower(strings.TrimSuffix(s, ".")))
+}
@@ -0,0 +1,16 @@
+package prober
+
+import "testing"
+
+func TestDnsProber_Check(t *testing.T) {
+	prober := NewDnsProber("localhost")
+	if !prober.Check() {
+		t.Errorf("expected true for localhost, got false")
+	}
+
+	// .invalid is reserved and must never resolve (RFC 2606)
+	proberInvalid := NewDnsProber("heartbeat.invalid")
+	if proberInvalid.Check() {
+		t.Errorf("expected false for .invalid name, got true")
+	}
+}
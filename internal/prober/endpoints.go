@@ -0,0 +1,382 @@
+package prober
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// Endpoint policies decide when a fanned out check is healthy.
+const (
+	EndpointPolicyAll        = "All"
+	EndpointPolicyAny        = "Any"
+	EndpointPolicyPercentage = "Percentage"
+)
+
+var (
+	servicesResource       = schema.GroupVersionResource{Version: "v1", Resource: "services"}
+	endpointSlicesResource = schema.GroupVersionResource{Group: "discovery.k8s.io", Version: "v1", Resource: "endpointslices"}
+)
+
+// EndpointOptions are the options of http and tcp checks.
+type EndpointOptions struct {
+	// Endpoints probes every ready endpoint of the Service the target
+	// points at, instead of going through the Service address.
+	Endpoints *FanOut `json:"endpoints,omitempty"`
+}
+
+// FanOut aggregates the results of the endpoints of a Service.
+type FanOut struct {
+	// Policy is All (the default), Any or Percentage.
+	Policy string `json:"policy,omitempty"`
+	// MinHealthyPercent is the share of endpoints that must pass with the
+	// Percentage policy.
+	MinHealthyPercent int32 `json:"minHealthyPercent,omitempty"`
+}
+
+func decodeEndpointOptions(raw json.RawMessage) (interface{}, error) {
+	opts := &EndpointOptions{}
+	if err := decodeStrict(raw, opts); err != nil {
+		return nil, err
+	}
+	if err := validateFanOut(opts.Endpoints); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// decodeStrict decodes raw into v, which keeps its values when raw is
+// empty. Unknown fields are an error.
+func decodeStrict(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	dec := json.NewDecoder(strings.NewReader(string(raw)))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func validateFanOut(f *FanOut) error {
+	if f == nil {
+		return nil
+	}
+	switch f.Policy {
+	case "", EndpointPolicyAll, EndpointPolicyAny:
+	case EndpointPolicyPercentage:
+		if f.MinHealthyPercent < 1 || f.MinHealthyPercent > 100 {
+			return errors.New("endpoints.minHealthyPercent must be between 1 and 100")
+		}
+	default:
+		return fmt.Errorf("endpoints.policy must be one of %s, %s, %s", EndpointPolicyAll, EndpointPolicyAny, EndpointPolicyPercentage)
+	}
+	return nil
+}
+
+// fanOutOf returns the fan-out settings of decoded http or tcp options.
+func fanOutOf(options interface{}) *FanOut {
+	switch opts := options.(type) {
+	case *EndpointOptions:
+		return opts.Endpoints
+	case *HTTPOptions:
+		return opts.Endpoints
+	}
+	return nil
+}
+
+// serviceRef is the Service a target host names: "<service>.<namespace>.svc"
+// with an optional cluster domain.
+type serviceRef struct {
+	name      string
+	namespace string
+	port      int32
+}
+
+func parseServiceHost(host string, port int) (serviceRef, error) {
+	parts := strings.Split(strings.TrimSuffix(host, "."), ".")
+	if len(parts) < 3 || parts[2] != "svc" || parts[0] == "" || parts[1] == "" {
+		return serviceRef{}, fmt.Errorf("host %q must be a Service name like <service>.<namespace>.svc to probe its endpoints", host)
+	}
+	return serviceRef{name: parts[0], namespace: parts[1], port: int32(port)}, nil
+}
+
+// checkNamespace keeps a Probe to the Services of its own namespace, the
+// endpoints of others need a ClusterProbe.
+func (s serviceRef) checkNamespace(probe ProbeInfo) error {
+	if probe.Namespace != "" && s.namespace != probe.Namespace {
+		return fmt.Errorf("endpoint checks of a Probe can only resolve Services in namespace %s", probe.Namespace)
+	}
+	return nil
+}
+
+// EndpointProber resolves the ready endpoints of a Service on every check and
+// runs a check against each of them.
+type EndpointProber struct {
+	Client dynamic.Interface
+	Target string
+	FanOut *FanOut
+	// Timeout bounds resolving the endpoints and the check of every
+	// endpoint, DefaultTimeout when zero.
+	Timeout time.Duration
+
+	service serviceRef
+	// http holds the request of an http check, nil for tcp.
+	http *HTTPOptions
+	// probe builds the check of one endpoint address.
+	probe func(host string, port int32, guard *targetGuard) (target string, p Prober)
+	// guard enforces the target policy on every endpoint, nil without one.
+	guard *targetGuard
+}
+
+// NewHttpEndpointProber fans an http check out to the endpoints of the
+// Service in the URL host.
+func NewHttpEndpointProber(client dynamic.Interface, target string, fanOut *FanOut) (*EndpointProber, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	port := 80
+	if u.Scheme == "https" {
+		port = 443
+	}
+	if u.Port() != "" {
+		if port, err = strconv.Atoi(u.Port()); err != nil {
+			return nil, err
+		}
+	}
+	svc, err := parseServiceHost(u.Hostname(), port)
+	if err != nil {
+		return nil, err
+	}
+	ep := &EndpointProber{Client: client, Target: target, FanOut: fanOut, service: svc}
+	// The request keeps the URL, so TLS verifies the Service name and the
+	// Host header names it, only the connection goes to the endpoint
+	ep.probe = func(host string, port int32, guard *targetGuard) (string, Prober) {
+		eu := *u
+		eu.Host = net.JoinHostPort(host, strconv.Itoa(int(port)))
+		hp := &HttpProber{URL: target, Timeout: ep.Timeout, guard: guard, dialAddress: eu.Host}
+		ep.http.apply(hp)
+		return eu.String(), hp
+	}
+	return ep, nil
+}
+
+// NewTcpEndpointProber fans a tcp check out to the endpoints of the Service
+// in the address host.
+func NewTcpEndpointProber(client dynamic.Interface, target string, fanOut *FanOut) (*EndpointProber, error) {
+	host, portStr, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := parseServiceHost(host, port)
+	if err != nil {
+		return nil, err
+	}
+	ep := &EndpointProber{Client: client, Target: target, FanOut: fanOut, service: svc}
+	ep.probe = func(host string, port int32, guard *targetGuard) (string, Prober) {
+		addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
+		return addr, &TcpProber{Address: addr, Timeout: ep.Timeout, guard: guard}
+	}
+	return ep, nil
+}
+
+func (p *EndpointProber) Check() bool {
+	return p.CheckResult().Healthy
+}
+
+func (p *EndpointProber) CheckResult() Result {
+	return p.CheckContext(context.Background())
+}
+
+func (p *EndpointProber) CheckContext(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout(p.Timeout))
+	defer cancel()
+
+	addrs, err := p.resolve(ctx)
+	if err != nil {
+		log.Printf("[Endpoints] Failed to resolve endpoints of %s: %v", p.Target, err)
+		return Result{Message: err.Error()}
+	}
+	if len(addrs) == 0 {
+		return Result{Message: fmt.Sprintf("service %s/%s has no ready endpoints", p.service.namespace, p.service.name)}
+	}
+
+	results := make([]EndpointResult, len(addrs))
+	denied := make([]bool, len(addrs))
+	var wg sync.WaitGroup
+	for i, a := range addrs {
+		wg.Add(1)
+		go func(i int, a endpointAddress) {
+			defer wg.Done()
+			target, ep := p.probe(a.ip, a.port, p.guard)
+			res := RunContext(ctx, ep)
+			denied[i] = res.PolicyDenied
+			results[i] = EndpointResult{
+				Address: net.JoinHostPort(a.ip, strconv.Itoa(int(a.port))),
+				Target:  target,
+				Healthy: res.Healthy,
+				Message: res.Message,
+			}
+			if a.pod != "" {
+				results[i].Message = a.pod + ": " + res.Message
+			}
+		}(i, a)
+	}
+	wg.Wait()
+
+	res := aggregateEndpoints(p.FanOut, results)
+	for i, d := range denied {
+		if d {
+			res.Healthy, res.PolicyDenied = false, true
+			res.Message = results[i].Message
+			break
+		}
+	}
+	return res
+}
+
+// aggregateEndpoints applies the policy to the endpoint results.
+func aggregateEndpoints(f *FanOut, results []EndpointResult) Result {
+	healthy := 0
+	var failing []string
+	for _, r := range results {
+		if r.Healthy {
+			healthy++
+		} else {
+			failing = append(failing, r.Address)
+		}
+	}
+	total := len(results)
+
+	var ok bool
+	policy := f.Policy
+	switch policy {
+	case EndpointPolicyAny:
+		ok = healthy > 0
+	case EndpointPolicyPercentage:
+		ok = healthy*100 >= int(f.MinHealthyPercent)*total
+		policy = fmt.Sprintf("%s %d%%", policy, f.MinHealthyPercent)
+	default:
+		policy = EndpointPolicyAll
+		ok = healthy == total
+	}
+
+	msg := fmt.Sprintf("%d/%d endpoints healthy (%s)", healthy, total, policy)
+	if len(failing) > 0 {
+		msg += ", failing: " + strings.Join(failing, ", ")
+	}
+	return Result{Healthy: ok, Message: msg, Endpoints: results}
+}
+
+type endpointAddress struct {
+	ip   string
+	port int32
+	pod  string
+}
+
+// resolve returns the ready addresses behind the Service port, sorted. The
+// Service port is mapped to the target port through its name, the way
+// kube-proxy does.
+func (p *EndpointProber) resolve(ctx context.Context) ([]endpointAddress, error) {
+	obj, err := p.Client.Resource(servicesResource).Namespace(p.service.namespace).Get(ctx, p.service.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var svc corev1.Service
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &svc); err != nil {
+		return nil, err
+	}
+	var svcPort *corev1.ServicePort
+	for i := range svc.Spec.Ports {
+		if svc.Spec.Ports[i].Port == p.service.port {
+			svcPort = &svc.Spec.Ports[i]
+		}
+	}
+	if svcPort == nil {
+		return nil, fmt.Errorf("service %s/%s has no port %d", p.service.namespace, p.service.name, p.service.port)
+	}
+
+	list, err := p.Client.Resource(endpointSlicesResource).Namespace(p.service.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + p.service.name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var addrs []endpointAddress
+	for _, item := range list.Items {
+		var slice discoveryv1.EndpointSlice
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &slice); err != nil {
+			return nil, err
+		}
+		if slice.AddressType == discoveryv1.AddressTypeFQDN {
+			continue
+		}
+		port, found := slicePort(slice.Ports, svcPort)
+		if !found {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
+			}
+			if len(ep.Addresses) == 0 {
+				continue
+			}
+			a := endpointAddress{ip: ep.Addresses[0], port: port}
+			if ep.TargetRef != nil && ep.TargetRef.Kind == "Pod" {
+				a.pod = ep.TargetRef.Name
+			}
+			key := net.JoinHostPort(a.ip, strconv.Itoa(int(a.port)))
+			if !seen[key] {
+				seen[key] = true
+				addrs = append(addrs, a)
+			}
+		}
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		if addrs[i].ip != addrs[j].ip {
+			return addrs[i].ip < addrs[j].ip
+		}
+		return addrs[i].port < addrs[j].port
+	})
+	return addrs, nil
+}
+
+// slicePort finds the endpoint port serving the Service port.
+func slicePort(ports []discoveryv1.EndpointPort, svcPort *corev1.ServicePort) (int32, bool) {
+	for _, sp := range ports {
+		name := ""
+		if sp.Name != nil {
+			name = *sp.Name
+		}
+		if name != svcPort.Name || sp.Port == nil {
+			continue
+		}
+		if sp.Protocol != nil && svcPort.Protocol != "" && *sp.Protocol != svcPort.Protocol {
+			continue
+		}
+		return *sp.Port, true
+	}
+	return 0, false
+}
@@ -0,0 +1,247 @@
+package prober
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func toUnstructured(t *testing.T, obj interface{}) runtime.Object {
+	t.Helper()
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		t.Fatalf("ToUnstructured: %v", err)
+	}
+	return &unstructured.Unstructured{Object: u}
+}
+
+// endpointSlice serves the "http" port of the Service on port from addresses.
+func endpointSlice(name string, port int32, ready map[string]bool) *discoveryv1.EndpointSlice {
+	portName := "http"
+	s := &discoveryv1.EndpointSlice{
+		TypeMeta:    metav1.TypeMeta{APIVersion: "discovery.k8s.io/v1", Kind: "EndpointSlice"},
+		ObjectMeta:  metav1.ObjectMeta{Namespace: "shop", Name: name, Labels: map[string]string{discoveryv1.LabelServiceName: "api"}},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: &portName, Port: &port}},
+	}
+	for addr, r := range ready {
+		r := r
+		s.Endpoints = append(s.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{addr},
+			Conditions: discoveryv1.EndpointConditions{Ready: &r},
+		})
+	}
+	return s
+}
+
+func serverPort(t *testing.T, srv *httptest.Server) int32 {
+	t.Helper()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return int32(n)
+}
+
+func TestEndpointProber(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	svc := &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "api"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80, Protocol: corev1.ProtocolTCP}}},
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			servicesResource:       "ServiceList",
+			endpointSlicesResource: "EndpointSliceList",
+		},
+		toUnstructured(t, svc),
+		// Endpoints on the same address are told apart by their port
+		toUnstructured(t, endpointSlice("api-ok", serverPort(t, ok), map[string]bool{"127.0.0.1": true, "10.0.0.9": false})),
+		toUnstructured(t, endpointSlice("api-broken", serverPort(t, broken), map[string]bool{"127.0.0.1": true})),
+	)
+
+	tests := []struct {
+		name    string
+		target  string
+		fanOut  FanOut
+		healthy bool
+		message string
+	}{
+		{"all", "http://api.shop.svc/health", FanOut{}, false, "1/2 endpoints healthy (All), failing: 127.0.0.1:" + strconv.Itoa(int(serverPort(t, broken)))},
+		{"any", "http://api.shop.svc.cluster.local/health", FanOut{Policy: EndpointPolicyAny}, true, "1/2 endpoints healthy (Any)"},
+		{"percentage met", "http://api.shop.svc:80/", FanOut{Policy: EndpointPolicyPercentage, MinHealthyPercent: 50}, true, "1/2 endpoints healthy (Percentage 50%)"},
+		{"percentage missed", "http://api.shop.svc/", FanOut{Policy: EndpointPolicyPercentage, MinHealthyPercent: 51}, false, "(Percentage 51%)"},
+		{"unknown port", "http://api.shop.svc:8080/", FanOut{}, false, "has no port 8080"},
+		{"unknown service", "http://web.shop.svc/", FanOut{}, false, "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.fanOut
+			p, err := NewHttpEndpointProber(client, tt.target, &f)
+			if err != nil {
+				t.Fatalf("NewHttpEndpointProber: %v", err)
+			}
+			res := p.CheckResult()
+			if res.Healthy != tt.healthy {
+				t.Errorf("healthy = %v; want %v (%s)", res.Healthy, tt.healthy, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Errorf("message = %q; want it to contain %q", res.Message, tt.message)
+			}
+		})
+	}
+
+	// Not ready endpoints are skipped, every ready one is reported
+	p, _ := NewTcpEndpointProber(client, "api.shop.svc:80", &FanOut{})
+	res := p.CheckResult()
+	if !res.Healthy || len(res.Endpoints) != 2 {
+		t.Errorf("tcp fan-out = %+v; want 2 healthy endpoints", res)
+	}
+	for _, e := range res.Endpoints {
+		if strings.HasPrefix(e.Address, "10.0.0.9") {
+			t.Errorf("not ready endpoint %s was probed", e.Address)
+		}
+	}
+}
+
+// serviceCert issues a certificate for name, and a pool trusting it.
+func serviceCert(t *testing.T, name string) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+// The endpoints of an https Service are verified against the Service name
+// and asked for it, only the connection goes to their address.
+func TestEndpointProberTLS(t *testing.T) {
+	cert, pool := serviceCert(t, "api.shop.svc")
+	var hosts []string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	transport := http.DefaultTransport.(*http.Transport)
+	defer func(c *tls.Config) { transport.TLSClientConfig = c }(transport.TLSClientConfig)
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+
+	svc := &corev1.Service{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "api"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 443, Protocol: corev1.ProtocolTCP}}},
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			servicesResource:       "ServiceList",
+			endpointSlicesResource: "EndpointSliceList",
+		},
+		toUnstructured(t, svc),
+		toUnstructured(t, endpointSlice("api-tls", serverPort(t, srv), map[string]bool{"127.0.0.1": true})),
+	)
+
+	p, err := NewHttpEndpointProber(client, "https://api.shop.svc/health", &FanOut{})
+	if err != nil {
+		t.Fatalf("NewHttpEndpointProber: %v", err)
+	}
+	if res := p.CheckResult(); !res.Healthy {
+		t.Fatalf("CheckResult() = %+v; want the endpoint to pass TLS verification", res)
+	}
+	if len(hosts) != 1 || hosts[0] != "api.shop.svc" {
+		t.Errorf("Host = %v; want api.shop.svc", hosts)
+	}
+}
+
+func TestEndpointOptions(t *testing.T) {
+	tests := []struct {
+		checkType string
+		target    string
+		options   string
+		problems  int
+	}{
+		{"http", "http://api.shop.svc/health", `{"endpoints": {}}`, 0},
+		{"http", "http://api.shop.svc/health", `{"endpoints": {"policy": "Percentage", "minHealthyPercent": 80}}`, 0},
+		{"http", "http://api.shop.svc/health", `{"endpoints": {"policy": "Percentage"}}`, 1},
+		{"http", "http://api.shop.svc/health", `{"endpoints": {"policy": "Most"}}`, 1},
+		{"http", "https://example.com/health", `{"endpoints": {}}`, 1},
+		{"tcp", "postgres.db.svc.cluster.local:5432", `{"endpoints": {"policy": "Any"}}`, 0},
+		{"tcp", "postgres:5432", `{"endpoints": {}}`, 1},
+	}
+	for _, tt := range tests {
+		got := Validate(tt.checkType, tt.target, json.RawMessage(tt.options))
+		if len(got) != tt.problems {
+			t.Errorf("Validate(%q, %q, %s) = %v; want %d problems", tt.checkType, tt.target, tt.options, got, tt.problems)
+		}
+	}
+}
+
+func TestEndpointNamespace(t *testing.T) {
+	SetKubernetesClient(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()))
+	defer SetKubernetesClient(nil)
+
+	options := json.RawMessage(`{"endpoints": {}}`)
+	for checkType, target := range map[string]string{
+		"http": "http://postgres.db.svc/health",
+		"tcp":  "postgres.db.svc:5432",
+	} {
+		if _, err := NewFor(ProbeInfo{Namespace: "shop", Name: "api"}, checkType, target, options); err == nil {
+			t.Errorf("NewFor(%s, %q) succeeded; want a Probe limited to the Services of its namespace", checkType, target)
+		}
+		if _, err := NewFor(ProbeInfo{Namespace: "db", Name: "postgres"}, checkType, target, options); err != nil {
+			t.Errorf("NewFor(%s, %q) in the namespace of the Service: %v", checkType, target, err)
+		}
+		if _, err := NewFor(ProbeInfo{Name: "postgres"}, checkType, target, options); err != nil {
+			t.Errorf("NewFor(%s, %q) of a ClusterProbe: %v", checkType, target, err)
+		}
+	}
+}
@@ -1,13 +1,118 @@
 package prober
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os/exec"
 	"strings"
+	"syscall"
+	"time"
 )
 
+func init() {
+	Register("exec", Type{
+		Decode: decodeExecOptions,
+		Validate: func(target string, options interface{}) []string {
+			var problems []string
+			if len(strings.Fields(target)) == 0 {
+				problems = append(problems, "must be a command to run")
+			}
+			opts := options.(*ExecOptions)
+			if opts.Pod == nil && opts.Job == nil {
+				if fields := strings.Fields(target); len(fields) > 0 {
+					policy, _ := currentExecPolicy()
+					if _, err := policy.allowedCommand(fields[0]); err != nil {
+						problems = append(problems, err.Error())
+					}
+				}
+			}
+			if opts.Pod != nil {
+				problems = append(problems, opts.Pod.validate()...)
+			}
+			if opts.Job != nil {
+				problems = append(problems, opts.Job.validate()...)
+			}
+			if opts.Pod != nil && opts.Job != nil {
+				problems = append(problems, "only one of pod and job may be set")
+			}
+			return problems
+		},
+		New: func(target string, options interface{}) (Prober, error) {
+			if opts := options.(*ExecOptions); opts.Pod != nil || opts.Job != nil {
+				return nil, errors.New("exec checks in a pod or Job can only be built for a probe")
+			}
+			return NewExecProber(target), nil
+		},
+		NewFor: func(probe ProbeInfo, target string, options interface{}) (Prober, error) {
+			opts := options.(*ExecOptions)
+			switch {
+			case opts.Pod != nil:
+				return newPodExecProberFor(probe, target, opts.Pod)
+			case opts.Job != nil:
+				return newJobExecProberFor(probe, target, opts.Job)
+			case !ExecEnabledIn(probe.Namespace):
+				return nil, fmt.Errorf("exec checks in the operator are not enabled for namespace %s", probe.Namespace)
+			}
+			p := NewExecProber(target)
+			p.Timeout = probe.Timeout
+			return p, nil
+		},
+	})
+}
+
+// ExecOptions tune an exec check. Without Pod and Job the command runs in
+// the operator container.
+type ExecOptions struct {
+	// Pod runs the command in a container of another pod instead.
+	Pod *PodSelector `json:"pod,omitempty"`
+	// Job runs the command in a Job of its own instead.
+	Job *JobSpec `json:"job,omitempty"`
+}
+
+func decodeExecOptions(raw json.RawMessage) (interface{}, error) {
+	opts := &ExecOptions{}
+	if len(raw) > 0 && string(raw) != "null" {
+		dec := json.NewDecoder(strings.NewReader(string(raw)))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(opts); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// maxExecOutput is how much of the output of a failed command ends up in
+// the log of the operator.
+const maxExecOutput = 256
+
+// execMessage reports the exit code of a command run by source. The output
+// may hold anything the command printed, secrets included, and the status
+// is readable by everyone who can read the Probe, so only the log gets the
+// tail of it.
+func execMessage(source string, code int, output []byte) string {
+	if out := execOutputTail(output); code != 0 && out != "" {
+		log.Printf("[Exec] %s exited with code %d: %s", source, code, out)
+	}
+	return fmt.Sprintf("exit code %d", code)
+}
+
+// execOutputTail is the end of output on a single line.
+func execOutputTail(output []byte) string {
+	out := bytes.TrimSpace(output)
+	if len(out) > maxExecOutput {
+		out = append([]byte("..."), out[len(out)-maxExecOutput:]...)
+	}
+	return strings.Join(strings.Fields(string(out)), " ")
+}
+
 type ExecProber struct {
 	Command []string
+	// Timeout kills the command, DefaultTimeout when zero.
+	Timeout time.Duration
 }
 
 func NewExecProber(cmdStr string) *ExecProber {
@@ -16,14 +121,49 @@ func NewExecProber(cmdStr string) *ExecProber {
 }
 
 func (p *ExecProber) Check() bool {
+	return p.CheckResult().Healthy
+}
+
+func (p *ExecProber) CheckResult() Result {
+	return p.CheckContext(context.Background())
+}
+
+// CheckContext runs the command under the exec policy, with a scrubbed
+// environment and its limits.
+func (p *ExecProber) CheckContext(ctx context.Context) Result {
 	if len(p.Command) == 0 {
-		return false
+		return Result{Message: "no command to run"}
 	}
-	cmd := exec.Command(p.Command[0], p.Command[1:]...)
-	err := cmd.Run()
+	timeout := attemptTimeout(ctx, p.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	policy, sandbox := currentExecPolicy()
+	cmd, err := policy.command(ctx, sandbox, p.Command)
 	if err != nil {
+		log.Printf("[Exec] Cannot run %s: %v", p.Command[0], err)
+		return Result{Message: err.Error()}
+	}
+	output := &cappedBuffer{max: policy.MaxOutput, exceeded: func() { _ = cmd.Process.Kill() }}
+	cmd.Stdout, cmd.Stderr = output, output
+
+	err = cmd.Run()
+	switch {
+	case output.overflow:
+		return Result{Message: fmt.Sprintf("stopped after more than %d bytes of output", policy.MaxOutput)}
+	case ctx.Err() != nil:
+		log.Printf("[Exec] %s did not finish within %s: %v", p.Command[0], timeout, err)
+		return Result{Message: fmt.Sprintf("timed out after %s", timeout)}
+	case err != nil:
 		log.Printf("[Exec] Command failed: %v", err)
-		return false
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return Result{Message: err.Error()}
+		}
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return Result{Message: fmt.Sprintf("killed by %s", ws.Signal())}
+		}
+		return Result{Message: execMessage(p.Command[0], exitErr.ExitCode(), output.buf)}
 	}
-	return true
+	return Result{Healthy: true, Message: execMessage(p.Command[0], 0, output.buf)}
 }
@@ -0,0 +1,52 @@
+package prober
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecProber(t *testing.T) {
+	res := NewExecProber("echo ok").CheckResult()
+	if !res.Healthy {
+		t.Errorf("result = %+v; want healthy", res)
+	}
+
+	res = NewExecProber("ls /does/not/exist").CheckResult()
+	if res.Healthy || !strings.HasPrefix(res.Message, "exit code") {
+		t.Errorf("result = %+v; want a failure with the exit code", res)
+	}
+
+	if res := NewExecProber("/does/not/exist").CheckResult(); res.Healthy {
+		t.Errorf("a missing executable passed")
+	}
+}
+
+func TestExecProberTimeout(t *testing.T) {
+	// The child keeps the output open after sh is killed
+	p := &ExecProber{Command: []string{"sh", "-c", "sleep 10 & sleep 10"}, Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	res := p.CheckResult()
+	if res.Healthy || res.Message != "timed out after 100ms" {
+		t.Errorf("result = %+v; want a timeout", res)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("check took %s; want it stopped at its timeout", d)
+	}
+}
+
+func TestExecMessage(t *testing.T) {
+	if got := execMessage("psql", 2, []byte("  psql: error:\n password authentication failed\n")); got != "exit code 2" {
+		t.Errorf("message = %q; want only the exit code", got)
+	}
+	if got := execMessage("true", 0, nil); got != "exit code 0" {
+		t.Errorf("message = %q", got)
+	}
+	if got := execOutputTail([]byte("  psql: error:\n connection refused\n")); got != "psql: error: connection refused" {
+		t.Errorf("tail = %q", got)
+	}
+	long := execOutputTail([]byte(strings.Repeat("x", 1000) + "end"))
+	if !strings.HasSuffix(long, "end") || len(long) > maxExecOutput+20 {
+		t.Errorf("long output not truncated to its tail: %q", long)
+	}
+}
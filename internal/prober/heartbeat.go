@@ -0,0 +1,244 @@
+package prober
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"heartbeat-operator/internal/heartbeat"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+)
+
+func init() {
+	Register("heartbeat", Type{
+		Decode: decodeHeartbeatOptions,
+		Validate: func(target string, _ interface{}) []string {
+			return validation.IsDNS1123Subdomain(target)
+		},
+		NewFor: func(probe ProbeInfo, target string, options interface{}) (Prober, error) {
+			client := kubernetesClient()
+			if client == nil {
+				return nil, errors.New("heartbeat checks need access to the Kubernetes API")
+			}
+			if probe.Namespace == "" {
+				return nil, errors.New("heartbeat checks keep their token in a Secret and need a namespace")
+			}
+			opts := options.(*HeartbeatOptions)
+			if probe.Schedule != "" && opts.Grace == "" {
+				return nil, errors.New("heartbeat checks of a scheduled Probe need a grace, the time a ping is expected within")
+			}
+			return NewHeartbeatProber(client, probe, target, opts), nil
+		},
+	})
+}
+
+// HeartbeatTokenKey is the key of the ping token in the Secret of a
+// heartbeat check.
+const HeartbeatTokenKey = "token"
+
+// heartbeatTokenRefresh is how often the token is read from its Secret
+// again, so a replaced token is picked up.
+const heartbeatTokenRefresh = time.Minute
+
+var (
+	secretsResource = schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	probesResource  = schema.GroupVersionResource{Group: "probes.ready.io", Version: "v1alpha1", Resource: "probes"}
+)
+
+// HeartbeatOptions tune a heartbeat check.
+type HeartbeatOptions struct {
+	// Grace is added to the probe interval before a missing ping fails the
+	// check, "1m" by default. A scheduled probe has no interval and needs
+	// it, pings are expected within Grace alone.
+	Grace string `json:"grace,omitempty"`
+}
+
+func decodeHeartbeatOptions(raw json.RawMessage) (interface{}, error) {
+	opts := &HeartbeatOptions{}
+	if err := decodeStrict(raw, opts); err != nil {
+		return nil, err
+	}
+	if opts.Grace != "" {
+		if d, err := time.ParseDuration(opts.Grace); err != nil || d < 0 {
+			return nil, fmt.Errorf("grace must be a non-negative duration, e.g. 5m")
+		}
+	}
+	return opts, nil
+}
+
+// HeartbeatProber checks that the job behind a probe pinged the operator
+// within the probe interval plus a grace period. The target names the
+// Secret holding the ping token, created with a random token when missing.
+// The token is registered for pings until Stop.
+type HeartbeatProber struct {
+	Client dynamic.Interface
+	Probe  ProbeInfo
+	Secret string
+	Grace  time.Duration
+
+	mu      sync.Mutex
+	token   string
+	read    time.Time
+	created time.Time
+}
+
+func NewHeartbeatProber(client dynamic.Interface, probe ProbeInfo, secret string, opts *HeartbeatOptions) *HeartbeatProber {
+	grace := time.Minute
+	if opts.Grace != "" {
+		grace, _ = time.ParseDuration(opts.Grace)
+	}
+	return &HeartbeatProber{Client: client, Probe: probe, Secret: secret, Grace: grace, created: time.Now()}
+}
+
+func (p *HeartbeatProber) Check() bool {
+	return p.CheckResult().Healthy
+}
+
+func (p *HeartbeatProber) CheckResult() Result {
+	token, err := p.ensureToken()
+	if err != nil {
+		log.Printf("[Heartbeat] Failed to get the token of %s/%s: %v", p.Probe.Namespace, p.Probe.Name, err)
+		return Result{Message: fmt.Sprintf("heartbeat token: %v", err)}
+	}
+	state, _ := heartbeat.Get(token)
+	window := p.Probe.Interval + p.Grace
+	if p.Probe.Schedule != "" {
+		window = p.Grace
+	}
+	return evaluateHeartbeat(state, window, p.created, time.Now())
+}
+
+// Stop unregisters the token, pings with it are refused unless another
+// probe uses it.
+func (p *HeartbeatProber) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != "" {
+		heartbeat.Unregister(p.token)
+		p.token = ""
+	}
+}
+
+// evaluateHeartbeat fails when the last run failed or no success ping came
+// within window. Before the first ping the window starts at since, the
+// time the check began.
+func evaluateHeartbeat(s heartbeat.State, window time.Duration, since, now time.Time) Result {
+	res := Result{Heartbeat: &HeartbeatResult{LastPing: s.LastPing(), LastSuccess: s.LastSuccess, RunDuration: s.RunDuration}}
+	switch {
+	case s.Failed():
+		res.Message = fmt.Sprintf("run failed %s ago", age(now, s.LastFail))
+	case s.LastSuccess.IsZero() && now.Sub(since) <= window:
+		res.Healthy = true
+		res.Message = fmt.Sprintf("waiting for the first ping, expected within %s", window)
+	case s.LastSuccess.IsZero():
+		res.Message = fmt.Sprintf("no ping received in %s", window)
+	case now.Sub(s.LastSuccess) > window:
+		res.Message = fmt.Sprintf("last success ping %s ago, expected every %s", age(now, s.LastSuccess), window)
+	default:
+		res.Healthy = true
+		res.Message = fmt.Sprintf("last success ping %s ago", age(now, s.LastSuccess))
+	}
+	if s.RunDuration > 0 {
+		res.Message += fmt.Sprintf(" (run took %s)", s.RunDuration.Round(time.Second))
+	}
+	return res
+}
+
+func age(now, t time.Time) time.Duration {
+	return now.Sub(t).Round(time.Second)
+}
+
+// ensureToken reads the ping token from the Secret, creating the Secret
+// when it does not exist yet, and registers it for pings. The token is read
+// again every heartbeatTokenRefresh, the last one is kept while that fails.
+func (p *HeartbeatProber) ensureToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token != "" && time.Since(p.read) < heartbeatTokenRefresh {
+		return p.token, nil
+	}
+	token, err := p.readToken()
+	if err != nil {
+		if p.token != "" {
+			log.Printf("[Heartbeat] Failed to read the token of %s/%s again, keeping it: %v", p.Probe.Namespace, p.Probe.Name, err)
+			return p.token, nil
+		}
+		return "", err
+	}
+	p.read = time.Now()
+	if token != p.token {
+		heartbeat.Register(token)
+		if p.token != "" {
+			log.Printf("[Heartbeat] Token of %s/%s changed", p.Probe.Namespace, p.Probe.Name)
+			heartbeat.Unregister(p.token)
+		}
+		p.token = token
+	}
+	return p.token, nil
+}
+
+// readToken reads the ping token from the Secret, creating it when it does
+// not exist yet.
+func (p *HeartbeatProber) readToken() (string, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	secrets := p.Client.Resource(secretsResource).Namespace(p.Probe.Namespace)
+
+	secret, err := secrets.Get(ctx, p.Secret, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret, err = secrets.Create(ctx, p.newSecret(ctx), metav1.CreateOptions{})
+		if err == nil {
+			log.Printf("[Heartbeat] Created token Secret %s/%s", p.Probe.Namespace, p.Secret)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	encoded, _, _ := unstructured.NestedString(secret.Object, "data", HeartbeatTokenKey)
+	token, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(token) == 0 {
+		// Only the API server turns stringData into data
+		plain, _, _ := unstructured.NestedString(secret.Object, "stringData", HeartbeatTokenKey)
+		token = []byte(plain)
+	}
+	if len(token) == 0 {
+		return "", fmt.Errorf("secret %s has no %q key", p.Secret, HeartbeatTokenKey)
+	}
+	return string(token), nil
+}
+
+// newSecret builds the token Secret, owned by the Probe when it exists so
+// it is deleted along with it.
+func (p *HeartbeatProber) newSecret(ctx context.Context) *unstructured.Unstructured {
+	raw := make([]byte, 24)
+	_, _ = rand.Read(raw)
+	secret := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":      p.Secret,
+			"namespace": p.Probe.Namespace,
+		},
+		"type":       "Opaque",
+		"stringData": map[string]interface{}{HeartbeatTokenKey: hex.EncodeToString(raw)},
+	}}
+	if ref := probeOwnerRef(ctx, p.Client, p.Probe); ref != nil {
+		secret.SetOwnerReferences([]metav1.OwnerReference{*ref})
+	}
+	return secret
+}
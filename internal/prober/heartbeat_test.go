@@ -0,0 +1,167 @@
+package prober
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"heartbeat-operator/internal/heartbeat"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestEvaluateHeartbeat(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	window := 10 * time.Minute
+
+	tests := []struct {
+		name    string
+		state   heartbeat.State
+		since   time.Time
+		healthy bool
+		message string
+	}{
+		{"waiting for the first ping", heartbeat.State{}, now.Add(-5 * time.Minute), true, "waiting for the first ping"},
+		{"first ping overdue", heartbeat.State{}, now.Add(-time.Hour), false, "no ping received in 10m0s"},
+		{"recent success", heartbeat.State{LastSuccess: now.Add(-3 * time.Minute)}, now.Add(-time.Hour), true, "last success ping 3m0s ago"},
+		{"success overdue", heartbeat.State{LastSuccess: now.Add(-11 * time.Minute)}, now.Add(-time.Hour), false, "expected every 10m0s"},
+		{
+			"failed run",
+			heartbeat.State{LastSuccess: now.Add(-5 * time.Minute), LastFail: now.Add(-time.Minute)},
+			now.Add(-time.Hour), false, "run failed 1m0s ago",
+		},
+		{
+			"run duration",
+			heartbeat.State{LastStart: now.Add(-2 * time.Minute), LastSuccess: now.Add(-time.Minute), RunDuration: time.Minute},
+			now.Add(-time.Hour), true, "(run took 1m0s)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := evaluateHeartbeat(tt.state, window, tt.since, now)
+			if res.Healthy != tt.healthy {
+				t.Errorf("healthy = %v; want %v (%s)", res.Healthy, tt.healthy, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Errorf("message = %q; want it to contain %q", res.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestHeartbeatProberToken(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			secretsResource: "SecretList",
+			probesResource:  "ProbeList",
+		})
+	probe := ProbeInfo{Namespace: "batch", Name: "backup", Interval: time.Hour}
+	p := NewHeartbeatProber(client, probe, "backup-heartbeat", &HeartbeatOptions{})
+
+	if res := p.CheckResult(); !res.Healthy || res.Heartbeat == nil {
+		t.Fatalf("new heartbeat = %+v; want healthy while waiting for the first ping", res)
+	}
+	secret, err := client.Resource(secretsResource).Namespace("batch").Get(context.Background(), "backup-heartbeat", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("token Secret was not created: %v", err)
+	}
+
+	// A prober rebuilt for the same probe reuses the Secret
+	again := NewHeartbeatProber(client, probe, "backup-heartbeat", &HeartbeatOptions{Grace: "5m"})
+	again.CheckResult()
+	if again.token == "" || again.token != p.token {
+		t.Errorf("token = %q; want the one of the existing Secret %q", again.token, p.token)
+	}
+	if again.Grace != 5*time.Minute {
+		t.Errorf("grace = %s; want 5m", again.Grace)
+	}
+	if secret.GetName() != "backup-heartbeat" {
+		t.Errorf("unexpected Secret %s", secret.GetName())
+	}
+}
+
+func TestHeartbeatRegistration(t *testing.T) {
+	if _, err := New("heartbeat", "backup-heartbeat", nil); err == nil {
+		t.Errorf("expected New to refuse a heartbeat check without its probe")
+	}
+	if _, err := NewFor(ProbeInfo{Name: "backup"}, "heartbeat", "backup-heartbeat", nil); err == nil {
+		t.Errorf("expected an error for a heartbeat check without a namespace")
+	}
+	for options, problems := range map[string]int{`{"grace": "5m"}`: 0, `{"grace": "soon"}`: 1, `{"grcae": "5m"}`: 1} {
+		if got := Validate("heartbeat", "backup-heartbeat", json.RawMessage(options)); len(got) != problems {
+			t.Errorf("Validate(%s) = %v; want %d problems", options, got, problems)
+		}
+	}
+	if got := Validate("heartbeat", "Backup_Token", nil); len(got) == 0 {
+		t.Errorf("expected a Secret name to be required as target")
+	}
+}
+
+func TestHeartbeatProberStop(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			secretsResource: "SecretList",
+			probesResource:  "ProbeList",
+		})
+	probe := ProbeInfo{Namespace: "batch", Name: "report", Interval: time.Hour}
+	p := NewHeartbeatProber(client, probe, "report-heartbeat", &HeartbeatOptions{})
+	p.CheckResult()
+
+	handler := heartbeat.Handler()
+	ping := func(token string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ping/"+token, nil))
+		return rec.Code
+	}
+	first := p.token
+	if code := ping(first); code != http.StatusOK {
+		t.Fatalf("ping = %d; want the token registered", code)
+	}
+
+	// A replaced token is picked up and the old one refused
+	secrets := client.Resource(secretsResource).Namespace("batch")
+	secret, _ := secrets.Get(context.Background(), "report-heartbeat", metav1.GetOptions{})
+	_ = unstructured.SetNestedField(secret.Object, map[string]interface{}{HeartbeatTokenKey: "rotated"}, "stringData")
+	if _, err := secrets.Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	p.read = time.Time{}
+	p.CheckResult()
+	if code := ping(first); code != http.StatusNotFound {
+		t.Errorf("ping with the replaced token = %d; want 404", code)
+	}
+	if code := ping("rotated"); code != http.StatusOK {
+		t.Errorf("ping with the new token = %d; want 200", code)
+	}
+
+	p.Stop()
+	if code := ping("rotated"); code != http.StatusNotFound {
+		t.Errorf("ping after Stop = %d; want 404", code)
+	}
+}
+
+func TestHeartbeatSchedule(t *testing.T) {
+	SetKubernetesClient(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()))
+	defer SetKubernetesClient(nil)
+
+	probe := ProbeInfo{Namespace: "batch", Name: "backup", Schedule: "0 3 * * *"}
+	if _, err := NewFor(probe, "heartbeat", "backup-heartbeat", nil); err == nil {
+		t.Errorf("expected a scheduled heartbeat without grace to be refused")
+	}
+	p, err := NewFor(probe, "heartbeat", "backup-heartbeat", json.RawMessage(`{"grace": "25h"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := p.(*HeartbeatProber).CheckResult(); !strings.Contains(res.Message, "expected within 25h0m0s") {
+		t.Errorf("message = %q; want pings expected within the grace", res.Message)
+	}
+	p.(*HeartbeatProber).Stop()
+}
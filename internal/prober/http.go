@@ -1,13 +1,129 @@
 package prober
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
+func init() {
+	Register("http", Type{
+		Decode: decodeHTTPOptions,
+		Validate: func(target string, options interface{}) []string {
+			if problems := validateURL(target); problems != nil {
+				return problems
+			}
+			if fanOutOf(options) != nil {
+				if _, err := NewHttpEndpointProber(nil, target, fanOutOf(options)); err != nil {
+					return []string{err.Error()}
+				}
+			}
+			return nil
+		},
+		New: func(target string, options interface{}) (Prober, error) {
+			return newHttpFor(ProbeInfo{}, target, options)
+		},
+		NewFor: newHttpFor,
+	})
+}
+
+// newHttpFor builds an http check held to the target policy of the
+// namespace of probe.
+func newHttpFor(probe ProbeInfo, target string, options interface{}) (Prober, error) {
+	guard := guardFor(probe.Namespace)
+	if f := fanOutOf(options); f != nil {
+		client := kubernetesClient()
+		if client == nil {
+			return nil, errors.New("probing the endpoints of a Service needs access to the Kubernetes API")
+		}
+		p, err := NewHttpEndpointProber(client, target, f)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.service.checkNamespace(probe); err != nil {
+			return nil, err
+		}
+		p.guard = guard
+		p.Timeout = probe.Timeout
+		p.http, _ = options.(*HTTPOptions)
+		return p, nil
+	}
+	p := &HttpProber{URL: target, Timeout: probe.Timeout, guard: guard}
+	opts, _ := options.(*HTTPOptions)
+	opts.apply(p)
+	return p, nil
+}
+
+// HTTPOptions are the options of http checks.
+type HTTPOptions struct {
+	EndpointOptions
+	// Method defaults to GET.
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// ExpectedStatusCodes defaults to any 2xx code.
+	ExpectedStatusCodes []int `json:"expectedStatusCodes,omitempty"`
+}
+
+func decodeHTTPOptions(raw json.RawMessage) (interface{}, error) {
+	opts := &HTTPOptions{}
+	if err := decodeStrict(raw, opts); err != nil {
+		return nil, err
+	}
+	if err := validateFanOut(opts.Endpoints); err != nil {
+		return nil, err
+	}
+	switch opts.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		return nil, fmt.Errorf("method %q must be an HTTP method such as GET or HEAD", opts.Method)
+	}
+	for _, code := range opts.ExpectedStatusCodes {
+		if code < 100 || code > 599 {
+			return nil, fmt.Errorf("expectedStatusCodes: %d must be between 100 and 599", code)
+		}
+	}
+	return opts, nil
+}
+
+// apply sets the request options on p. o may be nil.
+func (o *HTTPOptions) apply(p *HttpProber) {
+	if o == nil {
+		return
+	}
+	p.Method = o.Method
+	p.Headers = o.Headers
+	p.ExpectedStatusCodes = o.ExpectedStatusCodes
+}
+
 type HttpProber struct {
 	URL string
+	// Method defaults to GET. Headers are sent with every request, a Host
+	// header replaces the host of URL.
+	Method  string
+	Headers map[string]string
+	// ExpectedStatusCodes defaults to any 2xx code.
+	ExpectedStatusCodes []int
+	// Timeout bounds the request and its redirects, DefaultTimeout when
+	// zero.
+	Timeout time.Duration
+
+	// guard enforces the target policy, nil without one.
+	guard *targetGuard
+	// dialAddress is connected to instead of the host of URL, which still
+	// names the server for TLS and the Host header. Empty to dial the host.
+	dialAddress string
 }
 
 func NewHttpProber(url string) *HttpProber {
@@ -15,12 +131,224 @@ func NewHttpProber(url string) *HttpProber {
 }
 
 func (p *HttpProber) Check() bool {
-	client := http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Get(p.URL)
+	return p.CheckResult().Healthy
+}
+
+func (p *HttpProber) CheckResult() Result {
+	return p.CheckContext(context.Background())
+}
+
+func (p *HttpProber) CheckContext(ctx context.Context) Result {
+	// Without keep-alives every check pays for its connection, the phases
+	// stay comparable between checks
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableKeepAlives = true
+	timeout := checkTimeout(p.Timeout)
+	client := http.Client{Timeout: timeout, Transport: transport}
+	if g := p.guard; g != nil {
+		u, err := url.Parse(p.URL)
+		if err != nil {
+			return Result{Message: err.Error()}
+		}
+		// With a proxy the dialer only sees the proxy, so the host of every
+		// hop is checked as well.
+		address := urlAddress(u)
+		if p.dialAddress != "" {
+			address = p.dialAddress
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		err = g.check(ctx, address)
+		cancel()
+		if res, denied := policyDenied(err); denied {
+			log.Printf("[HTTP] %s: %v", p.URL, err)
+			return res
+		}
+		transport.DialContext = g.dialContext
+	}
+	if p.dialAddress != "" {
+		u, err := url.Parse(p.URL)
+		if err != nil {
+			return Result{Message: err.Error()}
+		}
+		// Redirects to other hosts dial those, and a proxy would connect
+		// to the host instead of the address
+		host, dial := urlAddress(u), transport.DialContext
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == host {
+				addr = p.dialAddress
+			}
+			return dial(ctx, network, addr)
+		}
+	}
+
+	trace := newHTTPTrace()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		trace.result.Redirects = len(via)
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if p.guard != nil {
+			return p.guard.check(req.Context(), urlAddress(req.URL))
+		}
+		return nil
+	}
+	method := p.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace.clientTrace()), method, p.URL, nil)
+	if err != nil {
+		return Result{Message: err.Error()}
+	}
+	for k, v := range p.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
+		if res, denied := policyDenied(err); denied {
+			log.Printf("[HTTP] %s: %v", p.URL, err)
+			return res
+		}
 		log.Printf("[HTTP] Check failed for %s: %v", p.URL, err)
-		return false
+		return Result{Message: err.Error(), HTTP: trace.done()}
 	}
 	defer resp.Body.Close()
-	return resp.StatusCode >= 200 && resp.StatusCode < 300
+
+	// The body is read to time its transfer, a failure to read it does not
+	// change the outcome
+	n, _ := io.Copy(io.Discard, resp.Body)
+	res := trace.done()
+	res.StatusCode = resp.StatusCode
+	res.Version = float64(resp.ProtoMajor) + float64(resp.ProtoMinor)/10
+	res.ContentLength = resp.ContentLength
+	if res.ContentLength < 0 {
+		res.ContentLength = n
+	}
+	if !p.expected(resp.StatusCode) {
+		return Result{Message: fmt.Sprintf("unexpected status code %d", resp.StatusCode), HTTP: res}
+	}
+	return Result{Healthy: true, HTTP: res}
+}
+
+// expected reports whether code is one of ExpectedStatusCodes, or a 2xx
+// code without them.
+func (p *HttpProber) expected(code int) bool {
+	if len(p.ExpectedStatusCodes) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, c := range p.ExpectedStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// httpTrace times the phases of a request and its redirects.
+type httpTrace struct {
+	// Dials to several addresses of a host may run in parallel
+	mu     sync.Mutex
+	result *HTTPResult
+
+	dnsStart, connectStart, tlsStart, gotConn, firstByte time.Time
+}
+
+func newHTTPTrace() *httpTrace {
+	phases := make(map[string]time.Duration, len(HTTPPhases))
+	for _, phase := range HTTPPhases {
+		phases[phase] = 0
+	}
+	return &httpTrace{result: &HTTPResult{Phases: phases}}
+}
+
+func (t *httpTrace) add(phase string, since time.Time) {
+	if since.IsZero() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.result.Phases[phase] += time.Since(since)
+}
+
+func (t *httpTrace) clientTrace() *httptrace.ClientTrace {
+	now := func(at *time.Time) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		*at = time.Now()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { now(&t.dnsStart) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { t.add("resolve", t.started(&t.dnsStart)) },
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			// The first of parallel dials starts the phase
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.add("connect", t.started(&t.connectStart))
+			}
+		},
+		TLSHandshakeStart: func() { now(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.add("tls", t.started(&t.tlsStart)) },
+		GotConn:           func(httptrace.GotConnInfo) { now(&t.gotConn) },
+		GotFirstResponseByte: func() {
+			now(&t.firstByte)
+			t.add("processing", t.started(&t.gotConn))
+		},
+	}
+}
+
+// started returns the time at and resets it, for the next hop.
+func (t *httpTrace) started(at *time.Time) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	start := *at
+	*at = time.Time{}
+	return start
+}
+
+// done ends the transfer of the last response and returns the result.
+func (t *httpTrace) done() *HTTPResult {
+	t.add("transfer", t.started(&t.firstByte))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.result
+}
+
+// urlAddress returns the host:port u connects to.
+func urlAddress(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+func validateURL(s string) []string {
+	if s == "" {
+		return []string{"must be an http or https URL"}
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return []string{"scheme must be http or https"}
+	}
+	if u.Hostname() == "" {
+		return []string{"must include a host"}
+	}
+	return nil
 }
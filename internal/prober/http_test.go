@@ -1,9 +1,12 @@
 package prober
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestHttpProber_Check(t *testing.T) {
@@ -45,3 +48,98 @@ func TestHttpProber_Check(t *testing.T) {
 		})
 	}
 }
+
+func TestHttpProber_CheckResultTimings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/health", http.StatusFound)
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	res := NewHttpProber(server.URL + "/old").CheckResult()
+	if !res.Healthy || res.HTTP == nil {
+		t.Fatalf("result = %+v; want healthy with http details", res)
+	}
+	h := res.HTTP
+	if h.StatusCode != http.StatusOK || h.Version != 1.1 || h.Redirects != 1 || h.ContentLength != 2 {
+		t.Errorf("details = %+v; want 200, HTTP/1.1, 1 redirect, 2 bytes", h)
+	}
+	for _, phase := range HTTPPhases {
+		if _, ok := h.Phases[phase]; !ok {
+			t.Errorf("phase %s missing", phase)
+		}
+	}
+	if h.Phases["processing"] < 20*time.Millisecond || h.Phases["connect"] <= 0 || h.Phases["tls"] != 0 {
+		t.Errorf("phases = %v; want processing of at least 20ms, a connect and no tls", h.Phases)
+	}
+
+	// Without a response the phases reached are still reported
+	server.Close()
+	res = NewHttpProber(server.URL).CheckResult()
+	if res.Healthy || res.HTTP == nil || res.HTTP.StatusCode != 0 {
+		t.Errorf("result = %+v; want unhealthy without a status code", res)
+	}
+}
+
+func TestHttpProberOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.Header.Get("X-Probe") != "heartbeat" || r.Host != "shop.example.com" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		options string
+		healthy bool
+	}{
+		{`{"method": "HEAD", "headers": {"X-Probe": "heartbeat", "Host": "shop.example.com"}, "expectedStatusCodes": [401]}`, true},
+		{`{"method": "HEAD", "headers": {"X-Probe": "heartbeat", "Host": "shop.example.com"}}`, false},
+		{`{"expectedStatusCodes": [401]}`, false},
+	}
+	for _, tt := range tests {
+		p, err := New("http", server.URL, json.RawMessage(tt.options))
+		if err != nil {
+			t.Fatalf("New(%s): %v", tt.options, err)
+		}
+		if res := Run(p); res.Healthy != tt.healthy {
+			t.Errorf("options %s: healthy = %v (%s); want %v", tt.options, res.Healthy, res.Message, tt.healthy)
+		}
+	}
+
+	for _, options := range []string{`{"method": "BREW"}`, `{"expectedStatusCodes": [99]}`, `{"verb": "GET"}`} {
+		if problems := Validate("http", server.URL, json.RawMessage(options)); len(problems) != 1 {
+			t.Errorf("Validate(%s) = %v; want one problem", options, problems)
+		}
+	}
+}
+
+func TestHttpProberTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		timeout time.Duration
+		healthy bool
+	}{{50 * time.Millisecond, false}, {time.Second, true}} {
+		p, err := NewFor(ProbeInfo{Namespace: "shop", Timeout: tt.timeout}, "http", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := Run(p)
+		if res.Healthy != tt.healthy {
+			t.Errorf("timeout %s: healthy = %v (%s); want %v", tt.timeout, res.Healthy, res.Message, tt.healthy)
+		}
+		if !res.Healthy && !strings.Contains(res.Message, "Client.Timeout exceeded") {
+			t.Errorf("timeout %s: message = %q; want the error of the request", tt.timeout, res.Message)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// defaultJobTimeout is how long a Job may take when the probe sets no
+	// timeout, scheduling and pulling the image included. It is capped at
+	// the interval.
+	defaultJobTimeout = time.Minute
+	// jobTTL removes finished Jobs the operator could not delete itself.
+	jobTTL = 10 * time.Minute
+	// LabelCheckJob is set on the Jobs of exec checks to the probe name.
+	LabelCheckJob = "probes.ready.io/check-job"
+)
+
+var (
+	// jobPollInterval is a variable so tests need not wait a second.
+	jobPollInterval = time.Second
+
+	clusterProbesResource = schema.GroupVersionResource{Group: "probes.ready.io", Version: "v1alpha1", Resource: "clusterprobes"}
+)
+
+// JobSpec describes the Job an exec check runs its command in.
+type JobSpec struct {
+	// Image holding the tools of the command, e.g. postgres:16 for psql.
+	Image string `json:"image"`
+	// Namespace of the Job, the one of the Probe by default. A Probe can
+	// only run Jobs in its own namespace.
+	Namespace string `json:"namespace,omitempty"`
+	// ServiceAccountName the Job runs as, the default one of the namespace
+	// when empty.
+	ServiceAccountName string                       `json:"serviceAccountName,omitempty"`
+	Resources          *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+func (s *JobSpec) validate() []string {
+	var problems []string
+	if s.Image == "" {
+		problems = append(problems, "job.image: must be set")
+	} else if strings.ContainsAny(s.Image, " \t\n") {
+		problems = append(problems, "job.image: must not contain whitespace")
+	}
+	if s.Namespace != "" {
+		for _, msg := range validation.IsDNS1123Label(s.Namespace) {
+			problems = append(problems, "job.namespace: "+msg)
+		}
+	}
+	if s.ServiceAccountName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(s.ServiceAccountName) {
+			problems = append(problems, "job.serviceAccountName: "+msg)
+		}
+	}
+	return problems
+}
+
+// jobSlots caps the Jobs of exec checks that run at the same time.
+var (
+	jobSlotsMu sync.RWMutex
+	jobSlots   = make(chan struct{}, 5)
+)
+
+// SetMaxExecJobs sets how many Jobs of exec checks may run at the same
+// time, 5 by default. Checks beyond it wait for a slot within their timeout.
+func SetMaxExecJobs(n int) {
+	if n < 1 {
+		n = 1
+	}
+	jobSlotsMu.Lock()
+	defer jobSlotsMu.Unlock()
+	jobSlots = make(chan struct{}, n)
+}
+
+func execJobSlots() chan struct{} {
+	jobSlotsMu.RLock()
+	defer jobSlotsMu.RUnlock()
+	return jobSlots
+}
+
+func newJobExecProberFor(probe ProbeInfo, target string, job *JobSpec) (Prober, error) {
+	client, logs := kubernetesClient(), podLogReader()
+	if client == nil || logs == nil {
+		return nil, errors.New("exec checks in a Job need access to the Kubernetes API")
+	}
+	spec := *job
+	if spec.Namespace == "" {
+		spec.Namespace = probe.Namespace
+	}
+	switch {
+	case spec.Namespace == "":
+		return nil, errors.New("exec checks of a ClusterProbe must set job.namespace")
+	case probe.Namespace != "" && spec.Namespace != probe.Namespace:
+		return nil, fmt.Errorf("a Probe in %s cannot run Jobs in %s", probe.Namespace, spec.Namespace)
+	}
+	timeout := probe.Timeout
+	if timeout == 0 {
+		timeout = defaultJobTimeout
+		if probe.Interval > 0 && probe.Interval < timeout {
+			timeout = probe.Interval
+		}
+	}
+	return &JobExecProber{
+		Client:  client,
+		Probe:   probe,
+		Job:     spec,
+		Command: strings.Fields(target),
+		Timeout: timeout,
+		logs:    logs,
+	}, nil
+}
+
+// JobExecProber runs its command in a short-lived Job, so the tools it needs
+// come from an image of its own and it runs with a service account of its
+// own. The Job is deleted once its result is in.
+type JobExecProber struct {
+	Client  dynamic.Interface
+	Probe   ProbeInfo
+	Job     JobSpec
+	Command []string
+	// Timeout covers waiting for a slot, scheduling and running the Job.
+	Timeout time.Duration
+
+	logs podLogFunc
+}
+
+func (p *JobExecProber) Check() bool {
+	return p.CheckResult().Healthy
+}
+
+func (p *JobExecProber) CheckResult() Result {
+	return p.CheckContext(context.Background())
+}
+
+func (p *JobExecProber) CheckContext(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	slots := execJobSlots()
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-ctx.Done():
+		return Result{Message: fmt.Sprintf("no free slot to run the Job within %s, limit %d", p.Timeout, cap(slots))}
+	}
+
+	jobs := p.Client.Resource(jobsResource).Namespace(p.Job.Namespace)
+	job, err := jobs.Create(ctx, p.newJob(ctx), metav1.CreateOptions{})
+	if err != nil {
+		log.Printf("[Exec] Failed to create Job for %s: %v", p.Probe.Name, err)
+		return Result{Message: fmt.Sprintf("create Job: %v", err)}
+	}
+	name := job.GetName()
+	defer func() {
+		// The check context may be done already
+		delCtx, delCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer delCancel()
+		background := metav1.DeletePropagationBackground
+		if err := jobs.Delete(delCtx, name, metav1.DeleteOptions{PropagationPolicy: &background}); err != nil {
+			log.Printf("[Exec] Failed to delete Job %s/%s: %v", p.Job.Namespace, name, err)
+		}
+	}()
+
+	for {
+		if res, done := p.result(ctx, name); done {
+			return res
+		}
+		select {
+		case <-ctx.Done():
+			return Result{Message: fmt.Sprintf("Job %s: no result within %s", name, p.Timeout)}
+		case <-time.After(jobPollInterval):
+		}
+	}
+}
+
+// result reports the outcome of the Job once its pod terminated.
+func (p *JobExecProber) result(ctx context.Context, name string) (Result, bool) {
+	pods, err := p.Client.Resource(podsResource).Namespace(p.Job.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: batchv1.JobNameLabel + "=" + name,
+	})
+	if err != nil {
+		return Result{}, false
+	}
+	for _, item := range pods.Items {
+		pod := &corev1.Pod{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, pod); err != nil {
+			continue
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.Name != "check" || cs.State.Terminated == nil {
+				continue
+			}
+			output, err := p.logs(ctx, pod.Namespace, pod.Name, cs.Name)
+			if err != nil {
+				log.Printf("[Exec] Failed to read the logs of %s/%s: %v", pod.Namespace, pod.Name, err)
+			}
+			code := int(cs.State.Terminated.ExitCode)
+			return Result{Healthy: code == 0, Message: fmt.Sprintf("Job %s: %s", name, execMessage("Job "+pod.Namespace+"/"+name, code, output))}, true
+		}
+		for _, cs := range pod.Status.ContainerStatuses {
+			if w := cs.State.Waiting; w != nil && (w.Reason == "ErrImagePull" || w.Reason == "ImagePullBackOff" || w.Reason == "InvalidImageName") {
+				return Result{Message: fmt.Sprintf("Job %s: %s: %s", name, w.Reason, w.Message)}, true
+			}
+		}
+	}
+	return Result{}, false
+}
+
+// newJob builds the Job of one check. It is owned by the Probe when that
+// exists, so it goes away with it.
+func (p *JobExecProber) newJob(ctx context.Context) *unstructured.Unstructured {
+	prefix := p.Probe.Name
+	if len(prefix) > 50 {
+		prefix = strings.TrimRight(prefix[:50], "-.")
+	}
+	container := corev1.Container{
+		Name:    "check",
+		Image:   p.Job.Image,
+		Command: p.Command,
+	}
+	if p.Job.Resources != nil {
+		container.Resources = *p.Job.Resources
+	}
+	backoffLimit := int32(0)
+	// The API server refuses a deadline of 0, a timeout under a second
+	// gets one
+	deadline := max(int64(math.Ceil(p.Timeout.Seconds())), 1)
+	ttl := int32(jobTTL.Seconds())
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      prefix + "-check-" + utilrand.String(5),
+			Namespace: p.Job.Namespace,
+			Labels:    map[string]string{LabelCheckJob: p.Probe.Name},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &deadline,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{LabelCheckJob: p.Probe.Name}},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: p.Job.ServiceAccountName,
+					Containers:         []corev1.Container{container},
+				},
+			},
+		},
+	}
+	if ref := probeOwnerRef(ctx, p.Client, p.Probe); ref != nil {
+		job.OwnerReferences = []metav1.OwnerReference{*ref}
+	}
+	obj, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(job)
+	return &unstructured.Unstructured{Object: obj}
+}
+
+// probeOwnerRef returns a reference to the Probe or ClusterProbe of probe,
+// nil when it cannot be read, e.g. for a rule from values.yaml.
+func probeOwnerRef(ctx context.Context, client dynamic.Interface, probe ProbeInfo) *metav1.OwnerReference {
+	var obj *unstructured.Unstructured
+	var err error
+	if probe.Namespace == "" {
+		obj, err = client.Resource(clusterProbesResource).Get(ctx, probe.Name, metav1.GetOptions{})
+	} else {
+		obj, err = client.Resource(probesResource).Namespace(probe.Namespace).Get(ctx, probe.Name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return nil
+	}
+	return &metav1.OwnerReference{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Name:       obj.GetName(),
+		UID:        obj.GetUID(),
+	}
+}
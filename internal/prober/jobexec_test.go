@@ -0,0 +1,187 @@
+package prober
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// runJobs plays the Job controller and kubelet: the pod of every Job of an
+// exec check terminates with exitCode.
+func runJobs(ctx context.Context, client dynamic.Interface, exitCode int64) {
+	seen := map[string]bool{}
+	for ctx.Err() == nil {
+		jobs, _ := client.Resource(jobsResource).Namespace("shop").List(ctx, metav1.ListOptions{})
+		for _, job := range jobs.Items {
+			if seen[job.GetName()] {
+				continue
+			}
+			seen[job.GetName()] = true
+			pod := object("v1", "Pod", "shop", job.GetName()+"-abcde", map[string]interface{}{batchv1.JobNameLabel: job.GetName()}, map[string]interface{}{
+				"containerStatuses": []interface{}{map[string]interface{}{
+					"name":  "check",
+					"state": map[string]interface{}{"terminated": map[string]interface{}{"exitCode": exitCode}},
+				}},
+			})
+			_, _ = client.Resource(podsResource).Namespace("shop").Create(ctx, pod, metav1.CreateOptions{})
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newJobTestClient() dynamic.Interface {
+	return dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			jobsResource:          "JobList",
+			podsResource:          "PodList",
+			probesResource:        "ProbeList",
+			clusterProbesResource: "ClusterProbeList",
+		},
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "probes.ready.io/v1alpha1",
+			"kind":       "Probe",
+			"metadata":   map[string]interface{}{"namespace": "shop", "name": "db", "uid": "probe-uid"},
+		}},
+	)
+}
+
+func TestJobExecProber(t *testing.T) {
+	defer func(d time.Duration) { jobPollInterval = d }(jobPollInterval)
+	jobPollInterval = 5 * time.Millisecond
+
+	for _, tt := range []struct {
+		exitCode int64
+		healthy  bool
+		message  string
+	}{
+		{0, true, "exit code 0"},
+		{2, false, "exit code 2"},
+	} {
+		client := newJobTestClient()
+		ctx, cancel := context.WithCancel(context.Background())
+		go runJobs(ctx, client, tt.exitCode)
+
+		p := &JobExecProber{
+			Client:  client,
+			Probe:   ProbeInfo{Namespace: "shop", Name: "db"},
+			Job:     JobSpec{Image: "postgres:16", Namespace: "shop", ServiceAccountName: "db-check"},
+			Command: []string{"pg_isready", "-h", "db"},
+			Timeout: 5 * time.Second,
+			logs: func(_ context.Context, namespace, pod, container string) ([]byte, error) {
+				return []byte("connected\n"), nil
+			},
+		}
+		res := p.CheckResult()
+		cancel()
+		if res.Healthy != tt.healthy || !strings.HasSuffix(res.Message, tt.message) {
+			t.Errorf("exit code %d: result = %+v; want healthy %v with %q and no output", tt.exitCode, res, tt.healthy, tt.message)
+		}
+
+		// The Job is gone once the result is in
+		jobs, _ := client.Resource(jobsResource).Namespace("shop").List(context.Background(), metav1.ListOptions{})
+		if len(jobs.Items) != 0 {
+			t.Errorf("%d Jobs left after the check", len(jobs.Items))
+		}
+	}
+}
+
+func TestJobExecProberJob(t *testing.T) {
+	p := &JobExecProber{
+		Client:  newJobTestClient(),
+		Probe:   ProbeInfo{Namespace: "shop", Name: "db"},
+		Job:     JobSpec{Image: "postgres:16", Namespace: "shop", ServiceAccountName: "db-check"},
+		Command: []string{"pg_isready"},
+		Timeout: 30 * time.Second,
+	}
+	obj := p.newJob(context.Background())
+	job := &batchv1.Job{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, job); err != nil {
+		t.Fatal(err)
+	}
+	spec := job.Spec.Template.Spec
+	if spec.ServiceAccountName != "db-check" || spec.Containers[0].Image != "postgres:16" || spec.RestartPolicy != "Never" {
+		t.Errorf("pod spec = %+v", spec)
+	}
+	if *job.Spec.BackoffLimit != 0 || *job.Spec.ActiveDeadlineSeconds != 30 {
+		t.Errorf("job spec = %+v; want no retries and the timeout as deadline", job.Spec)
+	}
+	if refs := job.OwnerReferences; len(refs) != 1 || refs[0].UID != "probe-uid" {
+		t.Errorf("owner references = %+v; want the Probe", refs)
+	}
+	if !strings.HasPrefix(job.Name, "db-check-") || job.Labels[LabelCheckJob] != "db" {
+		t.Errorf("job %s with labels %v", job.Name, job.Labels)
+	}
+}
+
+func TestJobExecDeadline(t *testing.T) {
+	for timeout, want := range map[time.Duration]int64{500 * time.Millisecond: 1, 1500 * time.Millisecond: 2, time.Minute: 60} {
+		p := &JobExecProber{
+			Client:  newJobTestClient(),
+			Probe:   ProbeInfo{Namespace: "shop", Name: "db"},
+			Job:     JobSpec{Image: "postgres:16", Namespace: "shop"},
+			Command: []string{"pg_isready"},
+			Timeout: timeout,
+		}
+		job := &batchv1.Job{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(p.newJob(context.Background()).Object, job); err != nil {
+			t.Fatal(err)
+		}
+		if got := *job.Spec.ActiveDeadlineSeconds; got != want {
+			t.Errorf("timeout %s: activeDeadlineSeconds = %d; want %d", timeout, got, want)
+		}
+	}
+}
+
+func TestJobExecSlots(t *testing.T) {
+	defer SetMaxExecJobs(5)
+	SetMaxExecJobs(1)
+	slots := execJobSlots()
+	slots <- struct{}{}
+	defer func() { <-slots }()
+
+	p := &JobExecProber{Client: newJobTestClient(), Probe: ProbeInfo{Namespace: "shop", Name: "db"}, Job: JobSpec{Image: "busybox", Namespace: "shop"}, Timeout: 20 * time.Millisecond}
+	if res := p.CheckResult(); res.Healthy || !strings.Contains(res.Message, "no free slot") {
+		t.Errorf("result = %+v; want to wait for a free slot", res)
+	}
+}
+
+func TestJobExecRegistration(t *testing.T) {
+	for options, problems := range map[string]int{
+		`{"job": {"image": "postgres:16", "serviceAccountName": "db-check", "resources": {"limits": {"memory": "64Mi"}}}}`: 0,
+		`{"job": {}}`: 1,
+		`{"job": {"image": "busybox", "serviceAccountName": "Admin"}}`:               1,
+		`{"job": {"image": "busybox"}, "pod": {"name": "db-0"}}`:                     1,
+		`{"job": {"image": "busybox", "resources": {"limits": {"memory": "lots"}}}}`: 1,
+	} {
+		if got := Validate("exec", "pg_isready", json.RawMessage(options)); len(got) != problems {
+			t.Errorf("Validate(%s) = %v; want %d problems", options, got, problems)
+		}
+	}
+
+	SetKubernetesClient(newJobTestClient())
+	defer SetKubernetesClient(nil)
+	setPodLogs(func(context.Context, string, string, string) ([]byte, error) { return nil, nil })
+	defer setPodLogs(nil)
+
+	options := json.RawMessage(`{"job": {"image": "busybox", "namespace": "other"}}`)
+	if _, err := NewFor(ProbeInfo{Namespace: "shop", Name: "db"}, "exec", "true", options); err == nil {
+		t.Errorf("expected a Probe to be refused Jobs in another namespace")
+	}
+	p, err := NewFor(ProbeInfo{Namespace: "shop", Name: "db", Timeout: 30 * time.Second}, "exec", "true", json.RawMessage(`{"job": {"image": "busybox"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jp := p.(*JobExecProber); jp.Job.Namespace != "shop" || jp.Timeout != 30*time.Second {
+		t.Errorf("prober = %+v; want the namespace and timeout of the probe", jp)
+	}
+}
@@ -0,0 +1,354 @@
+package prober
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+func init() {
+	Register("kubernetes", Type{
+		Decode: decodeKubernetesOptions,
+		Validate: func(target string, options interface{}) []string {
+			if _, err := parseObjectRef(target, options.(*KubernetesOptions).LabelSelector != ""); err != nil {
+				return []string{err.Error()}
+			}
+			return nil
+		},
+		NewFor: func(probe ProbeInfo, target string, options interface{}) (Prober, error) {
+			client := kubernetesClient()
+			if client == nil {
+				return nil, errors.New("kubernetes checks need access to the Kubernetes API")
+			}
+			p, err := NewKubernetesProber(client, target, options.(*KubernetesOptions))
+			if err != nil {
+				return nil, err
+			}
+			// A Probe only reads objects in its own namespace, other
+			// namespaces and cluster-scoped objects need a ClusterProbe
+			if probe.Namespace != "" {
+				if p.ref.namespace == "" {
+					p.ref.namespace = probe.Namespace
+				} else if p.ref.namespace != probe.Namespace {
+					return nil, fmt.Errorf("kubernetes checks of a Probe can only read objects in namespace %s", probe.Namespace)
+				}
+				p.ownNamespace = true
+			}
+			p.Timeout = probe.Timeout
+			return p, nil
+		},
+	})
+}
+
+var (
+	dynamicMu     sync.RWMutex
+	dynamicClient dynamic.Interface
+)
+
+// SetKubernetesClient sets the client kubernetes checks read objects with,
+// and checks resolve the endpoints of a Service with.
+func SetKubernetesClient(client dynamic.Interface) {
+	dynamicMu.Lock()
+	defer dynamicMu.Unlock()
+	dynamicClient = client
+}
+
+func kubernetesClient() dynamic.Interface {
+	dynamicMu.RLock()
+	defer dynamicMu.RUnlock()
+	return dynamicClient
+}
+
+// KubernetesOptions select the objects of a kubernetes check and what they
+// must satisfy. Without Condition and Fields an object only has to exist.
+type KubernetesOptions struct {
+	// LabelSelector checks every matching object instead of a named one.
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// Condition is the type of a status condition, e.g. Available or Ready.
+	Condition string `json:"condition,omitempty"`
+	// Status the condition must have, "True" by default.
+	Status string `json:"status,omitempty"`
+	// Fields are comparisons such as "status.readyReplicas >= 2" or
+	// "status.phase == Bound".
+	Fields []string `json:"fields,omitempty"`
+}
+
+func decodeKubernetesOptions(raw json.RawMessage) (interface{}, error) {
+	opts := &KubernetesOptions{}
+	if err := decodeStrict(raw, opts); err != nil {
+		return nil, err
+	}
+	if opts.LabelSelector != "" {
+		if _, err := labels.Parse(opts.LabelSelector); err != nil {
+			return nil, fmt.Errorf("labelSelector: %v", err)
+		}
+	}
+	for _, expr := range opts.Fields {
+		if _, err := parseFieldCheck(expr); err != nil {
+			return nil, err
+		}
+	}
+	return opts, nil
+}
+
+// objectRef names the objects of a kubernetes check. The target is
+// "<resource>.<version>[.<group>]/[<namespace>/]<name>", e.g.
+// "deployments.v1.apps/payments/api" or "persistentvolumes.v1/pv-1". With a
+// label selector the name is left out: "deployments.v1.apps/payments".
+type objectRef struct {
+	resource  schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+func parseObjectRef(target string, selector bool) (objectRef, error) {
+	parts := strings.Split(target, "/")
+	res := strings.SplitN(parts[0], ".", 3)
+	if len(res) < 2 || res[0] == "" || res[1] == "" {
+		return objectRef{}, fmt.Errorf("must start with <resource>.<version>[.<group>], e.g. deployments.v1.apps")
+	}
+	ref := objectRef{resource: schema.GroupVersionResource{Resource: res[0], Version: res[1]}}
+	if len(res) == 3 {
+		ref.resource.Group = res[2]
+	}
+
+	rest := parts[1:]
+	if selector {
+		switch len(rest) {
+		case 0:
+		case 1:
+			ref.namespace = rest[0]
+		default:
+			return objectRef{}, fmt.Errorf("must be <resource>[/<namespace>] with a labelSelector")
+		}
+		return ref, nil
+	}
+	switch len(rest) {
+	case 1:
+		ref.name = rest[0]
+	case 2:
+		ref.namespace, ref.name = rest[0], rest[1]
+	default:
+		return objectRef{}, fmt.Errorf("must be <resource>/[<namespace>/]<name>")
+	}
+	if ref.name == "" {
+		return objectRef{}, fmt.Errorf("must include an object name")
+	}
+	return ref, nil
+}
+
+// KubernetesProber reads objects through the dynamic client and evaluates
+// a status condition and field comparisons on them.
+type KubernetesProber struct {
+	Client  dynamic.Interface
+	Target  string
+	Options *KubernetesOptions
+	Timeout time.Duration
+
+	ref    objectRef
+	fields []fieldCheck
+	// ownNamespace is set when the objects are in the namespace of the
+	// Probe, their owner may read them and the message tells why a
+	// condition fails.
+	ownNamespace bool
+}
+
+func NewKubernetesProber(client dynamic.Interface, target string, opts *KubernetesOptions) (*KubernetesProber, error) {
+	ref, err := parseObjectRef(target, opts.LabelSelector != "")
+	if err != nil {
+		return nil, err
+	}
+	p := &KubernetesProber{Client: client, Target: target, Options: opts, ref: ref}
+	for _, expr := range opts.Fields {
+		fc, err := parseFieldCheck(expr)
+		if err != nil {
+			return nil, err
+		}
+		p.fields = append(p.fields, fc)
+	}
+	return p, nil
+}
+
+func (p *KubernetesProber) Check() bool {
+	return p.CheckResult().Healthy
+}
+
+func (p *KubernetesProber) CheckResult() Result {
+	return p.CheckContext(context.Background())
+}
+
+func (p *KubernetesProber) CheckContext(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout(p.Timeout))
+	defer cancel()
+
+	var client dynamic.ResourceInterface = p.Client.Resource(p.ref.resource)
+	if p.ref.namespace != "" {
+		client = p.Client.Resource(p.ref.resource).Namespace(p.ref.namespace)
+	}
+
+	if p.Options.LabelSelector == "" {
+		obj, err := client.Get(ctx, p.ref.name, metav1.GetOptions{})
+		if err != nil {
+			log.Printf("[Kubernetes] Get of %s failed: %v", p.Target, err)
+			return Result{Message: err.Error()}
+		}
+		return p.evaluate(obj)
+	}
+
+	list, err := client.List(ctx, metav1.ListOptions{LabelSelector: p.Options.LabelSelector})
+	if err != nil {
+		log.Printf("[Kubernetes] List of %s failed: %v", p.Target, err)
+		return Result{Message: err.Error()}
+	}
+	if len(list.Items) == 0 {
+		return Result{Message: fmt.Sprintf("no %s match %s", p.ref.resource.Resource, p.Options.LabelSelector)}
+	}
+	for i := range list.Items {
+		if res := p.evaluate(&list.Items[i]); !res.Healthy {
+			res.Message = list.Items[i].GetName() + ": " + res.Message
+			return res
+		}
+	}
+	return Result{Healthy: true, Message: fmt.Sprintf("%d %s healthy", len(list.Items), p.ref.resource.Resource)}
+}
+
+// evaluate checks the condition and fields of one object. The message
+// names what does not hold but no value read from the object, the operator
+// may read objects the owner of the Probe cannot, except for the reason and
+// message of a failing condition in the namespace of the Probe.
+func (p *KubernetesProber) evaluate(obj *unstructured.Unstructured) Result {
+	if p.Options.Condition != "" {
+		want := p.Options.Status
+		if want == "" {
+			want = string(metav1.ConditionTrue)
+		}
+		cond, found := findCondition(obj, p.Options.Condition)
+		if !found {
+			return Result{Message: fmt.Sprintf("condition %s not reported", p.Options.Condition)}
+		}
+		if cond["status"] != want {
+			log.Printf("[Kubernetes] %s %s: %s=%s: %s", obj.GetKind(), obj.GetName(), p.Options.Condition, cond["status"], cond["message"])
+			msg := fmt.Sprintf("condition %s is not %s", p.Options.Condition, want)
+			if p.ownNamespace {
+				for _, k := range []string{"reason", "message"} {
+					if cond[k] != "" {
+						msg += ": " + cond[k]
+					}
+				}
+			}
+			return Result{Message: msg}
+		}
+	}
+
+	for _, fc := range p.fields {
+		ok, actual := fc.evaluate(obj)
+		if !ok {
+			log.Printf("[Kubernetes] %s %s: %s is %s, want %s %s", obj.GetKind(), obj.GetName(), fc.path, actual, fc.op, fc.value)
+			return Result{Message: fmt.Sprintf("%s: want %s %s", fc.path, fc.op, fc.value)}
+		}
+	}
+
+	return Result{Healthy: true, Message: fmt.Sprintf("%s %s is healthy", obj.GetKind(), obj.GetName())}
+}
+
+func findCondition(obj *unstructured.Unstructured, condType string) (map[string]string, bool) {
+	conds, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conds {
+		m, ok := c.(map[string]interface{})
+		if !ok || m["type"] != condType {
+			continue
+		}
+		out := map[string]string{}
+		for _, k := range []string{"status", "reason", "message"} {
+			if s, ok := m[k].(string); ok {
+				out[k] = s
+			}
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// fieldCheck compares the value at a dotted path with a constant.
+type fieldCheck struct {
+	path  string
+	op    string
+	value string
+}
+
+var fieldOperators = []string{">=", "<=", "==", "!=", ">", "<"}
+
+func parseFieldCheck(expr string) (fieldCheck, error) {
+	for _, op := range fieldOperators {
+		if path, value, ok := strings.Cut(expr, op); ok {
+			fc := fieldCheck{
+				path:  strings.TrimPrefix(strings.TrimSpace(path), "."),
+				op:    op,
+				value: strings.TrimSpace(value),
+			}
+			if fc.path == "" || fc.value == "" {
+				break
+			}
+			return fc, nil
+		}
+	}
+	return fieldCheck{}, fmt.Errorf("field %q must be <path> <op> <value> with op one of %s", expr, strings.Join(fieldOperators, " "))
+}
+
+// evaluate returns whether the comparison holds and the actual value. Values
+// that parse as numbers are compared numerically, others as strings.
+func (fc fieldCheck) evaluate(obj *unstructured.Unstructured) (bool, string) {
+	v, found, err := unstructured.NestedFieldNoCopy(obj.Object, strings.Split(fc.path, ".")...)
+	if err != nil || !found {
+		// An unset counter such as readyReplicas means zero
+		v = nil
+	}
+	actual := ""
+	if v != nil {
+		actual = fmt.Sprint(v)
+	}
+
+	a, aErr := strconv.ParseFloat(actual, 64)
+	if actual == "" {
+		a, aErr = 0, nil
+	}
+	b, bErr := strconv.ParseFloat(fc.value, 64)
+	if aErr == nil && bErr == nil {
+		switch fc.op {
+		case "==":
+			return a == b, actual
+		case "!=":
+			return a != b, actual
+		case ">=":
+			return a >= b, actual
+		case "<=":
+			return a <= b, actual
+		case ">":
+			return a > b, actual
+		case "<":
+			return a < b, actual
+		}
+	}
+	if actual == "" {
+		actual = "unset"
+	}
+	switch fc.op {
+	case "==":
+		return actual == fc.value, actual
+	case "!=":
+		return actual != fc.value, actual
+	}
+	return false, actual
+}
@@ -0,0 +1,170 @@
+package prober
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func object(apiVersion, kind, namespace, name string, labels map[string]interface{}, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"namespace": namespace,
+			"name":      name,
+			"labels":    labels,
+		},
+		"status": status,
+	}}
+}
+
+func TestKubernetesProber(t *testing.T) {
+	available := map[string]interface{}{
+		"readyReplicas": int64(3),
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Available", "status": "True", "message": "Deployment has minimum availability."},
+		},
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Group: "apps", Version: "v1", Resource: "deployments"}:             "DeploymentList",
+			{Version: "v1", Resource: "persistentvolumeclaims"}:                 "PersistentVolumeClaimList",
+			{Group: "cert-manager.io", Version: "v1", Resource: "certificates"}: "CertificateList",
+		},
+		object("apps/v1", "Deployment", "shop", "api", map[string]interface{}{"app": "shop"}, available),
+		object("apps/v1", "Deployment", "shop", "worker", map[string]interface{}{"app": "shop"}, map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Available", "status": "False", "message": "Deployment does not have minimum availability."},
+			},
+		}),
+		object("v1", "PersistentVolumeClaim", "shop", "data", nil, map[string]interface{}{"phase": "Bound"}),
+		object("cert-manager.io/v1", "Certificate", "shop", "tls", nil, map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "False", "reason": "Issuing"},
+			},
+		}),
+	)
+
+	tests := []struct {
+		name    string
+		target  string
+		options string
+		healthy bool
+		message string
+	}{
+		{"deployment available", "deployments.v1.apps/shop/api", `{"condition": "Available", "fields": ["status.readyReplicas >= 2"]}`, true, "Deployment api is healthy"},
+		{"too few replicas", "deployments.v1.apps/shop/api", `{"fields": ["status.readyReplicas >= 5"]}`, false, "status.readyReplicas: want >= 5"},
+		{"deployment unavailable", "deployments.v1.apps/shop/worker", `{"condition": "Available"}`, false, "condition Available is not True"},
+		{"unset counter is zero", "deployments.v1.apps/shop/worker", `{"fields": [".status.readyReplicas == 0"]}`, true, ""},
+		{"pvc bound", "persistentvolumeclaims.v1/shop/data", `{"fields": ["status.phase == Bound"]}`, true, "PersistentVolumeClaim data"},
+		{"certificate not ready", "certificates.v1.cert-manager.io/shop/tls", `{"condition": "Ready"}`, false, "condition Ready is not True"},
+		{"missing object", "deployments.v1.apps/shop/gone", ``, false, "not found"},
+		{"selector with a failing member", "deployments.v1.apps/shop", `{"labelSelector": "app=shop", "condition": "Available"}`, false, "worker"},
+		{"selector without matches", "deployments.v1.apps/shop", `{"labelSelector": "app=none"}`, false, "no deployments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := decodeKubernetesOptions(json.RawMessage(tt.options))
+			if err != nil {
+				t.Fatalf("decode error: %v", err)
+			}
+			p, err := NewKubernetesProber(client, tt.target, opts.(*KubernetesOptions))
+			if err != nil {
+				t.Fatalf("NewKubernetesProber() error: %v", err)
+			}
+			res := p.CheckResult()
+			if res.Healthy != tt.healthy {
+				t.Errorf("healthy = %v; want %v (%s)", res.Healthy, tt.healthy, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Errorf("message = %q; want it to contain %q", res.Message, tt.message)
+			}
+			if strings.Contains(res.Message, "availability") || strings.Contains(res.Message, "Issuing") {
+				t.Errorf("message = %q; must not copy the object", res.Message)
+			}
+		})
+	}
+}
+
+func TestKubernetesValidate(t *testing.T) {
+	tests := []struct {
+		target   string
+		options  string
+		problems int
+	}{
+		{"deployments.v1.apps/shop/api", "", 0},
+		{"nodes.v1/node-1", `{"condition": "Ready"}`, 0},
+		{"deployments.v1.apps/shop", `{"labelSelector": "app=shop"}`, 0},
+		{"deployments/shop/api", "", 1},
+		{"deployments.v1.apps/shop/api/extra", "", 1},
+		{"deployments.v1.apps/shop/", "", 1},
+		{"deployments.v1.apps/shop", `{"labelSelector": "app in (("}`, 1},
+		{"deployments.v1.apps/shop/api", `{"fields": ["status.readyReplicas"]}`, 1},
+		{"nodes.v1/node-1", `{"condtion": "Ready"}`, 1},
+	}
+
+	for _, tt := range tests {
+		if got := Validate("kubernetes", tt.target, json.RawMessage(tt.options)); len(got) != tt.problems {
+			t.Errorf("Validate(%q, %q) = %v; want %d problems", tt.target, tt.options, got, tt.problems)
+		}
+	}
+}
+
+func TestKubernetesNamespace(t *testing.T) {
+	SetKubernetesClient(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()))
+	defer SetKubernetesClient(nil)
+
+	for target, options := range map[string]string{
+		"deployments.v1.apps/payments/api": ``,
+		"deployments.v1.apps/payments":     `{"labelSelector": "app=shop"}`,
+	} {
+		if _, err := NewFor(ProbeInfo{Namespace: "shop", Name: "api"}, "kubernetes", target, json.RawMessage(options)); err == nil {
+			t.Errorf("NewFor(%q) succeeded; want a Probe limited to its namespace", target)
+		}
+	}
+	p, err := NewFor(ProbeInfo{Namespace: "shop", Name: "api"}, "kubernetes", "deployments.v1.apps/api", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns := p.(*KubernetesProber).ref.namespace; ns != "shop" {
+		t.Errorf("namespace = %q; want the one of the Probe", ns)
+	}
+	if _, err := NewFor(ProbeInfo{Name: "api"}, "kubernetes", "deployments.v1.apps/payments/api", nil); err != nil {
+		t.Errorf("NewFor() of a ClusterProbe error: %v", err)
+	}
+}
+
+func TestKubernetesConditionMessage(t *testing.T) {
+	SetKubernetesClient(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		object("apps/v1", "Deployment", "shop", "worker", nil, map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Available", "status": "False", "reason": "MinimumReplicasUnavailable", "message": "Deployment does not have minimum availability."},
+			},
+		}),
+	))
+	defer SetKubernetesClient(nil)
+
+	options := json.RawMessage(`{"condition": "Available"}`)
+	for _, tt := range []struct {
+		probe   ProbeInfo
+		message string
+	}{
+		{ProbeInfo{Namespace: "shop", Name: "worker"}, "condition Available is not True: MinimumReplicasUnavailable: Deployment does not have minimum availability."},
+		{ProbeInfo{Name: "worker"}, "condition Available is not True"},
+	} {
+		p, err := NewFor(tt.probe, "kubernetes", "deployments.v1.apps/shop/worker", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := Run(p); res.Healthy || res.Message != tt.message {
+			t.Errorf("namespace %q: result = %+v; want %q", tt.probe.Namespace, res, tt.message)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package prober
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"heartbeat-operator/pkg/plugin"
+)
+
+const (
+	// pluginGrace is added to the check timeout before giving up on a reply.
+	pluginGrace            = time.Second
+	pluginHandshakeTimeout = 10 * time.Second
+	pluginMaxBackoff       = 30 * time.Second
+)
+
+// StartPlugin launches or connects to the plugin at endpoint, registers the
+// check types it serves and restarts it whenever it exits or drops the
+// connection, until ctx is cancelled. endpoint is a command line, or
+// "unix:<path>" for a plugin listening on a socket. It returns the check
+// types registered.
+func StartPlugin(ctx context.Context, endpoint string) ([]string, error) {
+	h := &pluginHost{endpoint: endpoint}
+	conn, types, err := h.connect()
+	if err != nil {
+		return nil, err
+	}
+	h.setConn(conn)
+
+	var registered []string
+	for _, t := range types {
+		if IsRegistered(t) {
+			log.Printf("[Plugin] %s: check type %s is already registered, ignoring it", endpoint, t)
+			continue
+		}
+		checkType := t
+		Register(checkType, Type{
+			Decode: func(options json.RawMessage) (interface{}, error) { return options, nil },
+			New: func(target string, options interface{}) (Prober, error) {
+				return &pluginProber{host: h, checkType: checkType, target: target, options: options.(json.RawMessage)}, nil
+			},
+			NewFor: func(probe ProbeInfo, target string, options interface{}) (Prober, error) {
+				return &pluginProber{host: h, checkType: checkType, target: target, options: options.(json.RawMessage), probe: probe}, nil
+			},
+		})
+		registered = append(registered, checkType)
+	}
+	h.types = registered
+
+	go h.supervise(ctx, conn)
+	return registered, nil
+}
+
+// pluginHost keeps one plugin running.
+type pluginHost struct {
+	endpoint string
+	// types are the check types registered for the plugin.
+	types []string
+
+	mu   sync.Mutex
+	conn *pluginConn // nil while the plugin is restarting
+}
+
+func (h *pluginHost) setConn(c *pluginConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.conn = c
+}
+
+func (h *pluginHost) currentConn() *pluginConn {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.conn
+}
+
+// connect starts the plugin and performs the handshake.
+func (h *pluginHost) connect() (*pluginConn, []string, error) {
+	var conn *pluginConn
+	if path, ok := strings.CutPrefix(h.endpoint, "unix:"); ok {
+		c, err := net.DialTimeout("unix", path, pluginHandshakeTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		conn = newPluginConn(c, c, func() { c.Close() })
+	} else {
+		args := strings.Fields(h.endpoint)
+		if len(args) == 0 {
+			return nil, nil, errors.New("empty plugin command")
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stderr = os.Stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, nil, err
+		}
+		conn = newPluginConn(stdout, stdin, func() { _ = cmd.Process.Kill() })
+		go func() {
+			<-conn.done
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		}()
+	}
+
+	resp, err := conn.call(context.Background(), plugin.Request{Method: plugin.MethodHandshake, ProtocolVersion: plugin.ProtocolVersion}, pluginHandshakeTimeout)
+	if err == nil && resp.Error != "" {
+		err = errors.New(resp.Error)
+	}
+	if err == nil && resp.ProtocolVersion != plugin.ProtocolVersion {
+		err = fmt.Errorf("plugin speaks protocol version %d, want %d", resp.ProtocolVersion, plugin.ProtocolVersion)
+	}
+	if err != nil {
+		conn.close()
+		return nil, nil, fmt.Errorf("handshake: %v", err)
+	}
+	return conn, resp.CheckTypes, nil
+}
+
+// supervise waits for the plugin to go away and starts it again, backing
+// off while it keeps failing.
+func (h *pluginHost) supervise(ctx context.Context, conn *pluginConn) {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		select {
+		case <-ctx.Done():
+			conn.close()
+			return
+		case <-conn.done:
+		}
+		h.setConn(nil)
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("[Plugin] %s exited: %v", h.endpoint, conn.err)
+
+		for {
+			log.Printf("[Plugin] Restarting %s in %s", h.endpoint, backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, pluginMaxBackoff)
+
+			c, types, err := h.connect()
+			if err != nil {
+				log.Printf("[Plugin] Failed to restart %s: %v", h.endpoint, err)
+				continue
+			}
+			conn = c
+			h.setConn(conn)
+			log.Printf("[Plugin] Restarted %s", h.endpoint)
+			h.compareTypes(types)
+			break
+		}
+	}
+}
+
+// compareTypes logs the difference between the check types a restarted
+// plugin serves and those registered for it when it started. Check types
+// are only registered at start, checks of a type the plugin dropped fail
+// with its error until it serves the type again.
+func (h *pluginHost) compareTypes(types []string) {
+	served := make(map[string]bool, len(types))
+	for _, t := range types {
+		served[t] = true
+	}
+	registered := make(map[string]bool, len(h.types))
+	for _, t := range h.types {
+		registered[t] = true
+		if !served[t] {
+			log.Printf("[Plugin] %s no longer serves check type %s, its checks fail", h.endpoint, t)
+		}
+	}
+	for _, t := range types {
+		if !registered[t] && !IsRegistered(t) {
+			log.Printf("[Plugin] %s now serves check type %s, restart the operator to use it", h.endpoint, t)
+		}
+	}
+}
+
+func (h *pluginHost) check(ctx context.Context, probe ProbeInfo, checkType, target string, options json.RawMessage) Result {
+	conn := h.currentConn()
+	if conn == nil {
+		return Result{Message: fmt.Sprintf("plugin %s is restarting", h.endpoint)}
+	}
+	timeout := attemptTimeout(ctx, probe.Timeout)
+	resp, err := conn.call(ctx, plugin.Request{
+		Method:        plugin.MethodCheck,
+		CheckType:     checkType,
+		Target:        target,
+		Options:       options,
+		Namespace:     probe.Namespace,
+		Name:          probe.Name,
+		TimeoutMillis: timeout.Milliseconds(),
+	}, timeout+pluginGrace)
+	if err != nil {
+		return Result{Message: fmt.Sprintf("plugin %s: %v", h.endpoint, err)}
+	}
+	if resp.Error != "" {
+		return Result{Message: fmt.Sprintf("plugin %s: %s", h.endpoint, resp.Error)}
+	}
+	return Result{Healthy: resp.Healthy, Message: resp.Message}
+}
+
+// pluginConn multiplexes requests over one plugin connection.
+type pluginConn struct {
+	w     io.Writer
+	wmu   sync.Mutex
+	close func()
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan plugin.Response
+
+	// done is closed once the connection is gone, err tells why.
+	done chan struct{}
+	err  error
+}
+
+func newPluginConn(r io.Reader, w io.Writer, close func()) *pluginConn {
+	c := &pluginConn{
+		w:       w,
+		close:   close,
+		pending: make(map[uint64]chan plugin.Response),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(r)
+	return c
+}
+
+func (c *pluginConn) readLoop(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var resp plugin.Response
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			log.Printf("[Plugin] Ignoring malformed response: %v", err)
+			continue
+		}
+		c.mu.Lock()
+		ch, ok := c.pending[resp.ID]
+		delete(c.pending, resp.ID)
+		c.mu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	c.mu.Lock()
+	c.err = err
+	c.pending = nil
+	c.mu.Unlock()
+	close(c.done)
+}
+
+// call sends req and waits up to timeout for its response, or until ctx is
+// done.
+func (c *pluginConn) call(ctx context.Context, req plugin.Request, timeout time.Duration) (plugin.Response, error) {
+	ch := make(chan plugin.Response, 1)
+	c.mu.Lock()
+	if c.pending == nil {
+		c.mu.Unlock()
+		return plugin.Response{}, errors.New("plugin is not running")
+	}
+	c.nextID++
+	req.ID = c.nextID
+	c.pending[req.ID] = ch
+	c.mu.Unlock()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return plugin.Response{}, err
+	}
+	c.wmu.Lock()
+	_, err = c.w.Write(append(data, '\n'))
+	c.wmu.Unlock()
+	if err != nil {
+		c.close()
+		return plugin.Response{}, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-c.done:
+		return plugin.Response{}, errors.New("plugin exited")
+	case <-timer.C:
+		c.forget(req.ID)
+		return plugin.Response{}, fmt.Errorf("no reply within %s", timeout)
+	case <-ctx.Done():
+		c.forget(req.ID)
+		return plugin.Response{}, ctx.Err()
+	}
+}
+
+// forget drops the pending request id, its reply is ignored.
+func (c *pluginConn) forget(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending != nil {
+		delete(c.pending, id)
+	}
+}
+
+// pluginProber runs its checks in a plugin.
+type pluginProber struct {
+	host      *pluginHost
+	checkType string
+	target    string
+	options   json.RawMessage
+	// probe is zero for probers built without one, checks then get
+	// the default timeout.
+	probe ProbeInfo
+}
+
+func (p *pluginProber) Check() bool {
+	return p.CheckResult().Healthy
+}
+
+func (p *pluginProber) CheckResult() Result {
+	return p.CheckContext(context.Background())
+}
+
+func (p *pluginProber) CheckContext(ctx context.Context) Result {
+	return p.host.check(ctx, p.probe, p.checkType, p.target, p.options)
+}
@@ -0,0 +1,100 @@
+package prober
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"heartbeat-operator/pkg/plugin"
+)
+
+// The test binary doubles as a plugin when started by TestPlugin, and as the
+// sandbox of exec checks with limits.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == SandboxCommand {
+		err := RunSandbox(os.Args[2:])
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(126)
+	}
+	if os.Getenv("PROBER_TEST_PLUGIN") == "1" {
+		_ = plugin.Serve(os.Stdin, os.Stdout, plugin.Checks{
+			"test-echo": func(_ context.Context, target string, _ json.RawMessage) (bool, string) {
+				return target == "up", "echo " + target
+			},
+			"test-probe": func(ctx context.Context, _ string, _ json.RawMessage) (bool, string) {
+				probe := plugin.ProbeFrom(ctx)
+				deadline, _ := ctx.Deadline()
+				return true, fmt.Sprintf("%s/%s %s", probe.Namespace, probe.Name, time.Until(deadline).Round(time.Second))
+			},
+			"test-slow": func(ctx context.Context, _ string, _ json.RawMessage) (bool, string) {
+				<-ctx.Done()
+				return false, "stopped"
+			},
+			"test-crash": func(context.Context, string, json.RawMessage) (bool, string) {
+				os.Exit(3)
+				return false, ""
+			},
+		})
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestPlugin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Setenv("PROBER_TEST_PLUGIN", "1")
+	types, err := StartPlugin(ctx, os.Args[0])
+	if err != nil {
+		t.Fatalf("StartPlugin() error: %v", err)
+	}
+	if len(types) != 4 || !IsRegistered("test-echo") {
+		t.Fatalf("registered types = %v", types)
+	}
+
+	echo, err := New("test-echo", "up", nil)
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if res := Run(echo); !res.Healthy || res.Message != "echo up" {
+		t.Errorf("Run(echo up) = %+v", res)
+	}
+	down, _ := New("test-echo", "down", nil)
+	if res := Run(down); res.Healthy {
+		t.Errorf("Run(echo down) = %+v; want unhealthy", res)
+	}
+
+	probe, err := NewFor(ProbeInfo{Namespace: "shop", Name: "db", Timeout: 5 * time.Second}, "test-probe", "", nil)
+	if err != nil {
+		t.Fatalf("NewFor() error: %v", err)
+	}
+	if res := Run(probe); res.Message != "shop/db 5s" {
+		t.Errorf("Run(probe) = %+v; want the probe and its timeout", res)
+	}
+
+	// The retry budget bounds the check in the plugin and the wait for it
+	slow, _ := NewFor(ProbeInfo{Namespace: "shop", Name: "slow", Timeout: 10 * time.Second}, "test-slow", "", nil)
+	start := time.Now()
+	res := RunWithRetry(context.Background(), slow, Retry{Budget: 200 * time.Millisecond})
+	if elapsed := time.Since(start); res.Healthy || elapsed > 2*time.Second {
+		t.Errorf("Run(slow) = %+v after %s; want it cut off at the budget", res, elapsed)
+	}
+
+	crash, _ := New("test-crash", "", nil)
+	if res := Run(crash); res.Healthy {
+		t.Errorf("Run(crash) = %+v; want unhealthy", res)
+	}
+
+	// The plugin is restarted after a crash
+	deadline := time.Now().Add(10 * time.Second)
+	for !Run(echo).Healthy {
+		if time.Now().After(deadline) {
+			t.Fatalf("plugin was not restarted: %+v", Run(echo))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
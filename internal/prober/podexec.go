@@ -0,0 +1,273 @@
+package prober
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// podExecTimeout bounds a command run in a pod of a probe without timeout,
+// including setting up the stream through the API server.
+const podExecTimeout = 10 * time.Second
+
+var podsResource = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// PodSelector picks the pod an exec check runs its command in.
+type PodSelector struct {
+	// Namespace of the pod, the one of the Probe by default. A Probe can
+	// only run commands in pods of its own namespace.
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the pod. Exactly one of Name and LabelSelector must be set.
+	Name string `json:"name,omitempty"`
+	// LabelSelector picks the first running and ready pod that matches.
+	LabelSelector string `json:"labelSelector,omitempty"`
+	// Container defaults to the first container of the pod.
+	Container string `json:"container,omitempty"`
+}
+
+func (s *PodSelector) validate() []string {
+	var problems []string
+	if (s.Name == "") == (s.LabelSelector == "") {
+		problems = append(problems, "pod: exactly one of name and labelSelector must be set")
+	}
+	if s.Name != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(s.Name) {
+			problems = append(problems, "pod.name: "+msg)
+		}
+	}
+	if s.LabelSelector != "" {
+		if _, err := labels.Parse(s.LabelSelector); err != nil {
+			problems = append(problems, fmt.Sprintf("pod.labelSelector: %v", err))
+		}
+	}
+	if s.Namespace != "" {
+		for _, msg := range validation.IsDNS1123Label(s.Namespace) {
+			problems = append(problems, "pod.namespace: "+msg)
+		}
+	}
+	if s.Container != "" {
+		for _, msg := range validation.IsDNS1123Label(s.Container) {
+			problems = append(problems, "pod.container: "+msg)
+		}
+	}
+	return problems
+}
+
+// podExecFunc runs command in a container of a pod and writes its output to
+// stdout and stderr. A non-zero exit code is returned as a
+// utilexec.ExitError.
+type podExecFunc func(ctx context.Context, namespace, pod, container string, command []string, stdout, stderr io.Writer) error
+
+// podLogFunc reads the logs of a container of a pod.
+type podLogFunc func(ctx context.Context, namespace, pod, container string) ([]byte, error)
+
+var (
+	podExecMu sync.RWMutex
+	podExec   podExecFunc
+	podLogs   podLogFunc
+)
+
+// SetRestConfig sets the config exec checks in a pod or Job connect to the
+// API server with. Without it they fail.
+func SetRestConfig(config *rest.Config) error {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	setPodLogs(func(ctx context.Context, namespace, pod, container string) ([]byte, error) {
+		limit := int64(64 << 10)
+		return clientset.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{
+			Container:  container,
+			LimitBytes: &limit,
+		}).DoRaw(ctx)
+	})
+	setPodExec(func(ctx context.Context, namespace, pod, container string, command []string, stdout, stderr io.Writer) error {
+		req := clientset.CoreV1().RESTClient().Post().
+			Resource("pods").Namespace(namespace).Name(pod).SubResource("exec").
+			VersionedParams(&corev1.PodExecOptions{
+				Container: container,
+				Command:   command,
+				Stdout:    true,
+				Stderr:    true,
+			}, scheme.ParameterCodec)
+
+		// WebSockets first, SPDY for API servers older than 1.30
+		ws, err := remotecommand.NewWebSocketExecutor(config, "GET", req.URL().String())
+		if err != nil {
+			return err
+		}
+		spdy, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+		if err != nil {
+			return err
+		}
+		executor, err := remotecommand.NewFallbackExecutor(ws, spdy, func(err error) bool {
+			return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+		})
+		if err != nil {
+			return err
+		}
+		return executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: stdout, Stderr: stderr})
+	})
+	return nil
+}
+
+func setPodExec(f podExecFunc) {
+	podExecMu.Lock()
+	defer podExecMu.Unlock()
+	podExec = f
+}
+
+func podExecutor() podExecFunc {
+	podExecMu.RLock()
+	defer podExecMu.RUnlock()
+	return podExec
+}
+
+func setPodLogs(f podLogFunc) {
+	podExecMu.Lock()
+	defer podExecMu.Unlock()
+	podLogs = f
+}
+
+func podLogReader() podLogFunc {
+	podExecMu.RLock()
+	defer podExecMu.RUnlock()
+	return podLogs
+}
+
+func newPodExecProberFor(probe ProbeInfo, target string, pod *PodSelector) (Prober, error) {
+	client, exec := kubernetesClient(), podExecutor()
+	if client == nil || exec == nil {
+		return nil, errors.New("exec checks in a pod need access to the Kubernetes API")
+	}
+	sel := *pod
+	if sel.Namespace == "" {
+		sel.Namespace = probe.Namespace
+	}
+	switch {
+	case sel.Namespace == "":
+		return nil, errors.New("exec checks of a ClusterProbe must set pod.namespace")
+	case probe.Namespace != "" && sel.Namespace != probe.Namespace:
+		return nil, fmt.Errorf("a Probe in %s cannot run commands in pods of %s", probe.Namespace, sel.Namespace)
+	}
+	return &PodExecProber{Client: client, Pod: sel, Command: strings.Fields(target), Timeout: probe.Timeout, exec: exec}, nil
+}
+
+// PodExecProber runs its command in a container of another pod through the
+// pods/exec subresource, so it runs with the network and identity of that
+// pod.
+type PodExecProber struct {
+	Client  dynamic.Interface
+	Pod     PodSelector
+	Command []string
+	// Timeout bounds the command, podExecTimeout when zero.
+	Timeout time.Duration
+
+	exec podExecFunc
+}
+
+func (p *PodExecProber) Check() bool {
+	return p.CheckResult().Healthy
+}
+
+func (p *PodExecProber) CheckResult() Result {
+	return p.CheckContext(context.Background())
+}
+
+func (p *PodExecProber) CheckContext(ctx context.Context) Result {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = podExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	pod, err := p.selectPod(ctx)
+	if err != nil {
+		log.Printf("[Exec] No pod for %s/%s%s: %v", p.Pod.Namespace, p.Pod.Name, p.Pod.LabelSelector, err)
+		return Result{Message: err.Error()}
+	}
+	container := p.Pod.Container
+	if container == "" && len(pod.Spec.Containers) > 0 {
+		container = pod.Spec.Containers[0].Name
+	}
+
+	var output bytes.Buffer
+	err = p.exec(ctx, pod.Namespace, pod.Name, container, p.Command, &output, &output)
+	prefix := fmt.Sprintf("pod %s: ", pod.Name)
+	if err != nil {
+		var exitErr utilexec.ExitError
+		if !errors.As(err, &exitErr) {
+			log.Printf("[Exec] Exec in pod %s/%s failed: %v", pod.Namespace, pod.Name, err)
+			return Result{Message: prefix + err.Error()}
+		}
+		return Result{Message: prefix + execMessage("pod "+pod.Namespace+"/"+pod.Name, exitErr.ExitStatus(), output.Bytes())}
+	}
+	return Result{Healthy: true, Message: prefix + execMessage("pod "+pod.Namespace+"/"+pod.Name, 0, output.Bytes())}
+}
+
+// selectPod gets the named pod, or the first running and ready one of the
+// label selector by name.
+func (p *PodExecProber) selectPod(ctx context.Context) (*corev1.Pod, error) {
+	client := p.Client.Resource(podsResource).Namespace(p.Pod.Namespace)
+	if p.Pod.Name != "" {
+		obj, err := client.Get(ctx, p.Pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		pod := &corev1.Pod{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, pod); err != nil {
+			return nil, err
+		}
+		if pod.Status.Phase != corev1.PodRunning {
+			return nil, fmt.Errorf("pod %s is %s", pod.Name, pod.Status.Phase)
+		}
+		return pod, nil
+	}
+
+	list, err := client.List(ctx, metav1.ListOptions{LabelSelector: p.Pod.LabelSelector})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].GetName() < list.Items[j].GetName() })
+	for _, item := range list.Items {
+		pod := &corev1.Pod{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, pod); err != nil {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodRunning && podReady(pod) {
+			return pod, nil
+		}
+	}
+	return nil, fmt.Errorf("no running and ready pod matches %s", p.Pod.LabelSelector)
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package prober
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+func pod(namespace, name, phase string, ready bool, labels map[string]interface{}) *unstructured.Unstructured {
+	status := "False"
+	if ready {
+		status = "True"
+	}
+	obj := object("v1", "Pod", namespace, name, labels, map[string]interface{}{
+		"phase":      phase,
+		"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": status}},
+	})
+	obj.Object["spec"] = map[string]interface{}{
+		"containers": []interface{}{map[string]interface{}{"name": "app"}, map[string]interface{}{"name": "sidecar"}},
+	}
+	return obj
+}
+
+func TestPodExecProber(t *testing.T) {
+	app := map[string]interface{}{"app": "shop"}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{podsResource: "PodList"},
+		pod("shop", "api-0", "Pending", false, app),
+		pod("shop", "api-1", "Running", false, app),
+		pod("shop", "api-2", "Running", true, app),
+		pod("shop", "api-3", "Running", true, app),
+		pod("shop", "db-0", "Running", true, nil),
+	)
+
+	var ran []string
+	exec := func(_ context.Context, namespace, pod, container string, command []string, stdout, _ io.Writer) error {
+		ran = append(ran, fmt.Sprintf("%s/%s/%s", namespace, pod, container))
+		fmt.Fprintln(stdout, strings.Join(command, " "))
+		if command[0] == "false" {
+			return utilexec.CodeExitError{Err: fmt.Errorf("command terminated with exit code 1"), Code: 1}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		pod     PodSelector
+		command string
+		healthy bool
+		message string
+		ran     string
+	}{
+		{"first ready pod", PodSelector{Namespace: "shop", LabelSelector: "app=shop"}, "pg_isready -h db", true, "pod api-2: exit code 0", "shop/api-2/app"},
+		{"named pod and container", PodSelector{Namespace: "shop", Name: "db-0", Container: "sidecar"}, "true", true, "pod db-0", "shop/db-0/sidecar"},
+		{"exit code", PodSelector{Namespace: "shop", Name: "db-0"}, "false", false, "pod db-0: exit code 1", "shop/db-0/app"},
+		{"pod not running", PodSelector{Namespace: "shop", Name: "api-0"}, "true", false, "is Pending", ""},
+		{"no ready pod", PodSelector{Namespace: "shop", LabelSelector: "app=cart"}, "true", false, "no running and ready pod", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran = nil
+			p := &PodExecProber{Client: client, Pod: tt.pod, Command: strings.Fields(tt.command), exec: exec}
+			res := p.CheckResult()
+			if res.Healthy != tt.healthy {
+				t.Errorf("healthy = %v; want %v (%s)", res.Healthy, tt.healthy, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Errorf("message = %q; want it to contain %q", res.Message, tt.message)
+			}
+			if strings.Contains(res.Message, tt.command) {
+				t.Errorf("message = %q; must not hold the output", res.Message)
+			}
+			if got := strings.Join(ran, ","); got != tt.ran {
+				t.Errorf("ran in %q; want %q", got, tt.ran)
+			}
+		})
+	}
+}
+
+func TestPodExecRegistration(t *testing.T) {
+	for options, problems := range map[string]int{
+		`{"pod": {"labelSelector": "app=shop", "container": "app"}}`: 0,
+		`{"pod": {"name": "db-0", "namespace": "shop"}}`:             0,
+		`{"pod": {}}`: 1,
+		`{"pod": {"name": "db-0", "labelSelector": "app=shop"}}`: 1,
+		`{"pod": {"labelSelector": "app in"}}`:                   1,
+		`{"pod": {"name": "db-0", "podName": "x"}}`:              1,
+		`{"retries": 3}`: 1,
+	} {
+		if got := Validate("exec", "pg_isready", json.RawMessage(options)); len(got) != problems {
+			t.Errorf("Validate(%s) = %v; want %d problems", options, got, problems)
+		}
+	}
+
+	options := json.RawMessage(`{"pod": {"name": "db-0", "namespace": "other"}}`)
+	if _, err := New("exec", "true", options); err == nil {
+		t.Errorf("expected New to refuse an exec check in a pod without its probe")
+	}
+
+	SetKubernetesClient(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()))
+	defer SetKubernetesClient(nil)
+	setPodExec(func(context.Context, string, string, string, []string, io.Writer, io.Writer) error { return nil })
+	defer setPodExec(nil)
+
+	if _, err := NewFor(ProbeInfo{Namespace: "shop", Name: "db"}, "exec", "true", options); err == nil {
+		t.Errorf("expected a Probe to be refused pods of another namespace")
+	}
+	if _, err := NewFor(ProbeInfo{Name: "db"}, "exec", "true", json.RawMessage(`{"pod": {"name": "db-0"}}`)); err == nil {
+		t.Errorf("expected a ClusterProbe to need pod.namespace")
+	}
+	p, err := NewFor(ProbeInfo{Name: "db"}, "exec", "true", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pe := p.(*PodExecProber); pe.Pod.Namespace != "other" {
+		t.Errorf("namespace = %q; want other", pe.Pod.Namespace)
+	}
+	p, err = NewFor(ProbeInfo{Namespace: "shop", Name: "db"}, "exec", "true", json.RawMessage(`{"pod": {"name": "db-0"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pe := p.(*PodExecProber); pe.Pod.Namespace != "shop" {
+		t.Errorf("namespace = %q; want the one of the probe", pe.Pod.Namespace)
+	}
+}
@@ -1,5 +1,134 @@
 package prober
 
+import (
+	"context"
+	"time"
+)
+
 type Prober interface {
 	Check() bool
 }
+
+// Result is the outcome of a single check.
+type Result struct {
+	Healthy bool
+	// Message explains the outcome, e.g. the error of a failed check.
+	Message string
+	// Endpoints holds the result of every endpoint of a fanned out check.
+	Endpoints []EndpointResult
+	// Heartbeat is set by heartbeat checks.
+	Heartbeat *HeartbeatResult
+	// HTTP is set by http checks of a single URL.
+	HTTP *HTTPResult
+	// PolicyDenied is set when the target policy refused the target, the
+	// check did not run.
+	PolicyDenied bool
+	// Attempts is the number of times the check ran, more than 1 when it
+	// was retried.
+	Attempts int
+}
+
+// HeartbeatResult tells what the pings of a heartbeat check reported.
+type HeartbeatResult struct {
+	LastPing    time.Time
+	LastSuccess time.Time
+	// RunDuration is the time between the start ping and the end of the
+	// last run, zero when the job does not send start pings.
+	RunDuration time.Duration
+}
+
+// HTTPPhases are the phases of an http check, named like those of the
+// blackbox_exporter.
+var HTTPPhases = []string{"resolve", "connect", "tls", "processing", "transfer"}
+
+// HTTPResult tells where the time of an http check went and what it got.
+type HTTPResult struct {
+	// Phases holds the time spent in every one of HTTPPhases, summed over
+	// redirects. Phases the request did not reach are zero.
+	Phases map[string]time.Duration
+	// StatusCode is that of the last response, 0 without one.
+	StatusCode int
+	// Version is the HTTP version of the last response, e.g. 1.1 or 2.
+	Version float64
+	// Redirects is the number of redirects followed.
+	Redirects int
+	// ContentLength is the length of the last response body, as announced
+	// or else as read.
+	ContentLength int64
+}
+
+// EndpointResult is the outcome of a check against one endpoint.
+type EndpointResult struct {
+	// Address is the ip:port of the endpoint.
+	Address string
+	// Target is the check target the endpoint was probed with.
+	Target  string
+	Healthy bool
+	Message string
+}
+
+// ResultProber is implemented by probers that explain their outcome.
+type ResultProber interface {
+	Prober
+	CheckResult() Result
+}
+
+// ContextProber is implemented by probers that stop their check when a
+// context is done, besides at their own timeout.
+type ContextProber interface {
+	Prober
+	CheckContext(ctx context.Context) Result
+}
+
+// StoppingProber is implemented by probers that hold on to something
+// between checks, released by Stop once the prober is no longer used.
+type StoppingProber interface {
+	Prober
+	Stop()
+}
+
+// RunContext checks p once like Run, within ctx. A prober that does not take
+// a context is left to finish in the background when ctx is done first.
+func RunContext(ctx context.Context, p Prober) Result {
+	if cp, ok := p.(ContextProber); ok {
+		res := cp.CheckContext(ctx)
+		if res.Message == "" {
+			res.Message = defaultMessage(res.Healthy)
+		}
+		res.Attempts = 1
+		return res
+	}
+	if ctx.Done() == nil {
+		return Run(p)
+	}
+	done := make(chan Result, 1)
+	go func() { done <- Run(p) }()
+	select {
+	case res := <-done:
+		return res
+	case <-ctx.Done():
+		return Result{Message: ctx.Err().Error(), Attempts: 1}
+	}
+}
+
+// Run checks p once. Probers that only report pass or fail get a generic
+// message.
+func Run(p Prober) Result {
+	if rp, ok := p.(ResultProber); ok {
+		res := rp.CheckResult()
+		if res.Message == "" {
+			res.Message = defaultMessage(res.Healthy)
+		}
+		res.Attempts = 1
+		return res
+	}
+	healthy := p.Check()
+	return Result{Healthy: healthy, Message: defaultMessage(healthy), Attempts: 1}
+}
+
+func defaultMessage(healthy bool) string {
+	if healthy {
+		return "Check passed"
+	}
+	return "Check failed"
+}
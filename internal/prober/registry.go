@@ -0,0 +1,165 @@
+package prober
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Type describes a check type. Built-in types register themselves in init,
+// a fork can add its own the same way from a file of its own.
+type Type struct {
+	// Decode parses the options of a rule, which may be empty. When nil, the
+	// type takes no options and rejects any that are set.
+	Decode func(options json.RawMessage) (interface{}, error)
+	// Validate checks the target and decoded options and returns one message
+	// per problem. It may be nil.
+	Validate func(target string, options interface{}) []string
+	// New builds the prober from a validated target and decoded options.
+	New func(target string, options interface{}) (Prober, error)
+	// NewFor is used instead of New by types that need to know the probe
+	// they check for. One of New and NewFor must be set.
+	NewFor func(probe ProbeInfo, target string, options interface{}) (Prober, error)
+}
+
+// ProbeInfo identifies the probe a prober is built for.
+type ProbeInfo struct {
+	// Namespace is empty for ClusterProbes.
+	Namespace string
+	Name      string
+	Interval  time.Duration
+	// Timeout is zero when the probe sets none.
+	Timeout time.Duration
+	// Schedule is the cron schedule of the probe, empty when it runs every
+	// Interval.
+	Schedule string
+}
+
+// DefaultTimeout bounds a check of a probe that sets no timeout.
+const DefaultTimeout = 2 * time.Second
+
+// checkTimeout returns d, or DefaultTimeout when it is not positive.
+func checkTimeout(d time.Duration) time.Duration {
+	if d <= 0 {
+		return DefaultTimeout
+	}
+	return d
+}
+
+// attemptTimeout returns checkTimeout(d), cut to what is left of ctx, the
+// budget of the retries of the check.
+func attemptTimeout(ctx context.Context, d time.Duration) time.Duration {
+	timeout := checkTimeout(d)
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = min(timeout, time.Until(deadline).Round(time.Millisecond))
+	}
+	return timeout
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Type)
+)
+
+// Register makes a check type available under name. It panics when name is
+// registered twice or t has neither New nor NewFor, as both are programming
+// errors.
+func Register(name string, t Type) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if t.New == nil && t.NewFor == nil {
+		panic(fmt.Sprintf("prober: Register of %q without New", name))
+	}
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("prober: Register called twice for %q", name))
+	}
+	registry[name] = t
+}
+
+// Types returns the names of the registered check types, sorted.
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsRegistered reports whether checkType names a registered check type.
+func IsRegistered(checkType string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	_, ok := registry[checkType]
+	return ok
+}
+
+// Validate decodes and checks target and options for checkType, and returns
+// one message per problem. An unknown checkType is reported as well.
+func Validate(checkType, target string, options json.RawMessage) []string {
+	t, decoded, err := decode(checkType, options)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if t.Validate == nil {
+		return nil
+	}
+	return t.Validate(target, decoded)
+}
+
+// New builds the prober for a rule through its registered check type. The
+// target is not validated, a malformed one fails its checks.
+func New(checkType, target string, options json.RawMessage) (Prober, error) {
+	t, decoded, err := decode(checkType, options)
+	if err != nil {
+		return nil, err
+	}
+	if t.New == nil {
+		return nil, fmt.Errorf("check type %s can only be built for a probe", checkType)
+	}
+	return t.New(target, decoded)
+}
+
+// NewFor builds the prober of probe through its registered check type, like
+// New.
+func NewFor(probe ProbeInfo, checkType, target string, options json.RawMessage) (Prober, error) {
+	t, decoded, err := decode(checkType, options)
+	if err != nil {
+		return nil, err
+	}
+	if t.NewFor != nil {
+		return t.NewFor(probe, target, decoded)
+	}
+	return t.New(target, decoded)
+}
+
+func decode(checkType string, options json.RawMessage) (Type, interface{}, error) {
+	registryMu.RLock()
+	t, ok := registry[checkType]
+	registryMu.RUnlock()
+	if !ok {
+		return Type{}, nil, fmt.Errorf("unknown CheckType '%s'", checkType)
+	}
+
+	empty := len(bytes.TrimSpace(options)) == 0 || string(bytes.TrimSpace(options)) == "null"
+	if t.Decode == nil {
+		if !empty {
+			return Type{}, nil, fmt.Errorf("check type %s takes no options", checkType)
+		}
+		return t, nil, nil
+	}
+	decoded, err := t.Decode(options)
+	if err != nil {
+		return Type{}, nil, fmt.Errorf("invalid %s options: %v", checkType, err)
+	}
+	return t, decoded, nil
+}
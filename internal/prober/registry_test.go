@@ -0,0 +1,91 @@
+package prober
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type staticProber struct{ healthy bool }
+
+func (p *staticProber) Check() bool { return p.healthy }
+
+func TestRegistry(t *testing.T) {
+	type staticOptions struct {
+		Healthy bool `json:"healthy"`
+	}
+	Register("test-static", Type{
+		Decode: func(options json.RawMessage) (interface{}, error) {
+			o := &staticOptions{}
+			if len(options) > 0 {
+				if err := json.Unmarshal(options, o); err != nil {
+					return nil, err
+				}
+			}
+			return o, nil
+		},
+		Validate: func(target string, _ interface{}) []string {
+			if target != "static" {
+				return []string{"must be static"}
+			}
+			return nil
+		},
+		New: func(_ string, options interface{}) (Prober, error) {
+			return &staticProber{healthy: options.(*staticOptions).Healthy}, nil
+		},
+	})
+
+	p, err := New("test-static", "static", json.RawMessage(`{"healthy": true}`))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	if !p.Check() {
+		t.Errorf("expected decoded options to make the prober healthy")
+	}
+
+	tests := []struct {
+		checkType string
+		target    string
+		options   string
+		problems  int
+	}{
+		{"test-static", "static", `{"healthy": false}`, 0},
+		{"test-static", "dynamic", "", 1},
+		{"test-static", "static", `{"healthy": "yes"}`, 1},
+		{"http", "https://example.com", "", 0},
+		{"http", "https://example.com", `{"verb": "HEAD"}`, 1}, // unknown http option
+		{"tcp", "postgres", "", 1},
+		{"dns", "example.com", "null", 0},
+		{"carrier-pigeon", "coop", "", 1},
+	}
+	for _, tt := range tests {
+		got := Validate(tt.checkType, tt.target, json.RawMessage(tt.options))
+		if len(got) != tt.problems {
+			t.Errorf("Validate(%q, %q, %q) = %v; want %d problems", tt.checkType, tt.target, tt.options, got, tt.problems)
+		}
+	}
+
+	if _, err := New("carrier-pigeon", "coop", nil); err == nil {
+		t.Errorf("expected error for unknown check type")
+	}
+	types := Types()
+	if !sort.StringsAreSorted(types) {
+		t.Errorf("Types() = %v; want sorted", types)
+	}
+	registered := " " + strings.Join(types, " ") + " "
+	for _, builtin := range []string{"dns", "exec", "http", "tcp"} {
+		if !strings.Contains(registered, " "+builtin+" ") {
+			t.Errorf("Types() = %v; want it to include %s", types, builtin)
+		}
+	}
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Register to panic on a duplicate name")
+		}
+	}()
+	Register("http", Type{New: func(string, interface{}) (Prober, error) { return nil, nil }})
+}
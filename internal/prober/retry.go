@@ -0,0 +1,73 @@
+package prober
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultMaxDelay caps exponential delays when Retry has no MaxDelay.
+const defaultMaxDelay = time.Minute
+
+// Retry repeats a failed check within a single execution, to ride out
+// transient errors without waiting for the next one.
+type Retry struct {
+	// Count is the number of attempts after the first one.
+	Count int
+	// Delay is the wait before the first retry. Exponential doubles it
+	// after every retry, up to MaxDelay, a minute when zero.
+	Delay       time.Duration
+	Exponential bool
+	MaxDelay    time.Duration
+	// Budget bounds the attempts and the delays between them: every attempt
+	// is cut off at its end and no retry starts after it. There is no bound
+	// when it is zero.
+	Budget time.Duration
+}
+
+// RunWithRetry checks p like RunContext, again after a failure as r allows.
+// The result is that of the last attempt and counts the attempts. Targets
+// the target policy refuses are not retried, and nothing is once ctx is
+// done.
+func RunWithRetry(ctx context.Context, p Prober, r Retry) Result {
+	if r.Budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Budget)
+		defer cancel()
+	}
+	maxDelay := r.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxDelay
+	}
+
+	start := time.Now()
+	delay := r.Delay
+	for attempt := 1; ; attempt++ {
+		res := RunContext(ctx, p)
+		res.Attempts = attempt
+		if res.Healthy || res.PolicyDenied {
+			return res
+		}
+		if attempt > r.Count || ctx.Err() != nil || (r.Budget > 0 && time.Since(start)+delay >= r.Budget) {
+			return retried(res)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return retried(res)
+		}
+		if r.Exponential {
+			delay = min(2*delay, maxDelay)
+		}
+	}
+}
+
+// retried notes the attempts of a retried failure in its message.
+func retried(res Result) Result {
+	if res.Attempts > 1 {
+		res.Message = fmt.Sprintf("%s (after %d attempts)", res.Message, res.Attempts)
+	}
+	return res
+}
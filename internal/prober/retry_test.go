@@ -0,0 +1,121 @@
+package prober
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flakyProber fails its first failures checks.
+type flakyProber struct {
+	failures int
+	calls    []time.Time
+}
+
+func (p *flakyProber) Check() bool { return p.CheckResult().Healthy }
+
+func (p *flakyProber) CheckResult() Result {
+	p.calls = append(p.calls, time.Now())
+	if len(p.calls) <= p.failures {
+		return Result{Message: "connection reset by peer"}
+	}
+	return Result{Healthy: true}
+}
+
+func TestRunWithRetry(t *testing.T) {
+	p := &flakyProber{failures: 2}
+	res := RunWithRetry(context.Background(), p, Retry{Count: 3, Delay: time.Millisecond})
+	if !res.Healthy || res.Attempts != 3 || res.Message != "Check passed" {
+		t.Errorf("result = %+v; want a pass on the third attempt", res)
+	}
+
+	p = &flakyProber{failures: 10}
+	res = RunWithRetry(context.Background(), p, Retry{Count: 2, Delay: time.Millisecond})
+	if res.Healthy || res.Attempts != 3 || !strings.HasSuffix(res.Message, "connection reset by peer (after 3 attempts)") {
+		t.Errorf("result = %+v; want a failure after 3 attempts", res)
+	}
+
+	if res = RunWithRetry(context.Background(), &flakyProber{failures: 1}, Retry{}); res.Healthy || res.Attempts != 1 || res.Message != "connection reset by peer" {
+		t.Errorf("result without retries = %+v", res)
+	}
+}
+
+func TestRunWithRetryBackoff(t *testing.T) {
+	p := &flakyProber{failures: 10}
+	RunWithRetry(context.Background(), p, Retry{Count: 4, Delay: 10 * time.Millisecond, Exponential: true, MaxDelay: 25 * time.Millisecond})
+	if len(p.calls) != 5 {
+		t.Fatalf("%d attempts; want 5", len(p.calls))
+	}
+	// 10ms, 20ms, then capped at 25ms
+	for i, min := range []time.Duration{10, 20, 25, 25} {
+		if gap := p.calls[i+1].Sub(p.calls[i]); gap < min*time.Millisecond {
+			t.Errorf("delay before retry %d = %s; want at least %dms", i+1, gap, min)
+		}
+	}
+
+	// The budget leaves no time for a second retry
+	p = &flakyProber{failures: 10}
+	res := RunWithRetry(context.Background(), p, Retry{Count: 5, Delay: 20 * time.Millisecond, Budget: 30 * time.Millisecond})
+	if res.Attempts != 2 {
+		t.Errorf("%d attempts within the budget; want 2", res.Attempts)
+	}
+}
+
+// slowProber takes d for every check, or until its context is done when it
+// takes one.
+type slowProber struct {
+	d time.Duration
+}
+
+func (p *slowProber) Check() bool {
+	time.Sleep(p.d)
+	return true
+}
+
+func (p *slowProber) CheckContext(ctx context.Context) Result {
+	select {
+	case <-time.After(p.d):
+		return Result{Healthy: true}
+	case <-ctx.Done():
+		return Result{Message: ctx.Err().Error()}
+	}
+}
+
+// contextless hides CheckContext of a slowProber.
+type contextless struct{ *slowProber }
+
+func (p contextless) Check() bool { return p.slowProber.Check() }
+
+func TestRunWithRetryBudget(t *testing.T) {
+	// Every attempt is cut off at the end of the budget, with or without a
+	// context
+	for _, p := range []Prober{&slowProber{d: time.Second}, contextless{&slowProber{d: time.Second}}} {
+		start := time.Now()
+		res := RunWithRetry(context.Background(), p, Retry{Count: 3, Delay: 10 * time.Millisecond, Budget: 50 * time.Millisecond})
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("%T: took %s with a budget of 50ms", p, elapsed)
+		}
+		if res.Healthy || res.Attempts != 1 || res.Message != "context deadline exceeded" {
+			t.Errorf("%T: result = %+v; want a single attempt cut off", p, res)
+		}
+	}
+
+	// The timeout of the check is cut to the budget
+	exec := &ExecProber{Command: []string{"sleep", "10"}, Timeout: 10 * time.Second}
+	start := time.Now()
+	res := RunWithRetry(context.Background(), exec, Retry{Count: 3, Delay: 10 * time.Millisecond, Budget: 100 * time.Millisecond})
+	if elapsed := time.Since(start); elapsed > 5*time.Second || res.Attempts != 1 || !strings.HasPrefix(res.Message, "timed out after ") {
+		t.Errorf("result = %+v after %s; want the command killed at the end of the budget", res, elapsed)
+	}
+
+	// The delay ends with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	p := &flakyProber{failures: 10}
+	start = time.Now()
+	res = RunWithRetry(ctx, p, Retry{Count: 3, Delay: time.Second})
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond || res.Attempts != 1 {
+		t.Errorf("result = %+v after %s; want the delay cancelled", res, elapsed)
+	}
+}
@@ -0,0 +1,233 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ExecPolicy restricts exec checks that run their command in the operator
+// container. The zero value allows any command without limits.
+type ExecPolicy struct {
+	// AllowedCommands are absolute paths of executables, or of directories
+	// when they end in "/". Empty allows any command.
+	AllowedCommands []string
+	// Namespaces whose Probes may run commands in the operator. Empty
+	// allows every namespace. ClusterProbes are always allowed.
+	Namespaces []string
+	// CPUTime and Memory (address space in bytes) limit a command, zero
+	// means no limit.
+	CPUTime time.Duration
+	Memory  uint64
+	// MaxOutput is the number of bytes of output after which a command is
+	// stopped and fails, zero means no limit.
+	MaxOutput int64
+	// UID and GID run commands as another user when set, which needs the
+	// SETUID and SETGID capabilities.
+	UID, GID *uint32
+}
+
+// execPath is the whole environment of a command, nothing of the operator's
+// environment is passed on.
+const execPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// SandboxCommand is the argument the operator binary is started with to
+// apply the limits of the policy before it becomes the command of a check,
+// see RunSandbox.
+const SandboxCommand = "exec-sandbox"
+
+var (
+	execPolicyMu sync.RWMutex
+	execPolicy   ExecPolicy
+	// sandboxBinary is the executable that handles SandboxCommand.
+	sandboxBinary string
+)
+
+// SetExecPolicy sets the policy of exec checks in the operator. Limits are
+// applied by starting the running binary with SandboxCommand, so main must
+// call RunSandbox when it sees it.
+func SetExecPolicy(policy ExecPolicy) error {
+	for _, c := range policy.AllowedCommands {
+		if !filepath.IsAbs(c) {
+			return fmt.Errorf("allowed command %q is not an absolute path", c)
+		}
+	}
+	self, err := os.Executable()
+	if err != nil && (policy.CPUTime > 0 || policy.Memory > 0) {
+		return fmt.Errorf("limits need the path of the operator binary: %v", err)
+	}
+	execPolicyMu.Lock()
+	defer execPolicyMu.Unlock()
+	execPolicy, sandboxBinary = policy, self
+	return nil
+}
+
+func currentExecPolicy() (ExecPolicy, string) {
+	execPolicyMu.RLock()
+	defer execPolicyMu.RUnlock()
+	return execPolicy, sandboxBinary
+}
+
+// ExecEnabledIn reports whether Probes in namespace may run commands in the
+// operator. An empty namespace stands for ClusterProbes.
+func ExecEnabledIn(namespace string) bool {
+	policy, _ := currentExecPolicy()
+	if namespace == "" || len(policy.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range policy.Namespaces {
+		if ns == namespace || ns == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveCommand returns the absolute path name runs as, looked up in
+// execPath unless it holds a slash.
+func resolveCommand(name string) (string, error) {
+	if strings.Contains(name, "/") {
+		if !filepath.IsAbs(name) {
+			return "", fmt.Errorf("%s: relative paths are not allowed", name)
+		}
+		return filepath.Clean(name), nil
+	}
+	for _, dir := range filepath.SplitList(execPath) {
+		path := filepath.Join(dir, name)
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() && fi.Mode()&0o111 != 0 {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s: not found in %s", name, execPath)
+}
+
+// allowedCommand resolves name and checks it against the allowlist of the
+// policy.
+func (p ExecPolicy) allowedCommand(name string) (string, error) {
+	if len(p.AllowedCommands) == 0 {
+		return name, nil
+	}
+	path, err := resolveCommand(name)
+	if err != nil {
+		return "", err
+	}
+	for _, allowed := range p.AllowedCommands {
+		if strings.HasSuffix(allowed, "/") && strings.HasPrefix(path, allowed) || path == filepath.Clean(allowed) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not an allowed command", path)
+}
+
+// execWaitDelay is how long a command may keep its output open after it
+// was killed at its timeout.
+const execWaitDelay = time.Second
+
+// command builds the process of a check running argv under the policy. It
+// is killed once ctx is done.
+func (p ExecPolicy) command(ctx context.Context, sandbox string, argv []string) (*exec.Cmd, error) {
+	path, err := p.allowedCommand(argv[0])
+	if err != nil {
+		return nil, err
+	}
+	// Without allowlist the command is looked up as before
+	if path, err = exec.LookPath(path); err != nil {
+		return nil, err
+	}
+	var cmd *exec.Cmd
+	if p.CPUTime > 0 || p.Memory > 0 {
+		args := []string{SandboxCommand}
+		if p.CPUTime > 0 {
+			// Round up, a limit of zero seconds would mean none
+			args = append(args, "-cpu", strconv.FormatInt(int64((p.CPUTime+time.Second-1)/time.Second), 10))
+		}
+		if p.Memory > 0 {
+			args = append(args, "-memory", strconv.FormatUint(p.Memory, 10))
+		}
+		args = append(append(args, "--", path), argv[1:]...)
+		cmd = exec.CommandContext(ctx, sandbox, args...)
+	} else {
+		cmd = exec.CommandContext(ctx, path, argv[1:]...)
+	}
+	// Children left behind by a killed command may hold its output open,
+	// they get this long before the check stops waiting for them
+	cmd.WaitDelay = execWaitDelay
+	cmd.Env = []string{"PATH=" + execPath}
+	cmd.Dir = "/"
+	if p.UID != nil || p.GID != nil {
+		cred := &syscall.Credential{Uid: uint32(os.Getuid()), Gid: uint32(os.Getgid()), NoSetGroups: true}
+		if p.UID != nil {
+			cred.Uid = *p.UID
+		}
+		if p.GID != nil {
+			cred.Gid = *p.GID
+		}
+		cmd.SysProcAttr = &syscall.SysProcAttr{Credential: cred}
+	}
+	return cmd, nil
+}
+
+// RunSandbox applies the limits given in args, as passed after
+// SandboxCommand, and replaces the process with the command following "--".
+// It only returns on error.
+func RunSandbox(args []string) error {
+	fs := flag.NewFlagSet(SandboxCommand, flag.ContinueOnError)
+	cpu := fs.Uint64("cpu", 0, "CPU time limit in seconds")
+	memory := fs.Uint64("memory", 0, "address space limit in bytes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	argv := fs.Args()
+	if len(argv) == 0 {
+		return errors.New("no command to run")
+	}
+	if *cpu > 0 {
+		// SIGXCPU at the limit, SIGKILL a second later if it is ignored
+		if err := syscall.Setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: *cpu, Max: *cpu + 1}); err != nil {
+			return fmt.Errorf("CPU limit: %v", err)
+		}
+	}
+	if *memory > 0 {
+		if err := syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: *memory, Max: *memory}); err != nil {
+			return fmt.Errorf("memory limit: %v", err)
+		}
+	}
+	return syscall.Exec(argv[0], argv, os.Environ())
+}
+
+// cappedBuffer keeps up to max bytes of output. Beyond it calls exceeded,
+// which stops the command, and fails the write.
+type cappedBuffer struct {
+	mu       sync.Mutex
+	buf      []byte
+	max      int64
+	exceeded func()
+	overflow bool
+}
+
+var errOutputLimit = errors.New("output limit exceeded")
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.max > 0 && int64(len(b.buf)+len(p)) > b.max {
+		n := int(b.max) - len(b.buf)
+		b.buf = append(b.buf, p[:n]...)
+		if !b.overflow {
+			b.overflow = true
+			b.exceeded()
+		}
+		return n, errOutputLimit
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
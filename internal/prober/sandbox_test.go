@@ -0,0 +1,120 @@
+package prober
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setTestExecPolicy(t *testing.T, policy ExecPolicy) {
+	t.Helper()
+	if err := SetExecPolicy(policy); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = SetExecPolicy(ExecPolicy{}) })
+}
+
+// sandboxOutput runs argv under the current exec policy and returns its
+// output on one line, which checks keep out of their message.
+func sandboxOutput(t *testing.T, argv ...string) string {
+	t.Helper()
+	policy, sandbox := currentExecPolicy()
+	cmd, err := policy.command(context.Background(), sandbox, argv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("%v: %v", argv, err)
+	}
+	return execOutputTail(out)
+}
+
+func TestAllowedCommand(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "check.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho ok\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	echo, err := resolveCommand("echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := ExecPolicy{AllowedCommands: []string{echo, dir + "/"}}
+
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"echo", true},
+		{echo, true},
+		{script, true},
+		{dir + "/../" + filepath.Base(dir) + "/check.sh", true},
+		{dir + "/../other.sh", false},
+		{dir + "x/check.sh", false},
+		{"ls", false},
+		{"./check.sh", false},
+		{"no-such-command", false},
+	}
+	for _, tt := range tests {
+		if _, err := policy.allowedCommand(tt.name); (err == nil) != tt.ok {
+			t.Errorf("allowedCommand(%q) error = %v; want allowed %v", tt.name, err, tt.ok)
+		}
+	}
+
+	if err := SetExecPolicy(ExecPolicy{AllowedCommands: []string{"bin/echo"}}); err == nil {
+		t.Errorf("relative allowlist entry accepted")
+	}
+}
+
+func TestExecEnabledIn(t *testing.T) {
+	if !ExecEnabledIn("team-a") {
+		t.Errorf("exec not enabled without a policy")
+	}
+	setTestExecPolicy(t, ExecPolicy{Namespaces: []string{"ops"}})
+	if !ExecEnabledIn("ops") || !ExecEnabledIn("") {
+		t.Errorf("exec not enabled for ops or ClusterProbes")
+	}
+	if ExecEnabledIn("team-a") {
+		t.Errorf("exec enabled for team-a")
+	}
+	if _, err := NewFor(ProbeInfo{Namespace: "team-a", Name: "p"}, "exec", "true", nil); err == nil {
+		t.Errorf("prober built for a namespace without exec")
+	}
+}
+
+func TestExecPolicyEnforced(t *testing.T) {
+	t.Setenv("HEARTBEAT_SECRET", "s3cr3t")
+	setTestExecPolicy(t, ExecPolicy{MaxOutput: 1 << 10})
+
+	if env := sandboxOutput(t, "env"); env != "PATH="+execPath {
+		t.Errorf("environment = %q; want only PATH", env)
+	}
+
+	res := (&ExecProber{Command: []string{"sh", "-c", "while :; do echo flood; done"}}).CheckResult()
+	if res.Healthy || !strings.Contains(res.Message, "1024 bytes of output") {
+		t.Errorf("result = %+v; want stopped at the output limit", res)
+	}
+
+	setTestExecPolicy(t, ExecPolicy{AllowedCommands: []string{"/bin/true"}})
+	if res := NewExecProber("/bin/false").CheckResult(); res.Healthy || !strings.Contains(res.Message, "not an allowed command") {
+		t.Errorf("result = %+v; want the command refused", res)
+	}
+}
+
+func TestExecLimits(t *testing.T) {
+	setTestExecPolicy(t, ExecPolicy{CPUTime: 1500 * time.Millisecond, Memory: 256 << 20})
+
+	if limits := sandboxOutput(t, "sh", "-c", "ulimit -t; ulimit -v"); limits != "2 262144" {
+		t.Errorf("limits = %q; want CPU limit 2s and 256Mi of memory", limits)
+	}
+
+	// A timeout above the CPU limit, so the limit stops the command first
+	res := (&ExecProber{Command: []string{"sh", "-c", "while :; do :; done"}, Timeout: 10 * time.Second}).CheckResult()
+	if res.Healthy || res.Message != "killed by CPU time limit exceeded" {
+		t.Errorf("result = %+v; want killed at the CPU limit", res)
+	}
+}
@@ -0,0 +1,285 @@
+package prober
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TargetRules restrict the addresses http and tcp checks may connect to.
+// Hosts are names such as metadata.google.internal, or "*.example.com" for
+// every name below example.com.
+type TargetRules struct {
+	// AllowCIDRs and AllowHosts, when any is set, are the only targets
+	// allowed: a target passes when its name is allowed or all of its
+	// addresses are.
+	AllowCIDRs []string `json:"allowCIDRs,omitempty"`
+	AllowHosts []string `json:"allowHosts,omitempty"`
+	// DenyCIDRs, DenyHosts and DenyPorts refuse a target even when it is
+	// allowed, e.g. 169.254.169.254/32 or the kubelet port 10250.
+	DenyCIDRs []string `json:"denyCIDRs,omitempty"`
+	DenyHosts []string `json:"denyHosts,omitempty"`
+	DenyPorts []int    `json:"denyPorts,omitempty"`
+}
+
+// TargetPolicy holds the rules of all namespaces and those of single ones.
+// The deny lists of a namespace add to the operator-wide ones, its allow
+// lists replace them. ClusterProbes get the operator-wide rules.
+type TargetPolicy struct {
+	TargetRules
+	Namespaces map[string]TargetRules `json:"namespaces,omitempty"`
+}
+
+// PolicyError is returned when the target policy refuses a target.
+type PolicyError struct {
+	Target string
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("target %s denied by policy: %s", e.Target, e.Reason)
+}
+
+// targetGuard holds the rules of one namespace in parsed form.
+type targetGuard struct {
+	allowNets, denyNets   []netip.Prefix
+	allowHosts, denyHosts []string
+	denyPorts             map[int]bool
+}
+
+var (
+	targetPolicyMu sync.RWMutex
+	targetGuards   map[string]*targetGuard
+	defaultGuard   *targetGuard
+)
+
+// SetTargetPolicy sets the rules http and tcp checks are held to. An empty
+// policy allows every target.
+func SetTargetPolicy(policy TargetPolicy) error {
+	base, err := newTargetGuard(policy.TargetRules, nil)
+	if err != nil {
+		return err
+	}
+	guards := make(map[string]*targetGuard, len(policy.Namespaces))
+	for ns, rules := range policy.Namespaces {
+		if guards[ns], err = newTargetGuard(rules, base); err != nil {
+			return fmt.Errorf("namespace %s: %v", ns, err)
+		}
+	}
+	if base.empty() && len(guards) == 0 {
+		base = nil
+	}
+
+	targetPolicyMu.Lock()
+	defer targetPolicyMu.Unlock()
+	defaultGuard, targetGuards = base, guards
+	return nil
+}
+
+// guardFor returns the rules of namespace, nil when there is no policy.
+func guardFor(namespace string) *targetGuard {
+	targetPolicyMu.RLock()
+	defer targetPolicyMu.RUnlock()
+	if g, ok := targetGuards[namespace]; ok && namespace != "" {
+		return g
+	}
+	return defaultGuard
+}
+
+func newTargetGuard(rules TargetRules, base *targetGuard) (*targetGuard, error) {
+	g := &targetGuard{denyPorts: map[int]bool{}}
+	var err error
+	if g.allowNets, err = parsePrefixes(rules.AllowCIDRs); err != nil {
+		return nil, err
+	}
+	if g.denyNets, err = parsePrefixes(rules.DenyCIDRs); err != nil {
+		return nil, err
+	}
+	g.allowHosts = normalizeHosts(rules.AllowHosts)
+	g.denyHosts = normalizeHosts(rules.DenyHosts)
+	for _, port := range rules.DenyPorts {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("deny port %d is not between 1 and 65535", port)
+		}
+		g.denyPorts[port] = true
+	}
+	if base != nil {
+		g.denyNets = append(g.denyNets, base.denyNets...)
+		g.denyHosts = append(g.denyHosts, base.denyHosts...)
+		for port := range base.denyPorts {
+			g.denyPorts[port] = true
+		}
+		if len(g.allowNets) == 0 && len(g.allowHosts) == 0 {
+			g.allowNets, g.allowHosts = base.allowNets, base.allowHosts
+		}
+	}
+	return g, nil
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, c := range cidrs {
+		p, err := netip.ParsePrefix(c)
+		if err != nil {
+			// A single address stands for itself
+			addr, addrErr := netip.ParseAddr(c)
+			if addrErr != nil {
+				return nil, fmt.Errorf("%q is not a CIDR", c)
+			}
+			p = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		out = append(out, p.Masked())
+	}
+	return out, nil
+}
+
+func normalizeHosts(hosts []string) []string {
+	var out []string
+	for _, h := range hosts {
+		if h = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(h), ".")); h != "" {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+func (g *targetGuard) empty() bool {
+	return len(g.allowNets) == 0 && len(g.denyNets) == 0 && len(g.allowHosts) == 0 &&
+		len(g.denyHosts) == 0 && len(g.denyPorts) == 0
+}
+
+// permitName checks what is known of a target before it is resolved.
+func (g *targetGuard) permitName(host string, port int) error {
+	target := net.JoinHostPort(host, strconv.Itoa(port))
+	if g.denyPorts[port] {
+		return &PolicyError{Target: target, Reason: fmt.Sprintf("port %d is denied", port)}
+	}
+	if matchHost(g.denyHosts, host) {
+		return &PolicyError{Target: target, Reason: "host is denied"}
+	}
+	return nil
+}
+
+// permit checks a target by its name, the addresses it resolved to and its
+// port.
+func (g *targetGuard) permit(host string, addrs []netip.Addr, port int) error {
+	if err := g.permitName(host, port); err != nil {
+		return err
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(port))
+	for _, addr := range addrs {
+		if matchAddr(g.denyNets, addr) {
+			return &PolicyError{Target: target, Reason: fmt.Sprintf("address %s is denied", addr)}
+		}
+	}
+	if len(g.allowNets) == 0 && len(g.allowHosts) == 0 || matchHost(g.allowHosts, host) {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !matchAddr(g.allowNets, addr) {
+			return &PolicyError{Target: target, Reason: fmt.Sprintf("address %s is not allowed", addr)}
+		}
+	}
+	if len(addrs) == 0 {
+		return &PolicyError{Target: target, Reason: "host is not allowed"}
+	}
+	return nil
+}
+
+func matchHost(patterns []string, host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, p := range patterns {
+		if suffix, ok := strings.CutPrefix(p, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == p {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the addresses of host, itself when it is one.
+func resolve(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr.Unmap().WithZone("")}, nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for i := range addrs {
+		addrs[i] = addrs[i].Unmap()
+	}
+	return addrs, nil
+}
+
+// check resolves the host of address and checks it before a check connects.
+func (g *targetGuard) check(ctx context.Context, address string) error {
+	_, err := g.resolveAndPermit(ctx, address)
+	return err
+}
+
+func (g *targetGuard) resolveAndPermit(ctx context.Context, address string) ([]netip.Addr, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("port %q is not a number", portStr)
+	}
+	if err := g.permitName(host, port); err != nil {
+		return nil, err
+	}
+	addrs, err := resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	return addrs, g.permit(host, addrs, port)
+}
+
+// dialContext connects only to addresses the rules permit. It dials the
+// addresses it checked, so a name cannot resolve to another one in between.
+func (g *targetGuard) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	addrs, err := g.resolveAndPermit(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	_, port, _ := net.SplitHostPort(address)
+	var d net.Dialer
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := d.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// policyDenied returns the result of a check refused by the policy, ok is
+// false when err is no policy error.
+func policyDenied(err error) (Result, bool) {
+	var perr *PolicyError
+	if !errors.As(err, &perr) {
+		return Result{}, false
+	}
+	return Result{Message: perr.Error(), PolicyDenied: true}, true
+}
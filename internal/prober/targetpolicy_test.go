@@ -0,0 +1,132 @@
+package prober
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func setTestTargetPolicy(t *testing.T, policy TargetPolicy) {
+	t.Helper()
+	if err := SetTargetPolicy(policy); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = SetTargetPolicy(TargetPolicy{}) })
+}
+
+func TestTargetPolicyPermit(t *testing.T) {
+	setTestTargetPolicy(t, TargetPolicy{
+		TargetRules: TargetRules{
+			DenyCIDRs: []string{"169.254.0.0/16", "fd00:ec2::254"},
+			DenyHosts: []string{"*.internal"},
+			DenyPorts: []int{10250},
+		},
+		Namespaces: map[string]TargetRules{
+			"team-a": {AllowCIDRs: []string{"10.1.0.0/16"}, AllowHosts: []string{"*.team-a.svc.cluster.local"}},
+		},
+	})
+
+	tests := []struct {
+		namespace string
+		host      string
+		addrs     []string
+		port      int
+		denied    string
+	}{
+		{"", "169.254.169.254", []string{"169.254.169.254"}, 80, "address 169.254.169.254 is denied"},
+		{"", "metadata.google.internal", nil, 80, "host is denied"},
+		{"", "meta.example.com", []string{"10.0.0.1", "fd00:ec2::254"}, 80, "address fd00:ec2::254 is denied"},
+		{"", "10.0.0.7", []string{"10.0.0.7"}, 10250, "port 10250 is denied"},
+		{"", "example.com", []string{"93.184.216.34"}, 443, ""},
+		{"team-b", "example.com", []string{"93.184.216.34"}, 443, ""},
+		{"team-a", "example.com", []string{"93.184.216.34"}, 443, "address 93.184.216.34 is not allowed"},
+		{"team-a", "db.team-a.svc.cluster.local", []string{"10.96.0.12"}, 5432, ""},
+		{"team-a", "10.1.4.2", []string{"10.1.4.2"}, 8080, ""},
+		// Deny lists of all namespaces apply in team-a too
+		{"team-a", "kubelet.team-a.svc.cluster.local", []string{"10.96.0.13"}, 10250, "port 10250 is denied"},
+	}
+	for _, tt := range tests {
+		var addrs []netip.Addr
+		for _, a := range tt.addrs {
+			addrs = append(addrs, netip.MustParseAddr(a))
+		}
+		err := guardFor(tt.namespace).permit(tt.host, addrs, tt.port)
+		switch {
+		case tt.denied == "" && err != nil:
+			t.Errorf("%s %s: denied: %v", tt.namespace, tt.host, err)
+		case tt.denied != "" && (err == nil || !strings.HasSuffix(err.Error(), tt.denied)):
+			t.Errorf("%s %s: error = %v; want %q", tt.namespace, tt.host, err, tt.denied)
+		}
+	}
+
+	if err := SetTargetPolicy(TargetPolicy{TargetRules: TargetRules{DenyCIDRs: []string{"banana"}}}); err == nil {
+		t.Errorf("malformed CIDR accepted")
+	}
+}
+
+func TestTargetPolicyNoPolicy(t *testing.T) {
+	if g := guardFor("default"); g != nil {
+		t.Errorf("guard without a policy")
+	}
+}
+
+func TestHttpProberPolicy(t *testing.T) {
+	var hits atomic.Int32
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer allowed.Close()
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer denied.Close()
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, denied.URL, http.StatusFound)
+	}))
+	defer redirect.Close()
+
+	u, _ := url.Parse(denied.URL)
+	port, _ := strconv.Atoi(u.Port())
+	setTestTargetPolicy(t, TargetPolicy{
+		TargetRules: TargetRules{DenyPorts: []int{port}},
+		Namespaces:  map[string]TargetRules{"team-a": {DenyCIDRs: []string{"127.0.0.0/8", "::1/128"}}},
+	})
+
+	p, err := NewFor(ProbeInfo{Namespace: "default", Name: "api"}, "http", allowed.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := Run(p); !res.Healthy || res.PolicyDenied {
+		t.Errorf("allowed target: %+v", res)
+	}
+
+	p, _ = NewFor(ProbeInfo{Namespace: "default", Name: "api"}, "http", redirect.URL, nil)
+	if res := Run(p); res.Healthy || !res.PolicyDenied {
+		t.Errorf("redirect to a denied port: %+v; want PolicyDenied", res)
+	}
+
+	// localhost is no denied name, but resolves to a denied address
+	local := strings.Replace(allowed.URL, "127.0.0.1", "localhost", 1)
+	p, _ = NewFor(ProbeInfo{Namespace: "team-a", Name: "api"}, "http", local, nil)
+	if res := Run(p); res.Healthy || !res.PolicyDenied || !strings.Contains(res.Message, "is denied") {
+		t.Errorf("resolved address: %+v; want PolicyDenied", res)
+	}
+
+	if hits.Load() != 1 {
+		t.Errorf("servers hit %d times; want only the allowed check", hits.Load())
+	}
+
+	p, _ = NewFor(ProbeInfo{Namespace: "team-a", Name: "db"}, "tcp", u.Host, nil)
+	if res := Run(p); res.Healthy || !res.PolicyDenied {
+		t.Errorf("tcp to a denied address: %+v; want PolicyDenied", res)
+	}
+	p, _ = NewFor(ProbeInfo{Namespace: "default", Name: "db"}, "tcp", strings.TrimPrefix(allowed.URL, "http://"), nil)
+	if res := Run(p); !res.Healthy {
+		t.Errorf("tcp to an allowed address: %+v", res)
+	}
+}
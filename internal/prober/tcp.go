@@ -1,13 +1,65 @@
 package prober
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net"
+	"strconv"
 	"time"
 )
 
+func init() {
+	Register("tcp", Type{
+		Decode: decodeEndpointOptions,
+		Validate: func(target string, options interface{}) []string {
+			if problems := validateHostPort(target); problems != nil {
+				return problems
+			}
+			if fanOutOf(options) != nil {
+				if _, err := NewTcpEndpointProber(nil, target, fanOutOf(options)); err != nil {
+					return []string{err.Error()}
+				}
+			}
+			return nil
+		},
+		New: func(target string, options interface{}) (Prober, error) {
+			return newTcpFor(ProbeInfo{}, target, options)
+		},
+		NewFor: newTcpFor,
+	})
+}
+
+// newTcpFor builds a tcp check held to the target policy of the namespace
+// of probe.
+func newTcpFor(probe ProbeInfo, target string, options interface{}) (Prober, error) {
+	guard := guardFor(probe.Namespace)
+	if f := fanOutOf(options); f != nil {
+		client := kubernetesClient()
+		if client == nil {
+			return nil, errors.New("probing the endpoints of a Service needs access to the Kubernetes API")
+		}
+		p, err := NewTcpEndpointProber(client, target, f)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.service.checkNamespace(probe); err != nil {
+			return nil, err
+		}
+		p.guard = guard
+		p.Timeout = probe.Timeout
+		return p, nil
+	}
+	return &TcpProber{Address: target, Timeout: probe.Timeout, guard: guard}, nil
+}
+
 type TcpProber struct {
 	Address string
+	// Timeout bounds the connection, DefaultTimeout when zero.
+	Timeout time.Duration
+
+	// guard enforces the target policy, nil without one.
+	guard *targetGuard
 }
 
 func NewTcpProber(addr string) *TcpProber {
@@ -15,13 +67,46 @@ func NewTcpProber(addr string) *TcpProber {
 }
 
 func (p *TcpProber) Check() bool {
-	conn, err := net.DialTimeout("tcp", p.Address, 2*time.Second)
+	return p.CheckResult().Healthy
+}
+
+func (p *TcpProber) CheckResult() Result {
+	return p.CheckContext(context.Background())
+}
+
+func (p *TcpProber) CheckContext(ctx context.Context) Result {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout(p.Timeout))
+	defer cancel()
+	var conn net.Conn
+	var err error
+	if p.guard != nil {
+		conn, err = p.guard.dialContext(ctx, "tcp", p.Address)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", p.Address)
+	}
 	if err != nil {
+		if res, denied := policyDenied(err); denied {
+			log.Printf("[TCP] %s: %v", p.Address, err)
+			return res
+		}
 		log.Printf("[TCP] Connection to %s failed: %v", p.Address, err)
-		return false
+		return Result{}
+	}
+	conn.Close()
+	return Result{Healthy: true}
+}
+
+func validateHostPort(s string) []string {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return []string{"must be host:port"}
+	}
+	if host == "" {
+		return []string{"must include a host"}
 	}
-	if conn != nil {
-		conn.Close()
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return []string{"port must be between 1 and 65535"}
 	}
-	return true
+	return nil
 }
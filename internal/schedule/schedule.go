@@ -0,0 +1,260 @@
+// Package schedule parses cron expressions as Kubernetes CronJobs accept
+// them and finds their activation times.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record an unrestricted field: cron matches a day
+	// when both day fields match, or either one when both are restricted.
+	domStar, dowStar bool
+	loc              *time.Location
+}
+
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = bounds{0, 59, nil}
+	hours   = bounds{0, 23, nil}
+	doms    = bounds{1, 31, nil}
+	months  = bounds{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is Sunday as well, folded into 0 after parsing
+	dows = bounds{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a five field cron expression ("minute hour day-of-month
+// month day-of-week") or a macro such as @daily. A "CRON_TZ=<zone>" or
+// "TZ=<zone>" prefix sets the time zone, which is UTC otherwise.
+func Parse(expr string) (*Schedule, error) {
+	return ParseInLocation(expr, time.UTC)
+}
+
+// ParseInLocation is Parse with loc as the time zone when the expression
+// does not name one.
+func ParseInLocation(expr string, loc *time.Location) (*Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if rest, ok := strings.CutPrefix(expr, prefix); ok {
+			zone, fields, _ := strings.Cut(rest, " ")
+			l, err := time.LoadLocation(zone)
+			if err != nil {
+				return nil, fmt.Errorf("unknown time zone %q", zone)
+			}
+			loc, expr = l, strings.TrimSpace(fields)
+			break
+		}
+	}
+	if m, ok := macros[expr]; ok {
+		expr = m
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, found %d in %q", len(fields), expr)
+	}
+	s := &Schedule{loc: loc}
+	var err error
+	if s.minute, err = parseField(fields[0], minutes); err != nil {
+		return nil, fmt.Errorf("minute: %v", err)
+	}
+	if s.hour, err = parseField(fields[1], hours); err != nil {
+		return nil, fmt.Errorf("hour: %v", err)
+	}
+	if s.dom, err = parseField(fields[2], doms); err != nil {
+		return nil, fmt.Errorf("day of month: %v", err)
+	}
+	if s.month, err = parseField(fields[3], months); err != nil {
+		return nil, fmt.Errorf("month: %v", err)
+	}
+	if s.dow, err = parseField(fields[4], dows); err != nil {
+		return nil, fmt.Errorf("day of week: %v", err)
+	}
+	if has(s.dow, 7) {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domStar = fields[2] == "*" || fields[2] == "?"
+	s.dowStar = fields[4] == "*" || fields[4] == "?"
+	return s, nil
+}
+
+// parseField parses a comma separated list of "*", "n", "a-b", each
+// optionally followed by "/step", into a bit set.
+func parseField(field string, b bounds) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+			step = n
+		}
+
+		lo, hi := b.min, b.max
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			a, z, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = value(a, b); err != nil {
+				return 0, err
+			}
+			if hi, err = value(z, b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("range %q ends before it starts", rng)
+			}
+		default:
+			v, err := value(rng, b)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if !hasStep {
+				hi = v
+			}
+		}
+		for i := lo; i <= hi; i += step {
+			set |= 1 << uint(i)
+		}
+	}
+	return set, nil
+}
+
+func value(s string, b bounds) (int, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("%d is out of range %d-%d", v, b.min, b.max)
+	}
+	return v, nil
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// searchLimit bounds the search for an activation, an expression such as
+// "0 0 30 2 *" never fires.
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+// Next returns the first activation after t, or the zero time when there is
+// none within five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	orig := t
+	t = t.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+	for t.Sub(orig) < searchLimit {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Prev returns the last activation at or before t, or the zero time when
+// there is none within five years.
+func (s *Schedule) Prev(t time.Time) time.Time {
+	orig := t
+	t = t.In(s.loc).Truncate(time.Minute)
+	for orig.Sub(t) < searchLimit {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, s.loc).Add(-time.Minute)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.loc).Add(-time.Minute)
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, s.loc).Add(-time.Minute)
+		case !has(s.minute, t.Minute()):
+			t = t.Add(-time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Set is a union of schedules, active whenever one of them is.
+type Set []*Schedule
+
+// ParseSet parses cron expressions separated by ";", e.g. a frequent one
+// for business hours and a sparse one for the night, as ParseInLocation
+// does.
+func ParseSet(expr string, loc *time.Location) (Set, error) {
+	var set Set
+	for _, e := range strings.Split(expr, ";") {
+		if strings.TrimSpace(e) == "" {
+			continue
+		}
+		s, err := ParseInLocation(e, loc)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, s)
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("empty schedule")
+	}
+	return set, nil
+}
+
+// Next returns the first activation of any schedule of the set after t,
+// or the zero time when there is none within five years.
+func (set Set) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, s := range set {
+		if n := s.Next(t); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
@@ -0,0 +1,111 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	valid := []string{
+		"*/5 * * * *", "0 2 * * *", "@daily", "30 4 1,15 * mon-fri",
+		"0 0 * JAN-MAR sun", "0 12 * * 1-7", "CRON_TZ=Europe/Berlin 0 3 * * *", "TZ=UTC @hourly",
+	}
+	for _, expr := range valid {
+		if _, err := Parse(expr); err != nil {
+			t.Errorf("Parse(%q) = %v", expr, err)
+		}
+	}
+	invalid := []string{
+		"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "5-1 * * * *",
+		"*/0 * * * *", "0 0 * foo *", "@every 5m", "TZ=Mars/Olympus 0 0 * * *",
+	}
+	for _, expr := range invalid {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded; want an error", expr)
+		}
+	}
+}
+
+func TestNextPrev(t *testing.T) {
+	at := time.Date(2024, 3, 15, 10, 7, 30, 0, time.UTC) // a Friday
+	tests := []struct {
+		expr       string
+		prev, next time.Time
+	}{
+		{"*/5 * * * *", time.Date(2024, 3, 15, 10, 5, 0, 0, time.UTC), time.Date(2024, 3, 15, 10, 10, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2024, 3, 15, 2, 0, 0, 0, time.UTC), time.Date(2024, 3, 16, 2, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 18, 9, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 0 13 * 5", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 22, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", tt.expr, err)
+		}
+		if got := s.Prev(at); !got.Equal(tt.prev) {
+			t.Errorf("%q: Prev = %s; want %s", tt.expr, got, tt.prev)
+		}
+		if got := s.Next(at); !got.Equal(tt.next) {
+			t.Errorf("%q: Next = %s; want %s", tt.expr, got, tt.next)
+		}
+	}
+
+	never, _ := Parse("0 0 30 2 *")
+	if !never.Next(at).IsZero() || !never.Prev(at).IsZero() {
+		t.Errorf("an expression that never fires returned an activation")
+	}
+}
+
+func TestTimeZone(t *testing.T) {
+	s, err := Parse("CRON_TZ=Europe/Berlin 0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	if got, want := s.Prev(at), time.Date(2024, 1, 10, 2, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Prev = %s; want %s", got, want)
+	}
+	india, err := Parse("CRON_TZ=Asia/Kolkata 0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := india.Prev(at), time.Date(2024, 1, 10, 11, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Prev in a half hour zone = %s; want %s", got, want)
+	}
+	// Prev of an activation is the activation itself
+	if got := s.Prev(s.Next(at)); !got.Equal(s.Next(at)) {
+		t.Errorf("Prev(Next) = %s; want %s", got, s.Next(at))
+	}
+}
+
+func TestSet(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every 5 minutes during business hours, hourly otherwise
+	set, err := ParseSet("*/5 9-17 * * mon-fri; 0 * * * *", berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ at, next time.Time }{
+		{time.Date(2024, 3, 15, 10, 7, 0, 0, berlin), time.Date(2024, 3, 15, 10, 10, 0, 0, berlin)},
+		{time.Date(2024, 3, 15, 22, 7, 0, 0, berlin), time.Date(2024, 3, 15, 23, 0, 0, 0, berlin)},
+		{time.Date(2024, 3, 16, 10, 7, 0, 0, berlin), time.Date(2024, 3, 16, 11, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		if got := set.Next(tt.at); !got.Equal(tt.next) {
+			t.Errorf("Next(%s) = %s; want %s", tt.at, got, tt.next)
+		}
+	}
+
+	for _, expr := range []string{"", " ; ", "@daily; 61 * * * *"} {
+		if _, err := ParseSet(expr, time.UTC); err == nil {
+			t.Errorf("ParseSet(%q) succeeded; want an error", expr)
+		}
+	}
+}
@@ -16,10 +16,47 @@ type GateStatus struct {
 	LastCheck string
 	CheckType string
 	Message   string
+
+	// Key, DependsOn and SuppressedBy place the gate in the dependency tree.
+	Key          string
+	DependsOn    []string
+	SuppressedBy string
+
+	// Endpoints are set when the gate probes every endpoint of a Service.
+	Endpoints []EndpointStatus
+}
+
+type EndpointStatus struct {
+	Address   string
+	IsHealthy bool
+	Message   string
+}
+
+// DependencyNode is a gate in the dependency tree, with the gates that depend on it.
+type DependencyNode struct {
+	Gate     GateStatus
+	Children []DependencyNode
+}
+
+// GroupStatus is the UI card of a ProbeGroup.
+type GroupStatus struct {
+	Name      string
+	Namespace string
+	Policy    string
+	IsHealthy bool
+	Members   []GroupMember
+	LastCheck string
+	Message   string
+}
+
+type GroupMember struct {
+	Name      string
+	IsHealthy bool
 }
 
 var (
 	stateStore = make(map[string]GateStatus)
+	groupStore = make(map[string]GroupStatus)
 	mu         sync.RWMutex
 )
 
@@ -32,13 +69,111 @@ func UpdateState(ruleName, target, checkType string, healthy bool) {
 		msg = "Gate Open"
 	}
 
+	prev := stateStore[ruleName]
 	stateStore[ruleName] = GateStatus{
-		Name:      ruleName,
-		Target:    target,
-		CheckType: checkType,
+		Name:         ruleName,
+		Target:       target,
+		CheckType:    checkType,
+		IsHealthy:    healthy,
+		LastCheck:    time.Now().Format("15:04:05"),
+		Message:      msg,
+		Key:          prev.Key,
+		DependsOn:    prev.DependsOn,
+		SuppressedBy: prev.SuppressedBy,
+		Endpoints:    prev.Endpoints,
+	}
+}
+
+// UpdateEndpoints records the endpoint results of a gate.
+func UpdateEndpoints(ruleName string, endpoints []EndpointStatus) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s := stateStore[ruleName]
+	s.Name = ruleName
+	s.Endpoints = endpoints
+	stateStore[ruleName] = s
+}
+
+// UpdateDependencies records the dependencies of a gate, and the failing
+// gate it is suppressed by, if any. key and dependsOn are rule keys.
+func UpdateDependencies(ruleName, key string, dependsOn []string, suppressedBy string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s := stateStore[ruleName]
+	s.Name = ruleName
+	s.Key = key
+	s.DependsOn = dependsOn
+	s.SuppressedBy = suppressedBy
+	if suppressedBy != "" {
+		s.Message = "Suppressed by " + suppressedBy
+	}
+	stateStore[ruleName] = s
+}
+
+// dependencyTree arranges the gates that take part in a dependency as trees
+// rooted at the gates that depend on nothing known. A cycle is cut where it
+// revisits a gate.
+func dependencyTree(gates []GateStatus) []DependencyNode {
+	byKey := make(map[string]GateStatus, len(gates))
+	for _, g := range gates {
+		if g.Key != "" {
+			byKey[g.Key] = g
+		}
+	}
+	children := make(map[string][]GateStatus)
+	for _, g := range gates {
+		for _, dep := range g.DependsOn {
+			if _, ok := byKey[dep]; ok {
+				children[dep] = append(children[dep], g)
+			}
+		}
+	}
+
+	var build func(g GateStatus, path map[string]bool) DependencyNode
+	build = func(g GateStatus, path map[string]bool) DependencyNode {
+		node := DependencyNode{Gate: g}
+		path[g.Key] = true
+		for _, c := range children[g.Key] {
+			if !path[c.Key] {
+				node.Children = append(node.Children, build(c, path))
+			}
+		}
+		delete(path, g.Key)
+		return node
+	}
+
+	var roots []DependencyNode
+	for _, g := range gates {
+		if len(children[g.Key]) == 0 {
+			continue
+		}
+		isRoot := true
+		for _, dep := range g.DependsOn {
+			if _, ok := byKey[dep]; ok {
+				isRoot = false
+			}
+		}
+		if isRoot {
+			roots = append(roots, build(g, map[string]bool{}))
+		}
+	}
+	return roots
+}
+
+func UpdateGroupState(namespace, name, policy string, healthy bool, members []GroupMember, message string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	groupStore[namespace+"/"+name] = GroupStatus{
+		Name:      name,
+		Namespace: namespace,
+		Policy:    policy,
 		IsHealthy: healthy,
+		Members:   members,
 		LastCheck: time.Now().Format("15:04:05"),
-		Message:   msg,
+		Message:   message,
 	}
 }
 
@@ -59,16 +194,56 @@ const htmlTmpl = `
         .red { background-color: #e74c3c; }
         .meta { font-size: 13px; color: #666; line-height: 1.6; }
         code { background: #eee; padding: 2px 4px; border-radius: 3px; }
+        .members { list-style: none; padding: 0; margin: 8px 0 0; }
+        .dot { display: inline-block; width: 8px; height: 8px; border-radius: 50%; margin-right: 6px; }
+        .grey { background-color: #95a5a6; }
+        .tree ul { list-style: none; padding-left: 20px; border-left: 1px dashed #ccc; }
+        .tree > ul { border-left: none; padding-left: 0; }
     </style>
 </head>
 <body>
+    {{if .Groups}}
+    <h1>Probe Groups</h1>
+    <div class="grid">
+        {{range .Groups}}
+        <div class="card">
+            <div class="header">
+                <strong>{{.Namespace}}/{{.Name}}</strong>
+                {{if .IsHealthy}}
+                    <span class="status-badge green">HEALTHY</span>
+                {{else}}
+                    <span class="status-badge red">FAILING</span>
+                {{end}}
+            </div>
+            <div class="meta">
+                Policy: {{.Policy}}<br>
+                Last Check: {{.LastCheck}}<br>
+                Status: {{.Message}}
+                <ul class="members">
+                    {{range .Members}}
+                    <li><span class="dot {{if .IsHealthy}}green{{else}}red{{end}}"></span>{{.Name}}</li>
+                    {{end}}
+                </ul>
+            </div>
+        </div>
+        {{end}}
+    </div>
+    {{end}}
+    {{if .Tree}}
+    <h1>Dependencies</h1>
+    <div class="card tree">
+        <ul>{{range .Tree}}{{template "node" .}}{{end}}</ul>
+    </div>
+    {{end}}
     <h1>Active Gates</h1>
     <div class="grid">
-        {{range .}}
+        {{range .Gates}}
         <div class="card">
             <div class="header">
                 <strong>{{.Name}}</strong>
-                {{if .IsHealthy}}
+                {{if .SuppressedBy}}
+                    <span class="status-badge grey">SUPPRESSED</span>
+                {{else if .IsHealthy}}
                     <span class="status-badge green">HEALTHY</span>
                 {{else}}
                     <span class="status-badge red">FAILING</span>
@@ -78,12 +253,25 @@ const htmlTmpl = `
                 Target: <code>{{.Target}}</code> ({{.CheckType}})<br>
                 Last Check: {{.LastCheck}}<br>
                 Status: {{.Message}}
+                {{if .Endpoints}}
+                <ul class="members">
+                    {{range .Endpoints}}
+                    <li title="{{.Message}}"><span class="dot {{if .IsHealthy}}green{{else}}red{{end}}"></span>{{.Address}}</li>
+                    {{end}}
+                </ul>
+                {{end}}
             </div>
         </div>
         {{end}}
     </div>
 </body>
 </html>
+{{define "node"}}
+<li>
+    <span class="dot {{if .Gate.SuppressedBy}}grey{{else if .Gate.IsHealthy}}green{{else}}red{{end}}"></span>{{.Gate.Name}}
+    {{if .Children}}<ul>{{range .Children}}{{template "node" .}}{{end}}</ul>{{end}}
+</li>
+{{end}}
 `
 
 var tmpl = template.Must(template.New("webpage").Parse(htmlTmpl))
@@ -94,19 +282,43 @@ func handler(w http.ResponseWriter, r *http.Request) {
 	for _, v := range stateStore {
 		list = append(list, v)
 	}
+	var groups []GroupStatus
+	for _, v := range groupStore {
+		groups = append(groups, v)
+	}
 	mu.RUnlock()
 
 	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Namespace+"/"+groups[i].Name < groups[j].Namespace+"/"+groups[j].Name
+	})
+
+	data := struct {
+		Gates  []GateStatus
+		Groups []GroupStatus
+		Tree   []DependencyNode
+	}{list, groups, dependencyTree(list)}
 
-	if err := tmpl.Execute(w, list); err != nil {
+	if err := tmpl.Execute(w, data); err != nil {
 		log.Printf("Error rendering UI template: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
 
+var extraHandlers = map[string]http.Handler{}
+
+// Handle serves pattern next to the UI, e.g. the heartbeat ping endpoint.
+// It must be called before Start.
+func Handle(pattern string, h http.Handler) {
+	extraHandlers[pattern] = h
+}
+
 func Start(port string) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", handler)
+	for pattern, h := range extraHandlers {
+		mux.Handle(pattern, h)
+	}
 	server := &http.Server{
 		Addr:    ":" + port,
 		Handler: mux,
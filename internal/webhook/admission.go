@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/api/v1beta1"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+type admitFunc func(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse
+
+// serveAdmission decodes an AdmissionReview, passes its request to admit and
+// writes the response back.
+func serveAdmission(w http.ResponseWriter, r *http.Request, admit admitFunc) {
+	review := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+		http.Error(w, "malformed AdmissionReview", http.StatusBadRequest)
+		return
+	}
+
+	resp := admit(review.Request)
+	resp.UID = review.Request.UID
+	review.Response = resp
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		log.Printf("Failed to write admission response: %v", err)
+	}
+}
+
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	serveAdmission(w, r, s.validate)
+}
+
+func (s *Server) handleMutate(w http.ResponseWriter, r *http.Request) {
+	serveAdmission(w, r, s.mutate)
+}
+
+func (s *Server) validate(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	var errs field.ErrorList
+	var deps []string
+	switch req.Kind.Version {
+	case v1alpha1.SchemeGroupVersion.Version:
+		p := &v1alpha1.Probe{}
+		if err := json.Unmarshal(req.Object.Raw, p); err != nil {
+			return deny(err)
+		}
+		errs = validateProbeV1alpha1(p, s.defaults)
+		errs = append(errs, validateExecNamespace(req.Namespace, execInOperator(p), field.NewPath("spec", "checkType"))...)
+		deps = p.Spec.DependsOn
+	case v1beta1.SchemeGroupVersion.Version:
+		p := &v1beta1.Probe{}
+		if err := json.Unmarshal(req.Object.Raw, p); err != nil {
+			return deny(err)
+		}
+		errs = validateProbeV1beta1(p, s.defaults)
+		inOperator := p.Spec.Exec != nil && p.Spec.Exec.Pod == nil && p.Spec.Exec.Job == nil
+		errs = append(errs, validateExecNamespace(req.Namespace, inOperator, field.NewPath("spec", "exec"))...)
+		deps = p.Spec.DependsOn
+	default:
+		return deny(fmt.Errorf("unsupported version %s", req.Kind.Version))
+	}
+	errs = append(errs, s.validateDependencies(req, deps, field.NewPath("spec", "dependsOn"))...)
+
+	if len(errs) == 0 {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+	gk := schema.GroupKind{Group: req.Kind.Group, Kind: req.Kind.Kind}
+	status := apierrors.NewInvalid(gk, req.Name, errs).ErrStatus
+	return &admissionv1.AdmissionResponse{Allowed: false, Result: &status}
+}
+
+func (s *Server) mutate(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	var ops []patchOp
+	switch req.Kind.Version {
+	case v1alpha1.SchemeGroupVersion.Version:
+		p := &v1alpha1.Probe{}
+		if err := json.Unmarshal(req.Object.Raw, p); err != nil {
+			return deny(err)
+		}
+		ops = defaultProbeV1alpha1(p, s.defaults)
+	case v1beta1.SchemeGroupVersion.Version:
+		p := &v1beta1.Probe{}
+		if err := json.Unmarshal(req.Object.Raw, p); err != nil {
+			return deny(err)
+		}
+		ops = defaultProbeV1beta1(p, s.defaults)
+	default:
+		return deny(fmt.Errorf("unsupported version %s", req.Kind.Version))
+	}
+
+	resp := &admissionv1.AdmissionResponse{Allowed: true}
+	if len(ops) == 0 {
+		return resp
+	}
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return deny(err)
+	}
+	patchType := admissionv1.PatchTypeJSONPatch
+	resp.Patch = patch
+	resp.PatchType = &patchType
+	return resp
+}
+
+func deny(err error) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusBadRequest,
+			Reason:  metav1.StatusReasonBadRequest,
+			Message: err.Error(),
+		},
+	}
+}
@@ -0,0 +1,392 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/internal/config"
+	"heartbeat-operator/internal/prober"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var testDefaults = config.Defaults{
+	Interval:         30 * time.Second,
+	Timeout:          2 * time.Second,
+	SuccessThreshold: 1,
+	FailureThreshold: 3,
+	MinInterval:      time.Second,
+}
+
+func init() {
+	// Stands in for a check type served by a plugin
+	prober.Register("test-plugin", prober.Type{
+		Decode: func(options json.RawMessage) (interface{}, error) { return options, nil },
+		New:    func(string, interface{}) (prober.Prober, error) { return nil, nil },
+	})
+}
+
+func admissionRequest(version, object string) *admissionv1.AdmissionRequest {
+	return &admissionv1.AdmissionRequest{
+		UID:       "uid",
+		Kind:      metav1.GroupVersionKind{Group: "probes.ready.io", Version: version, Kind: "Probe"},
+		Name:      "p",
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: []byte(object)},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	stored := map[string][]string{
+		"Probe//q": {"Probe//p"},
+	}
+	s := NewServer(testDefaults, func(_ context.Context, key string) ([]string, error) {
+		return stored[key], nil
+	})
+	tests := []struct {
+		name    string
+		version string
+		object  string
+		fields  []string // expected field paths of the causes, empty when allowed
+	}{
+		{
+			name:    "valid v1alpha1",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"tcp","checkTarget":"postgres:5432","interval":"10s","timeout":"2s"}}`,
+		},
+		{
+			name:    "malformed interval",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"http","checkTarget":"https://example.com","interval":"banana"}}`,
+			fields:  []string{"spec.interval"},
+		},
+		{
+			name:    "tcp target without port",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"tcp","checkTarget":"postgres","interval":"10s"}}`,
+			fields:  []string{"spec.checkTarget"},
+		},
+		{
+			name:    "timeout above interval and interval below minimum",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"http","checkTarget":"https://example.com","interval":"500ms","timeout":"1s"}}`,
+			fields:  []string{"spec.interval", "spec.timeout"},
+		},
+		{
+			name:    "cron schedule instead of interval",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"http","checkTarget":"https://example.com","schedule":"*/5 9-17 * * mon-fri; 0 * * * *","timeZone":"Europe/Berlin"}}`,
+		},
+		{
+			name:    "malformed schedule and time zone",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"http","checkTarget":"https://example.com","schedule":"0 25 * * *","timeZone":"Mars/Olympus"}}`,
+			fields:  []string{"spec.timeZone", "spec.schedule"},
+		},
+		{
+			name:    "v1beta1 schedule that never fires",
+			version: "v1beta1",
+			object:  `{"spec":{"http":{"url":"https://example.com"},"schedule":"0 0 30 2 *"}}`,
+			fields:  []string{"spec.schedule"},
+		},
+		{
+			name:    "retries with exponential backoff",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"tcp","checkTarget":"vpn:443","interval":"30s","timeout":"5s","retries":{"count":3,"delay":"200ms","backoff":"Exponential","maxDelay":"1s"}}}`,
+		},
+		{
+			name:    "retries without time to run",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"tcp","checkTarget":"vpn:443","interval":"30s","timeout":"1s","retries":{"count":20,"backoff":"Linear"}}}`,
+			fields:  []string{"spec.retries.count", "spec.retries.backoff", "spec.retries.delay"},
+		},
+		{
+			name:    "v1beta1 retry delay above the interval",
+			version: "v1beta1",
+			object:  `{"spec":{"tcp":{"host":"vpn","port":443},"interval":"5s","retries":{"count":2,"delay":"10s"}}}`,
+			fields:  []string{"spec.retries.delay"},
+		},
+		{
+			name:    "valid v1beta1",
+			version: "v1beta1",
+			object:  `{"spec":{"http":{"url":"https://example.com","method":"HEAD"},"interval":"30s","timeout":"5s"}}`,
+		},
+		{
+			name:    "v1beta1 with two checks and a bad scheme",
+			version: "v1beta1",
+			object:  `{"spec":{"http":{"url":"ftp://example.com"},"dns":{"name":"example.com"},"interval":"30s"}}`,
+			fields:  []string{"spec.http.url", "spec"},
+		},
+		{
+			name:    "v1beta1 tcp port out of range",
+			version: "v1beta1",
+			object:  `{"spec":{"tcp":{"host":"redis","port":70000},"interval":"30s"}}`,
+			fields:  []string{"spec.tcp.port"},
+		},
+		{
+			name:    "v1beta1 endpoints of a host that is no Service",
+			version: "v1beta1",
+			object:  `{"spec":{"http":{"url":"https://example.com"},"endpoints":{"policy":"Any"},"interval":"30s"}}`,
+			fields:  []string{"spec.endpoints"},
+		},
+		{
+			name:    "v1beta1 exec pod without name or selector",
+			version: "v1beta1",
+			object:  `{"spec":{"exec":{"command":["true"],"pod":{"container":"app"}},"interval":"30s"}}`,
+			fields:  []string{"spec.exec"},
+		},
+		{
+			name:    "v1beta1 kubernetes object",
+			version: "v1beta1",
+			object:  `{"spec":{"kubernetes":{"resource":"deployments.v1.apps","name":"api","condition":"Available","fields":["status.readyReplicas >= 2"]},"interval":"30s"}}`,
+		},
+		{
+			name:    "v1beta1 kubernetes with name and selector",
+			version: "v1beta1",
+			object:  `{"spec":{"kubernetes":{"resource":"deployments.v1.apps","name":"api","labelSelector":"app=shop"},"interval":"30s"}}`,
+			fields:  []string{"spec.kubernetes"},
+		},
+		{
+			name:    "v1beta1 heartbeat with a malformed Secret name",
+			version: "v1beta1",
+			object:  `{"spec":{"heartbeat":{"secretName":"Nightly_Backup","grace":"-1m"},"interval":"24h"}}`,
+			fields:  []string{"spec.heartbeat.grace", "spec.heartbeat.secretName"},
+		},
+		{
+			name:    "scheduled heartbeat without grace",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"heartbeat","checkTarget":"nightly-backup","schedule":"0 3 * * *"}}`,
+			fields:  []string{"spec.options.grace"},
+		},
+		{
+			name:    "v1beta1 scheduled heartbeat without grace",
+			version: "v1beta1",
+			object:  `{"spec":{"heartbeat":{"secretName":"nightly-backup"},"schedule":"0 3 * * *"}}`,
+			fields:  []string{"spec.heartbeat.grace"},
+		},
+		{
+			name:    "scheduled heartbeat",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"heartbeat","checkTarget":"nightly-backup","schedule":"0 3 * * *","options":{"grace":"25h"}}}`,
+		},
+		{
+			name:    "v1beta1 cronjob in another namespace",
+			version: "v1beta1",
+			object:  `{"spec":{"cronJob":{"namespace":"ops","name":"nightly-backup","maxDuration":"0s"},"interval":"5m"}}`,
+			fields:  []string{"spec.cronJob.maxDuration"},
+		},
+		{
+			name:    "v1beta1 plugin member for a check type without its own",
+			version: "v1beta1",
+			object:  `{"spec":{"plugin":{"type":"test-plugin","target":"PRD","options":{"client":100}},"interval":"30s"}}`,
+		},
+		{
+			name:    "v1beta1 plugin member for a check type with its own",
+			version: "v1beta1",
+			object:  `{"spec":{"plugin":{"type":"kubernetes","target":"deployments.v1.apps/api"},"interval":"30s"}}`,
+			fields:  []string{"spec.plugin.type"},
+		},
+		{
+			name:    "v1beta1 plugin member for an unknown check type",
+			version: "v1beta1",
+			object:  `{"spec":{"plugin":{"type":"sap-rfc","target":"PRD"},"interval":"30s"}}`,
+			fields:  []string{"spec.plugin.type"},
+		},
+		{
+			name:    "dependsOn a ClusterProbe",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"tcp","checkTarget":"postgres:5432","interval":"10s","dependsOn":["ClusterProbe/egress"]}}`,
+		},
+		{
+			name:    "dependsOn itself",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"tcp","checkTarget":"postgres:5432","interval":"10s","dependsOn":["p"]}}`,
+			fields:  []string{"spec.dependsOn"},
+		},
+		{
+			name:    "dependsOn a probe depending on it",
+			version: "v1beta1",
+			object:  `{"spec":{"tcp":{"host":"redis","port":6379},"interval":"30s","dependsOn":["q"]}}`,
+			fields:  []string{"spec.dependsOn"},
+		},
+		{
+			name:    "dependsOn malformed name",
+			version: "v1alpha1",
+			object:  `{"spec":{"checkType":"tcp","checkTarget":"postgres:5432","interval":"10s","dependsOn":["Not_A_Name"]}}`,
+			fields:  []string{"spec.dependsOn[0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.validate(admissionRequest(tt.version, tt.object))
+			if len(tt.fields) == 0 {
+				if !resp.Allowed {
+					t.Fatalf("expected allowed, got denied: %s", resp.Result.Message)
+				}
+				return
+			}
+			if resp.Allowed {
+				t.Fatalf("expected denied, got allowed")
+			}
+			var got []string
+			for _, c := range resp.Result.Details.Causes {
+				got = append(got, c.Field)
+			}
+			if len(got) != len(tt.fields) {
+				t.Fatalf("causes = %v; want fields %v", got, tt.fields)
+			}
+			for i := range got {
+				if got[i] != tt.fields[i] {
+					t.Errorf("cause %d field = %q; want %q", i, got[i], tt.fields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMutate(t *testing.T) {
+	s := NewServer(testDefaults, nil)
+	for _, tt := range []struct {
+		version, object string
+	}{
+		{"v1beta1", `{"spec":{"dns":{"name":"example.com"},"interval":"1m","successThreshold":2}}`},
+		{"v1alpha1", `{"spec":{"checkType":"dns","checkTarget":"example.com","interval":"1m","successThreshold":2}}`},
+	} {
+		review := admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request:  admissionRequest(tt.version, tt.object),
+		}
+		body, _ := json.Marshal(review)
+
+		rec := httptest.NewRecorder()
+		s.handleMutate(rec, httptest.NewRequest(http.MethodPost, "/mutate-probe", bytes.NewReader(body)))
+
+		var got admissionv1.AdmissionReview
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tt.version, err)
+		}
+		if got.Response == nil || !got.Response.Allowed || got.Response.UID != "uid" {
+			t.Fatalf("%s: unexpected response: %+v", tt.version, got.Response)
+		}
+
+		var ops []patchOp
+		if err := json.Unmarshal(got.Response.Patch, &ops); err != nil {
+			t.Fatalf("%s: failed to decode patch: %v", tt.version, err)
+		}
+		want := map[string]interface{}{
+			"/spec/timeout":          "2s",
+			"/spec/failureThreshold": float64(3),
+		}
+		if len(ops) != len(want) {
+			t.Fatalf("%s: patch = %+v; want %d operations", tt.version, ops, len(want))
+		}
+		for _, op := range ops {
+			if v, ok := want[op.Path]; !ok || v != op.Value {
+				t.Errorf("%s: unexpected patch operation %+v", tt.version, op)
+			}
+		}
+	}
+}
+
+func TestDefaultJobTimeout(t *testing.T) {
+	job := &v1alpha1.Probe{Spec: v1alpha1.ProbeSpec{
+		CheckType:        "exec",
+		CheckTarget:      "pg_isready",
+		Interval:         "1m",
+		SuccessThreshold: 1,
+		FailureThreshold: 3,
+		Options:          &runtime.RawExtension{Raw: []byte(`{"job":{"image":"postgres:16"}}`)},
+	}}
+	if ops := defaultProbeV1alpha1(job, testDefaults); len(ops) != 0 {
+		t.Errorf("patch = %+v; want no timeout for an exec check in a Job", ops)
+	}
+	job.Spec.Options = nil
+	if ops := defaultProbeV1alpha1(job, testDefaults); len(ops) != 1 {
+		t.Errorf("patch = %+v; want the default timeout", ops)
+	}
+}
+
+func TestValidateExecPolicy(t *testing.T) {
+	if err := prober.SetExecPolicy(prober.ExecPolicy{
+		AllowedCommands: []string{"/usr/bin/pg_isready", "/opt/checks/"},
+		Namespaces:      []string{"ops"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer prober.SetExecPolicy(prober.ExecPolicy{})
+
+	s := NewServer(testDefaults, nil)
+	tests := []struct {
+		name      string
+		version   string
+		namespace string
+		object    string
+		fields    []string
+	}{
+		{
+			name:      "allowed script",
+			version:   "v1alpha1",
+			namespace: "ops",
+			object:    `{"spec":{"checkType":"exec","checkTarget":"/opt/checks/db.sh","interval":"10s"}}`,
+		},
+		{
+			name:      "command not allowed",
+			version:   "v1beta1",
+			namespace: "ops",
+			object:    `{"spec":{"exec":{"command":["/bin/sh","-c","true"]},"interval":"30s"}}`,
+			fields:    []string{"spec.exec.command"},
+		},
+		{
+			name:      "namespace not enabled",
+			version:   "v1alpha1",
+			namespace: "team-a",
+			object:    `{"spec":{"checkType":"exec","checkTarget":"/opt/checks/db.sh","interval":"10s"}}`,
+			fields:    []string{"spec.checkType"},
+		},
+		{
+			name:      "namespace not enabled v1beta1",
+			version:   "v1beta1",
+			namespace: "team-a",
+			object:    `{"spec":{"exec":{"command":["/opt/checks/db.sh"]},"interval":"30s"}}`,
+			fields:    []string{"spec.exec"},
+		},
+		{
+			name:      "exec in a Job of a namespace not enabled",
+			version:   "v1alpha1",
+			namespace: "team-a",
+			object:    `{"spec":{"checkType":"exec","checkTarget":"pg_isready","interval":"1m","options":{"job":{"image":"postgres:16"}}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := admissionRequest(tt.version, tt.object)
+			req.Namespace = tt.namespace
+			resp := s.validate(req)
+			if len(tt.fields) == 0 {
+				if !resp.Allowed {
+					t.Fatalf("expected allowed, got denied: %s", resp.Result.Message)
+				}
+				return
+			}
+			if resp.Allowed {
+				t.Fatalf("expected denied, got allowed")
+			}
+			var got []string
+			for _, c := range resp.Result.Details.Causes {
+				got = append(got, c.Field)
+			}
+			if len(got) != len(tt.fields) || got[0] != tt.fields[0] {
+				t.Fatalf("causes = %v; want fields %v", got, tt.fields)
+			}
+		})
+	}
+}
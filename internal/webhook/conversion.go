@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/api/v1beta1"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var (
+	v1alpha1Version = v1alpha1.SchemeGroupVersion.String()
+	v1beta1Version  = v1beta1.SchemeGroupVersion.String()
+)
+
+// handleConvert implements the CRD conversion webhook between the served
+// versions of the probes.ready.io group. v1alpha1 is the storage version.
+func handleConvert(w http.ResponseWriter, r *http.Request) {
+	review := &apiextensionsv1.ConversionReview{}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+		http.Error(w, "malformed ConversionReview", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = convert(review.Request)
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		log.Printf("Failed to write conversion response: %v", err)
+	}
+}
+
+func convert(req *apiextensionsv1.ConversionRequest) *apiextensionsv1.ConversionResponse {
+	resp := &apiextensionsv1.ConversionResponse{UID: req.UID}
+	for _, obj := range req.Objects {
+		converted, err := convertObject(obj.Raw, req.DesiredAPIVersion)
+		if err != nil {
+			resp.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			return resp
+		}
+		resp.ConvertedObjects = append(resp.ConvertedObjects, converted)
+	}
+	resp.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return resp
+}
+
+func convertObject(raw []byte, desiredAPIVersion string) (runtime.RawExtension, error) {
+	var meta metav1.TypeMeta
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return runtime.RawExtension{}, err
+	}
+	if meta.APIVersion == desiredAPIVersion {
+		return runtime.RawExtension{Raw: raw}, nil
+	}
+
+	switch meta.Kind {
+	case "Probe", "ClusterProbe":
+		// ClusterProbe shares the Probe schema, so both kinds convert
+		// through the Probe types.
+		obj, err := convertProbe(raw, meta.APIVersion, desiredAPIVersion, meta.Kind)
+		return runtime.RawExtension{Object: obj}, err
+	default:
+		return runtime.RawExtension{}, fmt.Errorf("unsupported kind %q", meta.Kind)
+	}
+}
+
+func convertProbe(raw []byte, from, to, kind string) (runtime.Object, error) {
+	switch {
+	case from == v1alpha1Version && to == v1beta1Version:
+		src := &v1alpha1.Probe{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, err
+		}
+		dst := &v1beta1.Probe{}
+		if err := dst.ConvertFrom(src); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", kind, objectKey(src.Namespace, src.Name), err)
+		}
+		dst.TypeMeta = metav1.TypeMeta{APIVersion: to, Kind: kind}
+		return dst, nil
+	case from == v1beta1Version && to == v1alpha1Version:
+		src := &v1beta1.Probe{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, err
+		}
+		dst := &v1alpha1.Probe{}
+		if err := src.ConvertTo(dst); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", kind, objectKey(src.Namespace, src.Name), err)
+		}
+		dst.TypeMeta = metav1.TypeMeta{APIVersion: to, Kind: kind}
+		return dst, nil
+	default:
+		return nil, fmt.Errorf("unsupported conversion from %s to %s", from, to)
+	}
+}
+
+func objectKey(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/api/v1beta1"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestHandleConvert(t *testing.T) {
+	alpha := `{"apiVersion":"probes.ready.io/v1alpha1","kind":"Probe",` +
+		`"metadata":{"name":"check-google","namespace":"default"},` +
+		`"spec":{"checkType":"http","checkTarget":"https://google.com","interval":"30s"}}`
+
+	review := apiextensionsv1.ConversionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "ConversionReview"},
+		Request: &apiextensionsv1.ConversionRequest{
+			UID:               "1234",
+			DesiredAPIVersion: "probes.ready.io/v1beta1",
+			Objects:           []runtime.RawExtension{{Raw: []byte(alpha)}},
+		},
+	}
+	body, _ := json.Marshal(review)
+
+	rec := httptest.NewRecorder()
+	handleConvert(rec, httptest.NewRequest(http.MethodPost, "/convert", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var got apiextensionsv1.ConversionReview
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got.Response == nil || got.Response.UID != "1234" {
+		t.Fatalf("response UID not echoed: %+v", got.Response)
+	}
+	if got.Response.Result.Status != metav1.StatusSuccess {
+		t.Fatalf("conversion failed: %s", got.Response.Result.Message)
+	}
+	if len(got.Response.ConvertedObjects) != 1 {
+		t.Fatalf("expected 1 converted object, got %d", len(got.Response.ConvertedObjects))
+	}
+
+	var probe v1beta1.Probe
+	if err := json.Unmarshal(got.Response.ConvertedObjects[0].Raw, &probe); err != nil {
+		t.Fatalf("failed to decode converted object: %v", err)
+	}
+	if probe.APIVersion != "probes.ready.io/v1beta1" || probe.Kind != "Probe" {
+		t.Errorf("unexpected type meta: %+v", probe.TypeMeta)
+	}
+	if probe.Spec.HTTP == nil || probe.Spec.HTTP.URL != "https://google.com" {
+		t.Errorf("unexpected http check: %+v", probe.Spec.HTTP)
+	}
+}
+
+func TestHandleConvertUnsupportedKind(t *testing.T) {
+	resp := convert(&apiextensionsv1.ConversionRequest{
+		UID:               "1",
+		DesiredAPIVersion: "probes.ready.io/v1beta1",
+		Objects:           []runtime.RawExtension{{Raw: []byte(`{"apiVersion":"probes.ready.io/v1alpha1","kind":"Widget"}`)}},
+	})
+	if resp.Result.Status != metav1.StatusFailure {
+		t.Errorf("expected failure for unknown kind, got %+v", resp.Result)
+	}
+}
+
+func TestConvertClusterProbe(t *testing.T) {
+	beta := `{"apiVersion":"probes.ready.io/v1beta1","kind":"ClusterProbe",` +
+		`"metadata":{"name":"coredns"},` +
+		`"spec":{"dns":{"name":"kubernetes.default.svc.cluster.local"},"interval":"30s"}}`
+
+	resp := convert(&apiextensionsv1.ConversionRequest{
+		UID:               "1",
+		DesiredAPIVersion: "probes.ready.io/v1alpha1",
+		Objects:           []runtime.RawExtension{{Raw: []byte(beta)}},
+	})
+	if resp.Result.Status != metav1.StatusSuccess {
+		t.Fatalf("conversion failed: %s", resp.Result.Message)
+	}
+
+	raw, err := json.Marshal(resp.ConvertedObjects[0])
+	if err != nil {
+		t.Fatalf("failed to encode converted object: %v", err)
+	}
+	var got v1alpha1.ClusterProbe
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("failed to decode converted object: %v", err)
+	}
+	if got.Kind != "ClusterProbe" || got.APIVersion != "probes.ready.io/v1alpha1" {
+		t.Errorf("unexpected type meta: %+v", got.TypeMeta)
+	}
+	if got.Spec.CheckType != "dns" || got.Spec.CheckTarget != "kubernetes.default.svc.cluster.local" {
+		t.Errorf("unexpected spec: %+v", got.Spec)
+	}
+}
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"encoding/json"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/api/v1beta1"
+	"heartbeat-operator/internal/config"
+)
+
+// patchOp is a single RFC 6902 JSON patch operation.
+type patchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+func defaultProbeV1alpha1(p *v1alpha1.Probe, d config.Defaults) []patchOp {
+	var ops []patchOp
+	if p.Spec.Interval == "" && p.Spec.Schedule == "" {
+		ops = append(ops, patchOp{Op: "add", Path: "/spec/interval", Value: d.Interval.String()})
+	}
+	if p.Spec.Timeout == "" && !runsJob(p) {
+		ops = append(ops, patchOp{Op: "add", Path: "/spec/timeout", Value: d.Timeout.String()})
+	}
+	if p.Spec.SuccessThreshold == 0 {
+		ops = append(ops, patchOp{Op: "add", Path: "/spec/successThreshold", Value: d.SuccessThreshold})
+	}
+	if p.Spec.FailureThreshold == 0 {
+		ops = append(ops, patchOp{Op: "add", Path: "/spec/failureThreshold", Value: d.FailureThreshold})
+	}
+	return ops
+}
+
+// runsJob reports whether p is an exec check in a Job. Those are left
+// without timeout, the default one is too short to schedule a pod and the
+// prober picks its own.
+func runsJob(p *v1alpha1.Probe) bool {
+	if p.Spec.CheckType != "exec" || p.Spec.Options == nil {
+		return false
+	}
+	var opts struct {
+		Job json.RawMessage `json:"job"`
+	}
+	return json.Unmarshal(p.Spec.Options.Raw, &opts) == nil && len(opts.Job) > 0 && string(opts.Job) != "null"
+}
+
+func defaultProbeV1beta1(p *v1beta1.Probe, d config.Defaults) []patchOp {
+	var ops []patchOp
+	if p.Spec.Interval.Duration == 0 && p.Spec.Schedule == "" {
+		ops = append(ops, patchOp{Op: "add", Path: "/spec/interval", Value: d.Interval.String()})
+	}
+	if p.Spec.Timeout == nil && (p.Spec.Exec == nil || p.Spec.Exec.Job == nil) {
+		ops = append(ops, patchOp{Op: "add", Path: "/spec/timeout", Value: d.Timeout.String()})
+	}
+	if p.Spec.SuccessThreshold == 0 {
+		ops = append(ops, patchOp{Op: "add", Path: "/spec/successThreshold", Value: d.SuccessThreshold})
+	}
+	if p.Spec.FailureThreshold == 0 {
+		ops = append(ops, patchOp{Op: "add", Path: "/spec/failureThreshold", Value: d.FailureThreshold})
+	}
+	return ops
+}
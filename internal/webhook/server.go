@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"heartbeat-operator/internal/config"
+)
+
+// Server serves the Probe conversion, defaulting and validating webhooks.
+type Server struct {
+	defaults config.Defaults
+	lookup   DependencyLookup
+}
+
+// DependencyLookup returns the dependsOn entries of the stored probe with
+// the given config.GateRule Key, resolved with config.DependencyKey. A probe
+// that does not exist has no dependencies.
+type DependencyLookup func(ctx context.Context, key string) ([]string, error)
+
+// NewServer creates a webhook Server that fills and checks probes against
+// the given operator-wide defaults. lookup is used to reject dependency
+// cycles, when nil only a probe depending on itself is rejected.
+func NewServer(defaults config.Defaults, lookup DependencyLookup) *Server {
+	return &Server{defaults: defaults, lookup: lookup}
+}
+
+// Start serves the webhooks over TLS on addr. The serving certificate is
+// read from tls.crt and tls.key in certDir and reloaded when the files
+// change, so a rotated Secret is picked up without a restart.
+func (s *Server) Start(addr, certDir string) {
+	certs := &certReloader{
+		certFile: filepath.Join(certDir, "tls.crt"),
+		keyFile:  filepath.Join(certDir, "tls.key"),
+	}
+	if _, err := certs.GetCertificate(nil); err != nil {
+		log.Fatalf("Failed to load webhook certificate from %s: %v", certDir, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/convert", handleConvert)
+	mux.HandleFunc("/mutate-probe", s.handleMutate)
+	mux.HandleFunc("/validate-probe", s.handleValidate)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		},
+	}
+
+	log.Printf("Webhook server started on %s", addr)
+
+	go func() {
+		if err := server.ListenAndServeTLS("", ""); err != nil {
+			log.Fatalf("Webhook server failed to start: %v", err)
+		}
+	}()
+}
+
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	if r.cert != nil && info.ModTime().Equal(r.modTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			log.Printf("Failed to reload webhook certificate, keeping the previous one: %v", err)
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	r.cert = &cert
+	r.modTime = info.ModTime()
+	return r.cert, nil
+}
@@ -0,0 +1,441 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"heartbeat-operator/api/v1alpha1"
+	"heartbeat-operator/api/v1beta1"
+	"heartbeat-operator/internal/config"
+	"heartbeat-operator/internal/prober"
+	"heartbeat-operator/internal/schedule"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func validateProbeV1alpha1(p *v1alpha1.Probe, d config.Defaults) field.ErrorList {
+	spec := field.NewPath("spec")
+	allErrs := field.ErrorList{}
+
+	if !prober.IsRegistered(p.Spec.CheckType) {
+		allErrs = append(allErrs, field.NotSupported(spec.Child("checkType"), p.Spec.CheckType, prober.Types()))
+	} else {
+		var options []byte
+		if p.Spec.Options != nil {
+			options = p.Spec.Options.Raw
+		}
+		allErrs = append(allErrs, validateTarget(p.Spec.CheckType, p.Spec.CheckTarget, options, spec.Child("checkTarget"))...)
+	}
+	if p.Spec.CheckType == "heartbeat" && p.Spec.Schedule != "" {
+		var opts struct {
+			Grace string `json:"grace"`
+		}
+		if p.Spec.Options != nil {
+			_ = json.Unmarshal(p.Spec.Options.Raw, &opts)
+		}
+		if opts.Grace == "" {
+			allErrs = append(allErrs, field.Required(spec.Child("options", "grace"), "a scheduled heartbeat expects pings within grace"))
+		}
+	}
+
+	interval, errs := parseDuration(p.Spec.Interval, spec.Child("interval"), p.Spec.Schedule == "")
+	allErrs = append(allErrs, errs...)
+	allErrs = append(allErrs, validateSchedule(p.Spec.Schedule, p.Spec.TimeZone, spec)...)
+	timeout, errs := parseDuration(p.Spec.Timeout, spec.Child("timeout"), false)
+	allErrs = append(allErrs, errs...)
+	allErrs = append(allErrs, validateTiming(interval, timeout, spec, d)...)
+	if r := p.Spec.Retries; r != nil {
+		path := spec.Child("retries")
+		delay, errs := parseDelay(r.Delay, path.Child("delay"))
+		allErrs = append(allErrs, errs...)
+		_, errs = parseDuration(r.MaxDelay, path.Child("maxDelay"), false)
+		allErrs = append(allErrs, errs...)
+		allErrs = append(allErrs, validateRetries(r.Count, delay, r.Backoff, interval, timeout, path)...)
+	}
+	if p.Spec.SuccessThreshold < 0 {
+		allErrs = append(allErrs, field.Invalid(spec.Child("successThreshold"), p.Spec.SuccessThreshold, "must be at least 1"))
+	}
+	if p.Spec.FailureThreshold < 0 {
+		allErrs = append(allErrs, field.Invalid(spec.Child("failureThreshold"), p.Spec.FailureThreshold, "must be at least 1"))
+	}
+	return allErrs
+}
+
+func validateProbeV1beta1(p *v1beta1.Probe, d config.Defaults) field.ErrorList {
+	spec := field.NewPath("spec")
+	allErrs := field.ErrorList{}
+
+	var set []string
+	if c := p.Spec.HTTP; c != nil {
+		set = append(set, "http")
+		path := spec.Child("http")
+		allErrs = append(allErrs, validateTarget("http", c.URL, nil, path.Child("url"))...)
+		if c.Method != "" && !validMethod(c.Method) {
+			allErrs = append(allErrs, field.Invalid(path.Child("method"), c.Method, "must be an HTTP method such as GET or HEAD"))
+		}
+		for i, code := range c.ExpectedStatusCodes {
+			if code < 100 || code > 599 {
+				allErrs = append(allErrs, field.Invalid(path.Child("expectedStatusCodes").Index(i), code, "must be between 100 and 599"))
+			}
+		}
+	}
+	if c := p.Spec.TCP; c != nil {
+		set = append(set, "tcp")
+		path := spec.Child("tcp")
+		if c.Host == "" {
+			allErrs = append(allErrs, field.Required(path.Child("host"), ""))
+		}
+		if c.Port < 1 || c.Port > 65535 {
+			allErrs = append(allErrs, field.Invalid(path.Child("port"), c.Port, "must be between 1 and 65535"))
+		}
+	}
+	if c := p.Spec.Exec; c != nil {
+		set = append(set, "exec")
+		errs := validateCommand(c.Command, spec.Child("exec", "command"))
+		switch {
+		case len(errs) > 0:
+			allErrs = append(allErrs, errs...)
+		case c.Pod != nil || c.Job != nil:
+			options, _ := json.Marshal(map[string]interface{}{"pod": c.Pod, "job": c.Job})
+			allErrs = append(allErrs, validateTarget("exec", strings.Join(c.Command, " "), options, spec.Child("exec"))...)
+		default:
+			// Checks the command against the allowlist of the exec policy
+			allErrs = append(allErrs, validateTarget("exec", strings.Join(c.Command, " "), nil, spec.Child("exec", "command"))...)
+		}
+	}
+	if c := p.Spec.DNS; c != nil {
+		set = append(set, "dns")
+		allErrs = append(allErrs, validateTarget("dns", c.Name, nil, spec.Child("dns", "name"))...)
+	}
+	if c := p.Spec.Kubernetes; c != nil {
+		set = append(set, "kubernetes")
+		path := spec.Child("kubernetes")
+		switch {
+		case c.Resource == "":
+			allErrs = append(allErrs, field.Required(path.Child("resource"), ""))
+		case (c.Name == "") == (c.LabelSelector == ""):
+			allErrs = append(allErrs, field.Invalid(path, c.Name, "exactly one of name and labelSelector must be set"))
+		default:
+			target := c.Resource
+			if c.Namespace != "" {
+				target += "/" + c.Namespace
+			}
+			if c.Name != "" {
+				target += "/" + c.Name
+			}
+			options, _ := json.Marshal(map[string]interface{}{
+				"labelSelector": c.LabelSelector,
+				"condition":     c.Condition,
+				"status":        c.Status,
+				"fields":        c.Fields,
+			})
+			allErrs = append(allErrs, validateTarget("kubernetes", target, options, path)...)
+		}
+	}
+	if c := p.Spec.Heartbeat; c != nil {
+		set = append(set, "heartbeat")
+		path := spec.Child("heartbeat")
+		if c.Grace != nil && c.Grace.Duration < 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("grace"), c.Grace.Duration.String(), "must not be negative"))
+		}
+		if c.Grace == nil && p.Spec.Schedule != "" {
+			allErrs = append(allErrs, field.Required(path.Child("grace"), "a scheduled heartbeat expects pings within grace"))
+		}
+		allErrs = append(allErrs, validateTarget("heartbeat", c.SecretName, nil, path.Child("secretName"))...)
+	}
+	if c := p.Spec.CronJob; c != nil {
+		set = append(set, "cronJob")
+		path := spec.Child("cronJob")
+		if c.Tolerance != nil && c.Tolerance.Duration < 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("tolerance"), c.Tolerance.Duration.String(), "must not be negative"))
+		}
+		if c.MaxDuration != nil && c.MaxDuration.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("maxDuration"), c.MaxDuration.Duration.String(), "must be positive"))
+		}
+		target := c.Name
+		if c.Namespace != "" {
+			target = c.Namespace + "/" + c.Name
+		}
+		allErrs = append(allErrs, validateTarget("cronjob", target, nil, path)...)
+	}
+	if c := p.Spec.Plugin; c != nil {
+		set = append(set, "plugin")
+		path := spec.Child("plugin")
+		switch c.Type {
+		case "cronjob":
+			allErrs = append(allErrs, field.Invalid(path.Child("type"), c.Type, "use spec.cronJob instead"))
+		case "http", "tcp", "exec", "dns", "kubernetes", "heartbeat":
+			allErrs = append(allErrs, field.Invalid(path.Child("type"), c.Type, fmt.Sprintf("use spec.%s instead", c.Type)))
+		default:
+			if !prober.IsRegistered(c.Type) {
+				allErrs = append(allErrs, field.NotSupported(path.Child("type"), c.Type, prober.Types()))
+				break
+			}
+			var options []byte
+			if c.Options != nil {
+				options = c.Options.Raw
+			}
+			allErrs = append(allErrs, validateTarget(c.Type, c.Target, options, path.Child("target"))...)
+		}
+	}
+	if f := p.Spec.Endpoints; f != nil {
+		path := spec.Child("endpoints")
+		options, _ := json.Marshal(map[string]interface{}{"endpoints": f})
+		switch {
+		case p.Spec.HTTP != nil:
+			allErrs = append(allErrs, validateTarget("http", p.Spec.HTTP.URL, options, path)...)
+		case p.Spec.TCP != nil:
+			target := net.JoinHostPort(p.Spec.TCP.Host, strconv.Itoa(int(p.Spec.TCP.Port)))
+			allErrs = append(allErrs, validateTarget("tcp", target, options, path)...)
+		default:
+			allErrs = append(allErrs, field.Forbidden(path, "only http and tcp checks can probe the endpoints of a Service"))
+		}
+	}
+	switch len(set) {
+	case 0:
+		allErrs = append(allErrs, field.Required(spec, "one of http, tcp, exec, dns, kubernetes, heartbeat, cronJob or plugin must be set"))
+	case 1:
+	default:
+		allErrs = append(allErrs, field.Forbidden(spec, fmt.Sprintf("only one check may be set, found %s", strings.Join(set, ", "))))
+	}
+
+	if p.Spec.Interval.Duration == 0 && p.Spec.Schedule == "" {
+		allErrs = append(allErrs, field.Required(spec.Child("interval"), ""))
+	}
+	allErrs = append(allErrs, validateSchedule(p.Spec.Schedule, p.Spec.TimeZone, spec)...)
+	var timeout time.Duration
+	if p.Spec.Timeout != nil {
+		timeout = p.Spec.Timeout.Duration
+		if timeout <= 0 {
+			allErrs = append(allErrs, field.Invalid(spec.Child("timeout"), p.Spec.Timeout.Duration.String(), "must be positive"))
+		}
+	}
+	allErrs = append(allErrs, validateTiming(p.Spec.Interval.Duration, timeout, spec, d)...)
+	if r := p.Spec.Retries; r != nil {
+		path := spec.Child("retries")
+		delay := config.DefaultRetryDelay
+		if r.Delay != nil {
+			delay = r.Delay.Duration
+			if delay < 0 {
+				allErrs = append(allErrs, field.Invalid(path.Child("delay"), delay.String(), "must not be negative"))
+			}
+		}
+		if r.MaxDelay != nil && r.MaxDelay.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("maxDelay"), r.MaxDelay.Duration.String(), "must be positive"))
+		}
+		allErrs = append(allErrs, validateRetries(r.Count, delay, r.Backoff, p.Spec.Interval.Duration, timeout, path)...)
+	}
+
+	if p.Spec.SuccessThreshold < 0 {
+		allErrs = append(allErrs, field.Invalid(spec.Child("successThreshold"), p.Spec.SuccessThreshold, "must be at least 1"))
+	}
+	if p.Spec.FailureThreshold < 0 {
+		allErrs = append(allErrs, field.Invalid(spec.Child("failureThreshold"), p.Spec.FailureThreshold, "must be at least 1"))
+	}
+	return allErrs
+}
+
+// validateExecNamespace rejects exec checks that run in the operator for
+// namespaces the exec policy does not enable them in.
+func validateExecNamespace(namespace string, inOperator bool, path *field.Path) field.ErrorList {
+	if !inOperator || prober.ExecEnabledIn(namespace) {
+		return nil
+	}
+	return field.ErrorList{field.Forbidden(path, fmt.Sprintf("exec checks in the operator are not enabled for namespace %s, run the command in a pod or Job instead", namespace))}
+}
+
+// execInOperator reports whether p is an exec check without pod or job, so
+// its command runs in the operator container.
+func execInOperator(p *v1alpha1.Probe) bool {
+	if p.Spec.CheckType != "exec" {
+		return false
+	}
+	if p.Spec.Options == nil {
+		return true
+	}
+	var opts struct {
+		Pod json.RawMessage `json:"pod"`
+		Job json.RawMessage `json:"job"`
+	}
+	if json.Unmarshal(p.Spec.Options.Raw, &opts) != nil {
+		return true
+	}
+	set := func(raw json.RawMessage) bool { return len(raw) > 0 && string(raw) != "null" }
+	return !set(opts.Pod) && !set(opts.Job)
+}
+
+// validateDependencies checks the syntax of the dependsOn entries of the
+// probe under review and rejects entries that lead back to it.
+func (s *Server) validateDependencies(req *admissionv1.AdmissionRequest, deps []string, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, dep := range deps {
+		name := strings.TrimPrefix(dep, config.KindClusterProbe+"/")
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), dep, msg))
+		}
+	}
+	if len(allErrs) > 0 || len(deps) == 0 || req.Name == "" {
+		return allErrs
+	}
+
+	self := config.GateRule{Kind: req.Kind.Kind, Namespace: req.Namespace, Name: req.Name}
+	self.DependsOn = deps
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cycle, err := config.FindCycle(self.Key(), func(key string) ([]string, error) {
+		if key == self.Key() {
+			return self.DependencyKeys(), nil
+		}
+		if s.lookup == nil {
+			return nil, nil
+		}
+		return s.lookup(ctx, key)
+	})
+	if err != nil {
+		// Don't block writes while the API is unreachable, the controller
+		// ignores the dependencies of a cycle it runs into.
+		log.Printf("Skipping dependency cycle check of %s: %v", self.Key(), err)
+		return allErrs
+	}
+	if cycle != nil {
+		allErrs = append(allErrs, field.Invalid(path, deps, "dependency cycle: "+strings.Join(cycle, " -> ")))
+	}
+	return allErrs
+}
+
+// validateTiming checks interval and timeout against each other and the
+// operator limits. Zero values were reported by the caller and are skipped.
+func validateTiming(interval, timeout time.Duration, spec *field.Path, d config.Defaults) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if interval > 0 && interval < d.MinInterval {
+		allErrs = append(allErrs, field.Invalid(spec.Child("interval"), interval.String(),
+			fmt.Sprintf("must be at least %s", d.MinInterval)))
+	}
+	if interval > 0 && timeout > interval {
+		allErrs = append(allErrs, field.Invalid(spec.Child("timeout"), timeout.String(),
+			fmt.Sprintf("must not exceed the interval (%s)", interval)))
+	}
+	return allErrs
+}
+
+// maxRetries bounds the retries of a check, they are meant to be quick.
+const maxRetries = 10
+
+// validateRetries checks the retry policy of a probe. The first retry has
+// to fit in the timeout, or the interval when there is none.
+func validateRetries(count int32, delay time.Duration, backoff string, interval, timeout time.Duration, path *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if count < 0 || count > maxRetries {
+		allErrs = append(allErrs, field.Invalid(path.Child("count"), count, fmt.Sprintf("must be between 0 and %d", maxRetries)))
+	}
+	switch backoff {
+	case "", v1alpha1.RetryBackoffFixed, v1alpha1.RetryBackoffExponential:
+	default:
+		allErrs = append(allErrs, field.NotSupported(path.Child("backoff"), backoff, []string{v1alpha1.RetryBackoffFixed, v1alpha1.RetryBackoffExponential}))
+	}
+	budget, name := timeout, "timeout"
+	if budget == 0 {
+		budget, name = interval, "interval"
+	}
+	if count > 0 && budget > 0 && delay >= budget {
+		allErrs = append(allErrs, field.Invalid(path.Child("delay"), delay.String(), fmt.Sprintf("must be shorter than the %s (%s) to leave time for a retry", name, budget)))
+	}
+	return allErrs
+}
+
+// parseDelay parses a retry delay that may be zero, config.DefaultRetryDelay
+// when it is empty.
+func parseDelay(s string, path *field.Path) (time.Duration, field.ErrorList) {
+	if s == "" {
+		return config.DefaultRetryDelay, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, field.ErrorList{field.Invalid(path, s, "must be a duration such as 200ms or 1s")}
+	}
+	if d < 0 {
+		return 0, field.ErrorList{field.Invalid(path, s, "must not be negative")}
+	}
+	return d, nil
+}
+
+// validateSchedule checks the cron expressions of expr and the time zone
+// they are in.
+func validateSchedule(expr, timeZone string, spec *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	loc := time.UTC
+	if timeZone != "" {
+		l, err := time.LoadLocation(timeZone)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(spec.Child("timeZone"), timeZone, "must be a time zone such as Europe/Berlin"))
+		} else {
+			loc = l
+		}
+	}
+	if expr == "" {
+		return allErrs
+	}
+	set, err := schedule.ParseSet(expr, loc)
+	if err != nil {
+		return append(allErrs, field.Invalid(spec.Child("schedule"), expr, err.Error()))
+	}
+	if set.Next(time.Now()).IsZero() {
+		allErrs = append(allErrs, field.Invalid(spec.Child("schedule"), expr, "never fires"))
+	}
+	return allErrs
+}
+
+func parseDuration(s string, path *field.Path, required bool) (time.Duration, field.ErrorList) {
+	if s == "" {
+		if required {
+			return 0, field.ErrorList{field.Required(path, "")}
+		}
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, field.ErrorList{field.Invalid(path, s, "must be a duration such as 30s or 1m")}
+	}
+	if d <= 0 {
+		return 0, field.ErrorList{field.Invalid(path, s, "must be positive")}
+	}
+	return d, nil
+}
+
+// validateTarget checks target and options with the validator of the
+// registered checkType.
+func validateTarget(checkType, target string, options []byte, path *field.Path) field.ErrorList {
+	if target == "" {
+		return field.ErrorList{field.Required(path, "")}
+	}
+	allErrs := field.ErrorList{}
+	for _, msg := range prober.Validate(checkType, target, options) {
+		allErrs = append(allErrs, field.Invalid(path, target, msg))
+	}
+	return allErrs
+}
+
+func validateCommand(cmd []string, path *field.Path) field.ErrorList {
+	if len(cmd) == 0 || cmd[0] == "" {
+		return field.ErrorList{field.Required(path, "a command to run")}
+	}
+	return nil
+}
+
+func validMethod(m string) bool {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
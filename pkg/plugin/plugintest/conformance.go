@@ -0,0 +1,208 @@
+// Package plugintest checks that a prober plugin follows the plugin
+// protocol. Plugin authors run it from a test of their own:
+//
+//	func TestConformance(t *testing.T) {
+//		plugintest.Conformance(t, []string{"./my-plugin"}, []plugintest.Case{
+//			{CheckType: "mq", Target: "qm1:1414", Healthy: true},
+//		})
+//	}
+package plugintest
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"heartbeat-operator/pkg/plugin"
+)
+
+// Case is a check with a known outcome.
+type Case struct {
+	CheckType string
+	Target    string
+	Options   json.RawMessage
+	Healthy   bool
+}
+
+const replyTimeout = 10 * time.Second
+
+// Conformance launches the plugin with command and runs the protocol checks
+// against it: the handshake, error replies to unknown types and methods,
+// the given cases one by one and all at once, and a clean exit on EOF.
+func Conformance(t *testing.T, command []string, cases []Case) {
+	t.Helper()
+	p := start(t, command)
+
+	t.Run("handshake", func(t *testing.T) {
+		resp := p.roundTrip(t, plugin.Request{ID: 1, Method: plugin.MethodHandshake, ProtocolVersion: plugin.ProtocolVersion})
+		if resp.Error != "" {
+			t.Fatalf("handshake failed: %s", resp.Error)
+		}
+		if resp.ProtocolVersion != plugin.ProtocolVersion {
+			t.Errorf("protocolVersion = %d; want %d", resp.ProtocolVersion, plugin.ProtocolVersion)
+		}
+		if len(resp.CheckTypes) == 0 {
+			t.Errorf("plugin serves no check types")
+		}
+		served := map[string]bool{}
+		for _, ct := range resp.CheckTypes {
+			served[ct] = true
+		}
+		for _, c := range cases {
+			if !served[c.CheckType] {
+				t.Errorf("check type %q of a case is not announced", c.CheckType)
+			}
+		}
+	})
+
+	t.Run("unsupported protocol version", func(t *testing.T) {
+		resp := p.roundTrip(t, plugin.Request{ID: 2, Method: plugin.MethodHandshake, ProtocolVersion: plugin.ProtocolVersion + 100})
+		if resp.Error == "" {
+			t.Errorf("expected an error for protocol version %d", plugin.ProtocolVersion+100)
+		}
+	})
+
+	t.Run("unknown check type", func(t *testing.T) {
+		resp := p.roundTrip(t, plugin.Request{ID: 3, Method: plugin.MethodCheck, CheckType: "plugintest-unknown", TimeoutMillis: 1000})
+		if resp.Error == "" {
+			t.Errorf("expected an error for an unknown check type")
+		}
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		resp := p.roundTrip(t, plugin.Request{ID: 4, Method: "plugintest-unknown"})
+		if resp.Error == "" {
+			t.Errorf("expected an error for an unknown method")
+		}
+	})
+
+	t.Run("cases", func(t *testing.T) {
+		for i, c := range cases {
+			resp := p.roundTrip(t, checkRequest(uint64(100+i), c))
+			if resp.Error != "" {
+				t.Errorf("case %d (%s %s): error %s", i, c.CheckType, c.Target, resp.Error)
+			} else if resp.Healthy != c.Healthy {
+				t.Errorf("case %d (%s %s): healthy = %v; want %v (%s)", i, c.CheckType, c.Target, resp.Healthy, c.Healthy, resp.Message)
+			}
+		}
+	})
+
+	t.Run("concurrent cases", func(t *testing.T) {
+		for i, c := range cases {
+			p.send(t, checkRequest(uint64(1000+i), c))
+		}
+		for range cases {
+			resp := p.receive(t)
+			i := int(resp.ID) - 1000
+			if i < 0 || i >= len(cases) {
+				t.Fatalf("reply to unknown request %d", resp.ID)
+			}
+			if resp.Healthy != cases[i].Healthy {
+				t.Errorf("case %d: healthy = %v; want %v", i, resp.Healthy, cases[i].Healthy)
+			}
+		}
+	})
+
+	t.Run("exit on EOF", func(t *testing.T) {
+		p.stdin.Close()
+		done := make(chan error, 1)
+		go func() { done <- p.cmd.Wait() }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("plugin exited with %v", err)
+			}
+		case <-time.After(replyTimeout):
+			t.Errorf("plugin did not exit after its stdin was closed")
+		}
+	})
+}
+
+func checkRequest(id uint64, c Case) plugin.Request {
+	return plugin.Request{
+		ID:            id,
+		Method:        plugin.MethodCheck,
+		CheckType:     c.CheckType,
+		Target:        c.Target,
+		Options:       c.Options,
+		TimeoutMillis: replyTimeout.Milliseconds(),
+	}
+}
+
+type process struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	replies chan plugin.Response
+}
+
+func start(t *testing.T, command []string) *process {
+	t.Helper()
+	if len(command) == 0 {
+		t.Fatalf("no plugin command")
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("stdin: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("stdout: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start plugin: %v", err)
+	}
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+
+	p := &process{cmd: cmd, stdin: stdin, replies: make(chan plugin.Response, 64)}
+	go func() {
+		defer close(p.replies)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			var resp plugin.Response
+			if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+				t.Errorf("malformed response %q: %v", scanner.Text(), err)
+				continue
+			}
+			p.replies <- resp
+		}
+	}()
+	return p
+}
+
+func (p *process) send(t *testing.T, req plugin.Request) {
+	t.Helper()
+	data, _ := json.Marshal(req)
+	if _, err := p.stdin.Write(append(data, '\n')); err != nil {
+		t.Fatalf("failed to write request: %v", err)
+	}
+}
+
+func (p *process) receive(t *testing.T) plugin.Response {
+	t.Helper()
+	select {
+	case resp, ok := <-p.replies:
+		if !ok {
+			t.Fatalf("plugin closed its stdout")
+		}
+		return resp
+	case <-time.After(replyTimeout):
+		t.Fatalf("no reply within %s", replyTimeout)
+	}
+	return plugin.Response{}
+}
+
+func (p *process) roundTrip(t *testing.T, req plugin.Request) plugin.Response {
+	t.Helper()
+	p.send(t, req)
+	resp := p.receive(t)
+	if resp.ID != req.ID {
+		t.Fatalf("reply id = %d; want %d", resp.ID, req.ID)
+	}
+	return resp
+}
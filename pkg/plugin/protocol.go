@@ -0,0 +1,60 @@
+// Package plugin implements the protocol between heartbeat-operator and
+// out-of-process prober plugins, and helps writing such plugins.
+//
+// A plugin talks JSON lines: every message is one JSON object followed by a
+// newline. The operator sends Requests and the plugin answers each with a
+// Response carrying the same ID. Responses may be sent in any order, so a
+// plugin is free to run checks concurrently.
+//
+// The first request is always a handshake, answered with the protocol
+// version and the check types the plugin serves. Check requests follow.
+// A plugin is either launched by the operator and talks over its stdin and
+// stdout, or listens on a unix socket the operator connects to.
+package plugin
+
+import "encoding/json"
+
+// ProtocolVersion is the version of the protocol described here.
+const ProtocolVersion = 1
+
+// Request methods.
+const (
+	MethodHandshake = "handshake"
+	MethodCheck     = "check"
+)
+
+// Request is sent by the operator.
+type Request struct {
+	ID     uint64 `json:"id"`
+	Method string `json:"method"`
+
+	// ProtocolVersion is set on handshake requests.
+	ProtocolVersion int `json:"protocolVersion,omitempty"`
+
+	// The fields below are set on check requests.
+	CheckType string          `json:"checkType,omitempty"`
+	Target    string          `json:"target,omitempty"`
+	Options   json.RawMessage `json:"options,omitempty"`
+	// Namespace and Name identify the Probe the check runs for. Namespace
+	// is empty for ClusterProbes.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	// TimeoutMillis is the time the operator waits for the result.
+	TimeoutMillis int64 `json:"timeoutMillis,omitempty"`
+}
+
+// Response is sent by the plugin for every Request.
+type Response struct {
+	ID uint64 `json:"id"`
+	// Error is set when the request could not be handled, e.g. an unknown
+	// check type. A failed check is not an error, see Healthy.
+	Error string `json:"error,omitempty"`
+
+	// The fields below answer handshake requests.
+	ProtocolVersion int      `json:"protocolVersion,omitempty"`
+	CheckTypes      []string `json:"checkTypes,omitempty"`
+
+	// The fields below answer check requests.
+	Healthy bool   `json:"healthy,omitempty"`
+	Message string `json:"message,omitempty"`
+}
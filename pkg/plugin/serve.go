@@ -0,0 +1,142 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CheckFunc runs one check against target. ctx is cancelled once the
+// operator stops waiting for the result.
+type CheckFunc func(ctx context.Context, target string, options json.RawMessage) (healthy bool, message string)
+
+// Probe identifies the Probe a check runs for.
+type Probe struct {
+	// Namespace is empty for ClusterProbes.
+	Namespace string
+	Name      string
+}
+
+type probeKey struct{}
+
+// ProbeFrom returns the Probe the check running with ctx belongs to. It is
+// zero when the operator did not send one.
+func ProbeFrom(ctx context.Context) Probe {
+	p, _ := ctx.Value(probeKey{}).(Probe)
+	return p
+}
+
+// Checks maps the check types a plugin serves to the functions running them.
+type Checks map[string]CheckFunc
+
+// maxLineSize bounds a single request line.
+const maxLineSize = 1 << 20
+
+// Serve reads requests from r and writes their responses to w until r is
+// exhausted. Checks run concurrently.
+func Serve(r io.Reader, w io.Writer, checks Checks) error {
+	types := make([]string, 0, len(checks))
+	for t := range checks {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	respond := func(resp Response) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := enc.Encode(resp); err != nil {
+			log.Printf("plugin: failed to write response %d: %v", resp.ID, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			respond(Response{Error: fmt.Sprintf("malformed request: %v", err)})
+			continue
+		}
+
+		switch req.Method {
+		case MethodHandshake:
+			if req.ProtocolVersion != ProtocolVersion {
+				respond(Response{ID: req.ID, Error: fmt.Sprintf("unsupported protocol version %d, want %d", req.ProtocolVersion, ProtocolVersion)})
+				continue
+			}
+			respond(Response{ID: req.ID, ProtocolVersion: ProtocolVersion, CheckTypes: types})
+		case MethodCheck:
+			check, ok := checks[req.CheckType]
+			if !ok {
+				respond(Response{ID: req.ID, Error: fmt.Sprintf("unknown check type %q", req.CheckType)})
+				continue
+			}
+			wg.Add(1)
+			go func(req Request) {
+				defer wg.Done()
+				ctx := context.WithValue(context.Background(), probeKey{}, Probe{Namespace: req.Namespace, Name: req.Name})
+				if req.TimeoutMillis > 0 {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithTimeout(ctx, time.Duration(req.TimeoutMillis)*time.Millisecond)
+					defer cancel()
+				}
+				healthy, msg := check(ctx, req.Target, req.Options)
+				respond(Response{ID: req.ID, Healthy: healthy, Message: msg})
+			}(req)
+		default:
+			respond(Response{ID: req.ID, Error: fmt.Sprintf("unknown method %q", req.Method)})
+		}
+	}
+	return scanner.Err()
+}
+
+// Main runs a plugin serving checks. By default it talks over stdin and
+// stdout, with -socket it listens on a unix socket instead and serves every
+// connection. Plugins call it from their main function.
+func Main(checks Checks) {
+	socket := flag.String("socket", "", "listen on this unix socket instead of using stdin and stdout")
+	flag.Parse()
+
+	// stdout carries the protocol, keep logs out of it
+	log.SetOutput(os.Stderr)
+
+	if *socket == "" {
+		if err := Serve(os.Stdin, os.Stdout, checks); err != nil {
+			log.Fatalf("plugin: %v", err)
+		}
+		return
+	}
+
+	_ = os.Remove(*socket)
+	ln, err := net.Listen("unix", *socket)
+	if err != nil {
+		log.Fatalf("plugin: %v", err)
+	}
+	log.Printf("plugin: listening on %s", *socket)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Fatalf("plugin: %v", err)
+		}
+		go func() {
+			defer conn.Close()
+			if err := Serve(conn, conn, checks); err != nil {
+				log.Printf("plugin: connection closed: %v", err)
+			}
+		}()
+	}
+}